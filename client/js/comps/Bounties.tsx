@@ -30,11 +30,12 @@ export default class Bounties extends React.Component<Props, {}> {
 
     componentWillMount() {
         let {repo} = this.props.repoStore;
+        this.props.bountyStore.subscribeToChanges(repo.id);
         this.props.bountyStore.fetchBountiesOfRepo(repo.owner, repo.name);
     }
 
     componentWillUnmount() {
-
+        this.props.bountyStore.unsubscribeFromChanges();
     }
 
     toggleNewBountyForm = () => {
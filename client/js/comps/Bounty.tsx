@@ -16,7 +16,7 @@ import DialogContentText from '@material-ui/core/DialogContentText';
 import DialogTitle from '@material-ui/core/DialogTitle';
 
 import {Loader} from "./Loader";
-import {BountyState, BountyStore, mapStateToStr} from "../stores/BountyStore";
+import {BountyState, BountyStore, formatBalance, isSettled, mapStateToStr} from "../stores/BountyStore";
 
 import {RepositoryStore} from "../stores/RepositoryStore";
 import {UIStore} from "../stores/UIStore";
@@ -45,11 +45,13 @@ export default class Bounty extends React.Component<Props, {}> {
         let {id} = this.props.match.params;
         this.props.bountyStore.fetchBounty(id);
         this.props.repoStore.fetchRepoForBounty(id);
+        this.props.bountyStore.subscribeToChanges();
     }
 
     componentWillUnmount() {
         this.closeDeleteBountyModal();
         this.props.bountyStore.resetDeleted();
+        this.props.bountyStore.unsubscribeFromChanges();
     }
 
     deleteBounty = () => {
@@ -135,7 +137,7 @@ export default class Bounty extends React.Component<Props, {}> {
                             State
                         </Typography>
                         {
-                            bounty.state == BountyState.Transferred ?
+                            (bounty.state == BountyState.Transferred || bounty.state == BountyState.Confirmed) ?
                                 <div>
                                     <Typography component="p">
                                         {`${mapStateToStr(bounty.state)} `}
@@ -166,12 +168,27 @@ export default class Bounty extends React.Component<Props, {}> {
                                 <div className={css.addressBox}>{bounty.pool_address}</div>
                             </a>
                         </Grid>
+                        {
+                            isSettled(bounty.state) &&
+                            <Typography component="p" color="error">
+                                This address is spent, don't send any further tokens to it.
+                                {bounty.late_balance > 0 && ` It received ${bounty.late_balance} iotas after the payout.`}
+                            </Typography>
+                        }
+                        {
+                            !isSettled(bounty.state) && bounty.spent_pool_addresses &&
+                            <Typography component="p" color="error">
+                                Previous addresses of this bounty are spent, don't send any further tokens to them:
+                                {bounty.spent_pool_addresses.map(addr => <span key={addr}> {addr.substr(0, 10)}...</span>)}
+                                {bounty.late_balance > 0 && ` They received ${bounty.late_balance} iotas after the payouts.`}
+                            </Typography>
+                        }
                         <Divider className={css.dividerMiddle}/>
                         <Typography component="h2">
                             Balance
                         </Typography>
                         <Typography component="p">
-                            {bounty.balance} iotas
+                            {formatBalance(bounty)}
                         </Typography>
                         <Divider className={css.dividerMiddle}/>
                         {
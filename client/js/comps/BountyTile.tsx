@@ -12,7 +12,7 @@ import Typography from '@material-ui/core/Typography';
 import LaunchIcon from '@material-ui/icons/Launch';
 import Divider from "@material-ui/core/Divider";
 
-import {Bounty, BountyState, mapStateToStr} from "../stores/BountyStore";
+import {Bounty, BountyState, formatBalance, mapStateToStr} from "../stores/BountyStore";
 import {Repository} from "../stores/RepositoryStore";
 
 import * as css from './app.scss';
@@ -57,7 +57,7 @@ class bountyTile extends React.Component<BountyTileProps, {}> {
                             State
                         </Typography>
                         {
-                            bounty.state == BountyState.Transferred ?
+                            (bounty.state == BountyState.Transferred || bounty.state == BountyState.Confirmed) ?
                                 <div>
                                     <Typography component="p">
                                         {`${mapStateToStr(bounty.state)} `}
@@ -89,7 +89,7 @@ class bountyTile extends React.Component<BountyTileProps, {}> {
                             Balance
                         </Typography>
                         <Typography component="p">
-                            {bounty.balance} iotas
+                            {formatBalance(bounty)}
                         </Typography>
                         <Divider className={css.dividerMiddle}/>
                         <Typography color="textSecondary">
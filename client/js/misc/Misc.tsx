@@ -26,6 +26,6 @@ export function mapTextToError(txt: string, errorEnum: any, errorMap: any): stri
 
 export enum CreateError {
     Unknown = "unknown",
-    AlreadyExists = "duplicate key",
+    AlreadyExists = "already_exists",
     NotFound = "404"
 }
\ No newline at end of file
@@ -5,7 +5,11 @@ import {Model} from "./AppStore";
 export enum BountyState {
     Open,
     Released,
-    Transferred
+    Transferred,
+    Refunding,
+    Refunded,
+    Expired,
+    Confirmed
 }
 
 export function mapStateToStr(state: BountyState): string {
@@ -16,11 +20,32 @@ export function mapStateToStr(state: BountyState): string {
             return "Released";
         case BountyState.Transferred:
             return "Transferred";
+        case BountyState.Refunding:
+            return "Refunding";
+        case BountyState.Refunded:
+            return "Refunded";
+        case BountyState.Expired:
+            return "Expired";
+        case BountyState.Confirmed:
+            return "Confirmed";
         default:
             return "Unknown"
     }
 }
 
+// whether the funds of the bounty have left or are leaving the (then spent) pool address
+export function isSettled(state: BountyState): boolean {
+    switch (state) {
+        case BountyState.Transferred:
+        case BountyState.Refunding:
+        case BountyState.Refunded:
+        case BountyState.Confirmed:
+            return true;
+        default:
+            return false;
+    }
+}
+
 export class Bounty extends Model {
     id: number;
     issue_number: number;
@@ -34,6 +59,42 @@ export class Bounty extends Model {
     title: string;
     body: string;
     state: BountyState;
+    deadline: string;
+    expired_on: string;
+    late_balance: number;
+    spent_pool_addresses: Array<string>;
+    fiat: FiatValue;
+}
+
+export class FiatValue {
+    usd: number;
+    eur: number;
+    rates_updated_on: string;
+}
+
+// a change of a bounty streamed from /api/events
+export class LiveEvent {
+    id: number;
+    kind: string;
+    repository_id: number;
+    bounty_id: number;
+    state: BountyState;
+    balance: number;
+    milestone: number;
+    created_on: string;
+}
+
+// the kinds of live events which concern the shown bounties
+const bountyEventKinds = [
+    "bounty_created", "bounty_balance_changed", "bounty_released",
+    "bounty_transferred", "bounty_refunded", "bounty_deleted", "reset",
+];
+
+export function formatBalance(bounty: Bounty): string {
+    if (!bounty.fiat) {
+        return `${bounty.balance} iotas`;
+    }
+    return `${bounty.balance} iotas (~$${bounty.fiat.usd.toFixed(2)} / ~€${bounty.fiat.eur.toFixed(2)})`;
 }
 
 export let BountyCreateError = {
@@ -68,6 +129,10 @@ export class BountyStore {
     @observable new_bounty_issue_id: number = null;
     @observable new_bounty_form_state = FormState.Init;
 
+    // the repository whose bounties are listed
+    shownRepo: { owner: string, name: string } = null;
+    liveEvents: EventSource = null;
+
     @action
     resetDeleted = () => this.deleted = false;
 
@@ -93,7 +158,16 @@ export class BountyStore {
     }
 
     fetchBountiesOfRepo = async (owner: string, name: string) => {
+        this.shownRepo = {owner, name};
         this.setLoading(true);
+        try {
+            await this.loadBountiesOfRepo(owner, name);
+        } finally {
+            this.setLoading(false);
+        }
+    }
+
+    loadBountiesOfRepo = async (owner: string, name: string) => {
         try {
             let res = await fetch(`/api/bounties/${owner}/${name}`);
             if (res.status !== 200) {
@@ -105,11 +179,57 @@ export class BountyStore {
             this.setBounties(bounties);
         } catch (err) {
             this.setError(err);
-        } finally {
-            this.setLoading(false);
         }
     }
 
+    // keeps the shown bounties up to date with the changes streamed by the server,
+    // the browser reconnects and resumes the stream on its own if it is interrupted
+    subscribeToChanges = (repositoryID?: number) => {
+        this.unsubscribeFromChanges();
+        this.liveEvents = new EventSource(repositoryID ? `/api/events?repository_id=${repositoryID}` : '/api/events');
+        bountyEventKinds.forEach(kind => {
+            this.liveEvents.addEventListener(kind, (e: MessageEvent) => this.applyLiveEvent(JSON.parse(e.data)));
+        });
+    }
+
+    unsubscribeFromChanges = () => {
+        if (!this.liveEvents) {
+            return;
+        }
+        this.liveEvents.close();
+        this.liveEvents = null;
+        this.shownRepo = null;
+    }
+
+    @action
+    applyLiveEvent = (event: LiveEvent) => {
+        let shown = this.bounty && this.bounty.id === event.bounty_id;
+        switch (event.kind) {
+            case "bounty_created":
+            case "bounty_deleted":
+            case "reset":
+                // the listing changed or changes were missed
+                if (this.shownRepo) {
+                    this.loadBountiesOfRepo(this.shownRepo.owner, this.shownRepo.name);
+                }
+                if (event.kind === "bounty_deleted" && shown) {
+                    this.deleted = true;
+                }
+                if (event.kind === "reset" && this.bounty) {
+                    this.fetchBounty(String(this.bounty.id));
+                }
+                return;
+        }
+
+        let targets = [this.bounties.get(event.bounty_id), shown ? this.bounty : null];
+        targets.filter(bounty => bounty).forEach(bounty => {
+            bounty.state = event.state;
+            bounty.balance = event.balance;
+            // the fiat value is of the previous balance
+            bounty.fiat = null;
+        });
+    }
+
     deleteBounty = async (id: number) => {
         this.setLoading(true);
         try {
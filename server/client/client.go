@@ -0,0 +1,359 @@
+// Package client is a typed client of the REST API of the IOTA Bounty Platform,
+// as described by the OpenAPI specification served at /api/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nextCursorHeader = "X-Next-Cursor"
+
+// Error is returned for responses with a non 2xx status code.
+type Error struct {
+	StatusCode int
+	// the stable code of the error, e.g. "issue_not_found", empty if the response wasn't an API error
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("ibp API error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("ibp API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the API of a platform instance. Requests are authenticated with an API token if one is set,
+// otherwise with the basic auth credentials if set.
+type Client struct {
+	BaseURL    string
+	Token      string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// New creates a client for the platform at the given base URL (e.g. https://example.com) using the given API token.
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// BountyQuery filters, sorts and pages bounty listings and searches. Unset fields don't filter.
+type BountyQuery struct {
+	States        []models.BountyState
+	RepositoryID  int64
+	MinBalance    *uint64
+	MaxBalance    *uint64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// created_on, updated_on or balance, prefixed with "-" for a descending order
+	Sort string
+	// the cursor returned with the previous page
+	Cursor string
+	Limit  int64
+}
+
+func (q *BountyQuery) values() url.Values {
+	v := url.Values{}
+	if q == nil {
+		return v
+	}
+	if len(q.States) > 0 {
+		states := make([]string, len(q.States))
+		for i, state := range q.States {
+			states[i] = strconv.Itoa(int(state))
+		}
+		v.Set("state", strings.Join(states, ","))
+	}
+	if q.RepositoryID != 0 {
+		v.Set("repository_id", strconv.FormatInt(q.RepositoryID, 10))
+	}
+	if q.MinBalance != nil {
+		v.Set("min_balance", strconv.FormatUint(*q.MinBalance, 10))
+	}
+	if q.MaxBalance != nil {
+		v.Set("max_balance", strconv.FormatUint(*q.MaxBalance, 10))
+	}
+	for param, t := range map[string]*time.Time{
+		"created_after": q.CreatedAfter, "created_before": q.CreatedBefore,
+		"updated_after": q.UpdatedAfter, "updated_before": q.UpdatedBefore,
+	} {
+		if t != nil {
+			v.Set(param, t.Format(time.RFC3339))
+		}
+	}
+	if q.Sort != "" {
+		v.Set("sort", q.Sort)
+	}
+	if q.Cursor != "" {
+		v.Set("cursor", q.Cursor)
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	return v
+}
+
+// Repositories
+
+func (c *Client) ListRepos(ctx context.Context) ([]models.Repository, error) {
+	repos := []models.Repository{}
+	_, err := c.do(ctx, http.MethodGet, "/api/repos", nil, nil, &repos)
+	return repos, err
+}
+
+func (c *Client) GetRepo(ctx context.Context, id int64) (*models.Repository, error) {
+	repo := &models.Repository{}
+	_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/repos/%d", id), nil, nil, repo)
+	return repo, err
+}
+
+func (c *Client) GetRepoByName(ctx context.Context, owner string, name string) (*models.Repository, error) {
+	repo := &models.Repository{}
+	_, err := c.do(ctx, http.MethodGet, repoPath("/api/repos", owner, name), nil, nil, repo)
+	return repo, err
+}
+
+// GetRepoOfBounty returns the repository of the given bounty.
+func (c *Client) GetRepoOfBounty(ctx context.Context, bountyID int64) (*models.Repository, error) {
+	repo := &models.Repository{}
+	_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/repos/of/%d", bountyID), nil, nil, repo)
+	return repo, err
+}
+
+func (c *Client) AddRepo(ctx context.Context, owner string, name string) (*models.Repository, error) {
+	repo := &models.Repository{}
+	_, err := c.do(ctx, http.MethodPost, repoPath("/api/repos", owner, name), nil, nil, repo)
+	return repo, err
+}
+
+func (c *Client) UpdateRepoSettings(ctx context.Context, id int64, settings *models.RepositorySettings) (*models.Repository, error) {
+	repo := &models.Repository{}
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/repos/%d/settings", id), nil, settings, repo)
+	return repo, err
+}
+
+// DeleteRepo deletes the repository and all its bounties.
+func (c *Client) DeleteRepo(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/repos/%d", id), nil, nil, nil)
+	return err
+}
+
+// Bounties
+
+// ListBounties returns a page of bounties and the cursor of the next page, which is empty on the last page.
+func (c *Client) ListBounties(ctx context.Context, query *BountyQuery) ([]models.Bounty, string, error) {
+	bounties := []models.Bounty{}
+	header, err := c.do(ctx, http.MethodGet, "/api/bounties", query.values(), nil, &bounties)
+	if err != nil {
+		return nil, "", err
+	}
+	return bounties, header.Get(nextCursorHeader), nil
+}
+
+// ListRepoBounties returns the bounties of a repository, all of them if the query has no limit.
+func (c *Client) ListRepoBounties(ctx context.Context, owner string, name string, query *BountyQuery) ([]models.Bounty, string, error) {
+	bounties := []models.Bounty{}
+	header, err := c.do(ctx, http.MethodGet, repoPath("/api/bounties", owner, name), query.values(), nil, &bounties)
+	if err != nil {
+		return nil, "", err
+	}
+	return bounties, header.Get(nextCursorHeader), nil
+}
+
+func (c *Client) GetBounty(ctx context.Context, id int64) (*models.Bounty, error) {
+	bounty := &models.Bounty{}
+	_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/bounties/%d", id), nil, nil, bounty)
+	return bounty, err
+}
+
+func (c *Client) ListBountyPayouts(ctx context.Context, id int64) ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	query := url.Values{"bounty_id": {strconv.FormatInt(id, 10)}}
+	_, err := c.do(ctx, http.MethodGet, "/api/payouts", query, nil, &payouts)
+	return payouts, err
+}
+
+func (c *Client) ListBountyAuditEvents(ctx context.Context, id int64) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	query := url.Values{"bounty_id": {strconv.FormatInt(id, 10)}}
+	_, err := c.do(ctx, http.MethodGet, "/api/audit", query, nil, &events)
+	return events, err
+}
+
+func (c *Client) CreateBounty(ctx context.Context, req *models.NewBounty) (*models.Bounty, error) {
+	bounty := &models.Bounty{}
+	_, err := c.do(ctx, http.MethodPost, "/api/bounties", nil, req, bounty)
+	return bounty, err
+}
+
+// CancelBounty cancels the bounty and returns the refunds of its contributions.
+func (c *Client) CancelBounty(ctx context.Context, id int64) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/bounties/%d/cancel", id), nil, nil, &refunds)
+	return refunds, err
+}
+
+// SweepBounty sends the late deposits of a settled bounty to the given target.
+func (c *Client) SweepBounty(ctx context.Context, id int64, target models.SweepTarget) (*models.Payout, error) {
+	payout := &models.Payout{}
+	query := url.Values{"target": {string(target)}}
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/bounties/%d/sweep", id), query, nil, payout)
+	return payout, err
+}
+
+// RegisterRefundAddress sets the refund address (with checksum) of the contribution with the given bundle hash.
+func (c *Client) RegisterRefundAddress(ctx context.Context, id int64, bundleHash string, address string) error {
+	path := fmt.Sprintf("/api/bounties/%d/contributions/%s/refund_address", id, url.PathEscape(bundleHash))
+	_, err := c.do(ctx, http.MethodPut, path, url.Values{"address": {address}}, nil, nil)
+	return err
+}
+
+func (c *Client) SetBountyDeadline(ctx context.Context, id int64, deadline time.Time) (*models.Bounty, error) {
+	bounty := &models.Bounty{}
+	query := url.Values{"deadline": {deadline.Format(time.RFC3339)}}
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/bounties/%d/deadline", id), query, nil, bounty)
+	return bounty, err
+}
+
+func (c *Client) SetBountyMilestones(ctx context.Context, id int64, milestones []models.Milestone) (*models.Bounty, error) {
+	bounty := &models.Bounty{}
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/bounties/%d/milestones", id), nil, milestones, bounty)
+	return bounty, err
+}
+
+func (c *Client) DeleteBounty(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/bounties/%d", id), nil, nil, nil)
+	return err
+}
+
+// Payouts
+
+// ListPayouts returns all payouts, or the ones in the given state if it is set.
+func (c *Client) ListPayouts(ctx context.Context, state *models.PayoutState) ([]models.Payout, error) {
+	query := url.Values{}
+	if state != nil {
+		query.Set("state", strconv.Itoa(int(*state)))
+	}
+	payouts := []models.Payout{}
+	_, err := c.do(ctx, http.MethodGet, "/api/payouts", query, nil, &payouts)
+	return payouts, err
+}
+
+func (c *Client) ListStuckPayouts(ctx context.Context) ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	_, err := c.do(ctx, http.MethodGet, "/api/payouts/stuck", nil, nil, &payouts)
+	return payouts, err
+}
+
+func (c *Client) ListPayoutsAwaitingApproval(ctx context.Context) ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	_, err := c.do(ctx, http.MethodGet, "/api/payouts/awaiting_approval", nil, nil, &payouts)
+	return payouts, err
+}
+
+func (c *Client) ApprovePayout(ctx context.Context, id primitive.ObjectID) (*models.Payout, error) {
+	payout := &models.Payout{}
+	_, err := c.do(ctx, http.MethodPost, "/api/payouts/"+id.Hex()+"/approve", nil, nil, payout)
+	return payout, err
+}
+
+func (c *Client) RejectPayout(ctx context.Context, id primitive.ObjectID) (*models.Payout, error) {
+	payout := &models.Payout{}
+	_, err := c.do(ctx, http.MethodPost, "/api/payouts/"+id.Hex()+"/reject", nil, nil, payout)
+	return payout, err
+}
+
+// Search
+
+// Search returns the bounties and repositories matching the given text, the bounties are narrowed down by the query.
+func (c *Client) Search(ctx context.Context, text string, query *BountyQuery) (*models.SearchResult, error) {
+	values := query.values()
+	values.Set("q", text)
+	res := &models.SearchResult{}
+	_, err := c.do(ctx, http.MethodGet, "/api/search", values, nil, res)
+	return res, err
+}
+
+func repoPath(prefix string, owner string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", prefix, url.PathEscape(owner), url.PathEscape(name))
+}
+
+// do sends the request with the given body encoded as JSON and decodes the JSON response into res if it is set.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, res interface{}) (http.Header, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "ibp-client/"+models.APIVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		apiErr := &Error{}
+		if err := json.Unmarshal(msg, apiErr); err != nil || apiErr.Code == "" {
+			apiErr = &Error{Message: strings.TrimSpace(string(msg))}
+		}
+		apiErr.StatusCode = resp.StatusCode
+		return nil, apiErr
+	}
+	if res == nil {
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, fmt.Errorf("couldn't decode response of %s %s: %s", method, path, err.Error())
+	}
+	return resp.Header, nil
+}
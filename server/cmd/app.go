@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"github.com/luca-moser/iota-bounty-platform/server/controllers"
 	"github.com/luca-moser/iota-bounty-platform/server/server"
 	"os"
@@ -10,7 +12,76 @@ import (
 	"time"
 )
 
+var rotateSeedKey = flag.String("rotate-seed-key", "", "re-encrypt all bounty seeds with the master key in the given file and exit")
+var recoverAccounts = flag.Bool("recover-accounts", false, "re-derive all bounty seeds from the master seed, print their balances and exit")
+var recoverIndices = flag.Int64("recover-indices", 0, "the amount of seed indices to scan, 0 scans all indices recorded in the database")
+var recoverAddresses = flag.Uint64("recover-addresses", 10, "the amount of addresses to scan per seed")
+var reconcile = flag.Bool("reconcile", false, "compare the stored bounties with the tangle, print the found discrepancies and exit")
+
 func main() {
+	flag.Parse()
+
+	if *rotateSeedKey != "" {
+		rotated, err := server.RotateSeedKey(*rotateSeedKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "seed key rotation failed after %d seeds: %s\n", rotated, err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("re-encrypted %d seeds, update the seed_encryption config to use the new master key\n", rotated)
+		return
+	}
+
+	if *recoverAccounts {
+		accounts, err := server.RecoverAccounts(*recoverIndices, *recoverAddresses)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "account recovery failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		var total uint64
+		for _, acc := range accounts {
+			bountyInfo := "no bounty"
+			if acc.BountyID != 0 {
+				bountyInfo = fmt.Sprintf("bounty %d", acc.BountyID)
+				if acc.Deleted {
+					bountyInfo += " (deleted)"
+				}
+			}
+			if acc.CampaignID != "" {
+				bountyInfo = fmt.Sprintf("campaign %s", acc.CampaignID)
+			}
+			fmt.Printf("index %d, %s: %d iotas\n", acc.SeedIndex, bountyInfo, acc.Balance)
+			for i, addr := range acc.Addresses {
+				fmt.Printf("\t%s: %d iotas\n", addr, acc.Balances[i])
+			}
+			total += acc.Balance
+		}
+		fmt.Printf("scanned %d seeds, total balance: %d iotas\n", len(accounts), total)
+		return
+	}
+
+	if *reconcile {
+		report, err := server.Reconcile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reconciliation failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		for _, discrepancy := range report.Discrepancies {
+			fmt.Printf("bounty %d: %s (stored: %s, on tangle: %s)\n",
+				discrepancy.BountyID, discrepancy.Kind, discrepancy.Stored, discrepancy.OnTangle)
+			if discrepancy.Detail != "" {
+				fmt.Printf("\t%s\n", discrepancy.Detail)
+			}
+		}
+		for _, reconcileErr := range report.Errors {
+			fmt.Fprintf(os.Stderr, "error: %s\n", reconcileErr)
+		}
+		fmt.Printf("checked %d bounties, found %d discrepancies\n", report.BountiesChecked, len(report.Discrepancies))
+		if len(report.Errors) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	srv := server.Server{}
 
 	sigs := make(chan os.Signal, 1)
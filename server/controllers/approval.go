@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+var ErrPayoutAwaitingApproval = errors.New("a payout of this bounty is awaiting approval")
+var ErrPayoutNotAwaitingApproval = errors.New("the payout isn't awaiting approval")
+var ErrApprovalExpired = errors.New("the approval period of the payout has expired")
+var ErrApproverNotAllowed = errors.New("the payout must be approved by another person than the one who released the bounty or receives it")
+var ErrBountyBalanceChanged = errors.New("the balance of the bounty changed since the payout was requested")
+var ErrPayoutReceiverChanged = errors.New("the bounty was released to another receiver since the payout was requested")
+
+const defaultApprovalExpireHours = 72
+
+// requiresApproval tells whether the value of the given payout exceeds the configured approval threshold.
+func (bc *BountyCtrl) requiresApproval(payout *models.Payout) bool {
+	threshold := bc.Config.Payouts.ApprovalThreshold
+	return threshold > 0 && payout.Value > threshold
+}
+
+// holdForApproval persists the given payout in the outbox without sending it.
+func (bc *BountyCtrl) holdForApproval(bounty *models.Bounty, payout *models.Payout) error {
+	expireHours := bc.Config.Payouts.ApprovalExpireHours
+	if expireHours <= 0 {
+		expireHours = defaultApprovalExpireHours
+	}
+	expiresOn := time.Now().Add(time.Duration(expireHours) * time.Hour)
+	payout.State = models.PayoutStateAwaitingApproval
+	payout.ExpiresOn = &expiresOn
+	if err := bc.insertPayout(bounty, payout); err != nil {
+		return err
+	}
+	bc.logger.Info(fmt.Sprintf("payout %s of bounty %d over %d iotas is awaiting approval", payout.ID.Hex(), bounty.ID, payout.Value))
+	return nil
+}
+
+func (bc *BountyCtrl) GetPayoutByID(id primitive.ObjectID) (*models.Payout, error) {
+	res := bc.PayoutColl.FindOne(DefaultCtx(), bson.D{{"_id", id}})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	payout := &models.Payout{}
+	err := res.Decode(payout)
+	return payout, errors.Wrapf(err, "(payout) couldn't load payout '%s'", id.Hex())
+}
+
+// GetAwaitingPayoutOfBounty returns the payout of the given bounty which is awaiting approval.
+func (bc *BountyCtrl) GetAwaitingPayoutOfBounty(bountyID int64) (*models.Payout, error) {
+	res := bc.PayoutColl.FindOne(DefaultCtx(), bson.D{
+		{"bounty_id", bountyID},
+		{"state", models.PayoutStateAwaitingApproval},
+	})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	payout := &models.Payout{}
+	err := res.Decode(payout)
+	return payout, errors.Wrapf(err, "(payout) couldn't load payout awaiting approval of bounty '%d'", bountyID)
+}
+
+// ApprovePayout approves the given payout on behalf of the given actor and sends it off. Approvals must carry
+// the GitHub identity of the approver, as otherwise it can't be told apart from the requester and the receiver.
+func (bc *BountyCtrl) ApprovePayout(payout *models.Payout, actor *models.Actor) error {
+	if payout.State != models.PayoutStateAwaitingApproval {
+		return ErrPayoutNotAwaitingApproval
+	}
+
+	bounty, err := bc.GetByID(payout.BountyID)
+	if err != nil {
+		return err
+	}
+
+	approverID := actor.GitHubID
+	if approverID == 0 {
+		return errors.Wrap(ErrApproverNotAllowed, "the approval carries no GitHub identity")
+	}
+	if approverID == payout.RequestedBy || approverID == payout.ReceiverID {
+		return ErrApproverNotAllowed
+	}
+
+	if payout.ExpiresOn != nil && time.Now().After(*payout.ExpiresOn) {
+		if err := bc.withdrawPayout(bounty, payout, models.PayoutStateExpired, ErrApprovalExpired.Error(), PlatformActor); err != nil {
+			return err
+		}
+		return ErrApprovalExpired
+	}
+
+	if err := bc.checkPayoutStillReleased(bounty, payout); err != nil {
+		return err
+	}
+
+	// the approved amounts must match what is actually sent, milestones only take their share of the balance
+	availBalance, err := bc.GetAccountBalance(bounty.Seed)
+	if err != nil {
+		return err
+	}
+	if availBalance != payout.Value && (payout.Kind != models.PayoutKindMilestone || availBalance < payout.Value) {
+		if err := bc.withdrawPayout(bounty, payout, models.PayoutStateRejected, ErrBountyBalanceChanged.Error(), PlatformActor); err != nil {
+			return err
+		}
+		return ErrBountyBalanceChanged
+	}
+
+	// only the first approval of concurrent ones sends the payout
+	t := time.Now()
+	res, err := bc.PayoutColl.UpdateOne(DefaultCtx(),
+		bson.D{{"_id", payout.ID}, {"state", models.PayoutStateAwaitingApproval}},
+		bson.D{{"$set", bson.D{
+			{"state", models.PayoutStatePending},
+			{"approved_by", approverID},
+			{"approved_on", t},
+			{"model.updated_on", t},
+		}}})
+	if err != nil {
+		return errors.Wrapf(err, "(payout) couldn't approve payout '%s'", payout.ID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return ErrPayoutNotAwaitingApproval
+	}
+	payout.State = models.PayoutStatePending
+	payout.ApprovedBy = approverID
+	payout.ApprovedOn = &t
+	bc.logger.Info(fmt.Sprintf("payout %s of bounty %d approved by %d", payout.ID.Hex(), bounty.ID, approverID))
+	bc.audit(bounty.ID, models.AuditEventPayoutApproved, actor, fmt.Sprintf("payout %s", payout.ID.Hex()),
+		auditChange("payout_state", models.PayoutStateAwaitingApproval, models.PayoutStatePending),
+		auditChange("approved_by", nil, approverID),
+	)
+
+	if err := bc.dispatchPayout(bounty, payout, payoutRecipients(payout.Split, payout.ReceiverAddress)); err != nil {
+		bc.audit(bounty.ID, models.AuditEventTransferFailed, actor, err.Error())
+		return err
+	}
+
+	if err := bc.finalizePayout(payout); err != nil {
+		return err
+	}
+	bc.auditPayoutSent(bounty, payout, actor)
+
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return err
+	}
+	// ignore error as the payout already happened
+	if payout.Kind == models.PayoutKindMilestone {
+		bc.renewPoolAddress(bounty)
+		err = bc.Bot.PostMilestoneSentMessage(repo.Owner, repo.Name, bounty, payout)
+	} else {
+		err = bc.Bot.PostBountySentMessage(repo.Owner, repo.Name, bounty, payout.Split, payout.BundleHash)
+	}
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to post bounty sent message: %s", err.Error()))
+	}
+	return nil
+}
+
+// checkPayoutStillReleased withdraws the given payout awaiting approval if its bounty (or milestone)
+// is no longer released or was released to another receiver than the one who requested it.
+func (bc *BountyCtrl) checkPayoutStillReleased(bounty *models.Bounty, payout *models.Payout) error {
+	reason := "the bounty is no longer in the released state"
+	released := bounty.State == models.BountyStateReleased
+	receiverID := bounty.ReceiverID
+	if payout.Kind == models.PayoutKindMilestone {
+		reason = fmt.Sprintf("milestone %d is no longer released", payout.Milestone)
+		milestone, err := getMilestone(bounty, payout.Milestone)
+		released = err == nil && bounty.State == models.BountyStateOpen && milestone.State == models.MilestoneStateReleased
+		if err == nil {
+			receiverID = milestone.ReceiverID
+		}
+	}
+	if released && receiverID == payout.ReceiverID {
+		return nil
+	}
+	if released {
+		reason = "released to another receiver"
+	}
+
+	if err := bc.withdrawPayout(bounty, payout, models.PayoutStateRejected, reason, PlatformActor); err != nil {
+		return err
+	}
+	switch {
+	case released:
+		return ErrPayoutReceiverChanged
+	case bounty.State == models.BountyStateExpired:
+		return ErrBountyExpired
+	case payout.Kind == models.PayoutKindMilestone && !bounty.Settled():
+		return ErrMilestoneNotReleased
+	}
+	return ErrBountyAlreadySettled
+}
+
+// withdrawPayoutOfOtherReceiver withdraws the payout of the bounty awaiting approval if it was requested by
+// another receiver than the given one. With a milestone number, only a payout of that milestone is withdrawn.
+func (bc *BountyCtrl) withdrawPayoutOfOtherReceiver(bounty *models.Bounty, receiverID int64, milestone int, actor *models.Actor) error {
+	payout, err := bc.GetAwaitingPayoutOfBounty(bounty.ID)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if payout.ReceiverID == receiverID || (milestone != 0 && payout.Milestone != milestone) {
+		return nil
+	}
+	return bc.withdrawPayout(bounty, payout, models.PayoutStateRejected, "released to another receiver", actor)
+}
+
+// RejectPayout rejects the given payout awaiting approval, the receiver can then request the payout again.
+func (bc *BountyCtrl) RejectPayout(payout *models.Payout, actor *models.Actor) error {
+	if payout.State != models.PayoutStateAwaitingApproval {
+		return ErrPayoutNotAwaitingApproval
+	}
+
+	bounty, err := bc.GetByID(payout.BountyID)
+	if err != nil {
+		return err
+	}
+
+	reason := "rejected through the admin API"
+	if actor.GitHubID != 0 {
+		reason = fmt.Sprintf("rejected by GitHub user %d", actor.GitHubID)
+	}
+	return bc.withdrawPayout(bounty, payout, models.PayoutStateRejected, reason, actor)
+}
+
+// ExpirePayouts expires all payouts which weren't approved in time.
+func (bc *BountyCtrl) ExpirePayouts() {
+	payouts, err := bc.getPayouts(bson.D{
+		{"state", models.PayoutStateAwaitingApproval},
+		{"expires_on", bson.D{{"$lt", time.Now()}}},
+	})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load payouts awaiting approval: %s", err.Error()))
+		return
+	}
+
+	for i := range payouts {
+		payout := &payouts[i]
+		bounty, err := bc.GetByID(payout.BountyID)
+		if err == nil {
+			err = bc.withdrawPayout(bounty, payout, models.PayoutStateExpired, ErrApprovalExpired.Error(), PlatformActor)
+		}
+		if err != nil {
+			bc.logger.Error(fmt.Sprintf("can't expire payout %s of bounty %d: %s", payout.ID.Hex(), payout.BountyID, err.Error()))
+		}
+	}
+}
+
+// withdrawPayout moves the given payout awaiting approval into the expired or rejected state and notifies the issue.
+func (bc *BountyCtrl) withdrawPayout(bounty *models.Bounty, payout *models.Payout, state models.PayoutState, reason string, actor *models.Actor) error {
+	res, err := bc.PayoutColl.UpdateOne(DefaultCtx(),
+		bson.D{{"_id", payout.ID}, {"state", models.PayoutStateAwaitingApproval}},
+		bson.D{{"$set", bson.D{
+			{"state", state},
+			{"error", reason},
+			{"model.updated_on", time.Now()},
+		}}})
+	if err != nil {
+		return errors.Wrapf(err, "(payout) couldn't withdraw payout '%s'", payout.ID.Hex())
+	}
+	if res.ModifiedCount == 0 {
+		return ErrPayoutNotAwaitingApproval
+	}
+	payout.State = state
+	payout.Error = reason
+	bc.logger.Info(fmt.Sprintf("payout %s of bounty %d withdrawn: %s", payout.ID.Hex(), bounty.ID, reason))
+	bc.audit(bounty.ID, models.AuditEventPayoutWithdrawn, actor, fmt.Sprintf("payout %s: %s", payout.ID.Hex(), reason),
+		auditChange("payout_state", models.PayoutStateAwaitingApproval, state),
+	)
+
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return err
+	}
+	return bc.Bot.PostPayoutWithdrawnMessage(repo.Owner, repo.Name, bounty, payout)
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"gopkg.in/inconshreveable/log15.v2"
+	"sync"
+	"time"
+)
+
+const auditCollection = "audit_events"
+
+const defaultAuditPageSize = 100
+const maxAuditPageSize = 1000
+
+// the actors of changes which aren't initiated through a comment on GitHub
+var APIActor = &models.Actor{Source: models.ActorSourceAPI}
+var PlatformActor = &models.Actor{Source: models.ActorSourcePlatform}
+
+type AuditCtrl struct {
+	Config *config.Configuration `inject:""`
+	Mongo  *mongo.Client         `inject:""`
+	Coll   *mongo.Collection
+	logger log15.Logger
+	// serializes appends, as each event references the hash of its predecessor
+	mu sync.Mutex
+}
+
+func (ac *AuditCtrl) Init() error {
+	logger, err := misc.GetLogger("audit-ctrl")
+	if err != nil {
+		return err
+	}
+	ac.logger = logger
+
+	ac.Coll = ac.Mongo.Database(ac.Config.DB.DBName).Collection(auditCollection)
+
+	// the unique sequence makes concurrent appends to the same predecessor fail instead of forking the chain
+	seqIndexName := "seq"
+	seqIndexUnique := true
+	bountyIndexName := "bounty_id_seq"
+	_, err = ac.Coll.Indexes().CreateMany(DefaultCtx(), []mongo.IndexModel{
+		{
+			Keys:    bsonx.Doc{{Key: "seq", Value: bsonx.Int32(int32(1))}},
+			Options: &options.IndexOptions{Name: &seqIndexName, Unique: &seqIndexUnique},
+		},
+		{
+			Keys: bsonx.Doc{
+				{Key: "bounty_id", Value: bsonx.Int32(int32(1))},
+				{Key: "seq", Value: bsonx.Int32(int32(1))},
+			},
+			Options: &options.IndexOptions{Name: &bountyIndexName},
+		},
+	})
+	return err
+}
+
+// Record appends an event of the given kind to the audit log.
+func (ac *AuditCtrl) Record(bountyID int64, kind models.AuditEventKind, actor *models.Actor, note string, changes ...models.AuditChange) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	last := &models.AuditEvent{}
+	opts := options.FindOne().SetSort(bson.D{{"seq", -1}})
+	if err := ac.Coll.FindOne(DefaultCtx(), bson.D{}, opts).Decode(last); err != nil {
+		if err != mongo.ErrNoDocuments {
+			return errors.Wrap(err, "(audit) couldn't load last audit event")
+		}
+		last = nil
+	}
+
+	if actor == nil {
+		actor = PlatformActor
+	}
+	if changes == nil {
+		changes = []models.AuditChange{}
+	}
+
+	event := &models.AuditEvent{
+		ID:       primitive.NewObjectID(),
+		BountyID: bountyID,
+		Kind:     kind,
+		Actor:    *actor,
+		Changes:  changes,
+		Note:     note,
+		// mongo only stores milliseconds, the hash must match the stored value
+		CreatedOn: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if last != nil {
+		event.Seq = last.Seq + 1
+		event.PrevHash = last.Hash
+	}
+	hash, err := hashAuditEvent(event)
+	if err != nil {
+		return err
+	}
+	event.Hash = hash
+
+	_, err = ac.Coll.InsertOne(DefaultCtx(), event)
+	return errors.Wrapf(err, "(audit) couldn't record %s event of bounty '%d'", kind, bountyID)
+}
+
+// hashAuditEvent computes the hash over the content of the event and the hash of its predecessor.
+func hashAuditEvent(event *models.AuditEvent) (string, error) {
+	changes := event.Changes
+	if changes == nil {
+		changes = []models.AuditChange{}
+	}
+	content, err := json.Marshal(struct {
+		Seq       int64                 `json:"seq"`
+		PrevHash  string                `json:"prev_hash"`
+		BountyID  int64                 `json:"bounty_id"`
+		Kind      models.AuditEventKind `json:"kind"`
+		Actor     models.Actor          `json:"actor"`
+		Changes   []models.AuditChange  `json:"changes"`
+		Note      string                `json:"note"`
+		CreatedOn int64                 `json:"created_on"`
+	}{
+		event.Seq, event.PrevHash, event.BountyID, event.Kind, event.Actor,
+		changes, event.Note, event.CreatedOn.UnixNano() / int64(time.Millisecond),
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// GetAll returns the events following the given sequence number in the order they were recorded.
+func (ac *AuditCtrl) GetAll(afterSeq int64, limit int64) ([]models.AuditEvent, error) {
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+	opts := options.Find().SetSort(bson.D{{"seq", 1}}).SetLimit(limit)
+	return ac.getEvents(bson.D{{"seq", bson.D{{"$gt", afterSeq}}}}, opts)
+}
+
+// GetOfBounty returns all events of the given bounty in the order they were recorded.
+func (ac *AuditCtrl) GetOfBounty(bountyID int64) ([]models.AuditEvent, error) {
+	return ac.getEvents(bson.D{{"bounty_id", bountyID}}, options.Find().SetSort(bson.D{{"seq", 1}}))
+}
+
+func (ac *AuditCtrl) getEvents(filter bson.D, opts *options.FindOptions) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	cursor, err := ac.Coll.Find(DefaultCtx(), filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "(audit) couldn't load audit events")
+	}
+	for cursor.Next(DefaultCtx()) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// AuditVerification is the result of verifying the hash chain of the audit log.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Events   int64  `json:"events"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes the hashes of all events and checks that they form an unbroken chain.
+func (ac *AuditCtrl) Verify() (*AuditVerification, error) {
+	// the whole log is read, which might take longer than the default timeout
+	ctx := context.Background()
+	cursor, err := ac.Coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{"seq", 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "(audit) couldn't load audit events")
+	}
+
+	res := &AuditVerification{Valid: true}
+	broken := func(seq int64, reason string) (*AuditVerification, error) {
+		res.Valid = false
+		res.BrokenAt = &seq
+		res.Reason = reason
+		return res, nil
+	}
+
+	var prevHash string
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		if event.Seq != res.Events {
+			return broken(res.Events, fmt.Sprintf("expected event %d but found event %d", res.Events, event.Seq))
+		}
+		if event.PrevHash != prevHash {
+			return broken(event.Seq, "the event doesn't reference the hash of its predecessor")
+		}
+		hash, err := hashAuditEvent(&event)
+		if err != nil {
+			return nil, err
+		}
+		if hash != event.Hash {
+			return broken(event.Seq, "the content of the event doesn't match its hash")
+		}
+		prevHash = event.Hash
+		res.Events++
+	}
+	return res, cursor.Err()
+}
+
+// auditChange creates a change of the given field, formatting the values in a stable textual representation.
+func auditChange(field string, before interface{}, after interface{}) models.AuditChange {
+	return models.AuditChange{Field: field, Before: auditValue(before), After: auditValue(after)}
+}
+
+func auditValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		return value.UTC().Format(time.RFC3339)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// audit records an event of the given bounty. Failing to record the event must not undo or abort the change
+// which already happened, therefore errors are only logged.
+func (bc *BountyCtrl) audit(bountyID int64, kind models.AuditEventKind, actor *models.Actor, note string, changes ...models.AuditChange) {
+	if err := bc.Audit.Record(bountyID, kind, actor, note, changes...); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to record %s audit event of bounty %d: %s", kind, bountyID, err.Error()))
+	}
+}
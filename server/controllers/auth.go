@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/go-github/github"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"golang.org/x/oauth2"
+	githubOAuth "golang.org/x/oauth2/github"
+	"gopkg.in/inconshreveable/log15.v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sessionCollection = "sessions"
+
+const defaultSessionHours = 7 * 24
+
+// how long the GitHub permission of a user on a repository is cached
+const repoPermissionCacheTTL = 5 * time.Minute
+
+var ErrInvalidOAuthCode = errors.New("invalid OAuth code")
+var ErrSessionNotFound = errors.New("session not found")
+
+type AuthCtrl struct {
+	Config      *config.Configuration `inject:""`
+	Mongo       *mongo.Client         `inject:""`
+	GHClient    *github.Client        `inject:""`
+	Coll        *mongo.Collection
+	TokenColl   *mongo.Collection
+	oauthConfig *oauth2.Config
+	logger      log15.Logger
+
+	permissionsMu sync.Mutex
+	permissions   map[string]repoPermission
+}
+
+type repoPermission struct {
+	admin     bool
+	fetchedOn time.Time
+}
+
+func (ac *AuthCtrl) Init() error {
+	logger, err := misc.GetLogger("auth-ctrl")
+	if err != nil {
+		return err
+	}
+	ac.logger = logger
+	ac.permissions = map[string]repoPermission{}
+
+	oauthConf := ac.Config.HTTP.OAuth
+	ac.oauthConfig = &oauth2.Config{
+		ClientID:     oauthConf.ClientID,
+		ClientSecret: oauthConf.ClientSecret,
+		RedirectURL:  oauthConf.CallbackURL,
+		Endpoint:     githubOAuth.Endpoint,
+	}
+
+	ac.Coll = ac.Mongo.Database(ac.Config.DB.DBName).Collection(sessionCollection)
+	ac.TokenColl = ac.Mongo.Database(ac.Config.DB.DBName).Collection(tokenCollection)
+
+	// expired sessions are removed by MongoDB
+	expiryIndexName := "expires_on"
+	var expireAfter int32 = 0
+	_, err = ac.Coll.Indexes().CreateOne(DefaultCtx(), mongo.IndexModel{
+		Keys:    bsonx.Doc{{Key: "expires_on", Value: bsonx.Int32(int32(1))}},
+		Options: &options.IndexOptions{Name: &expiryIndexName, ExpireAfterSeconds: &expireAfter},
+	})
+	if err != nil {
+		return errors.Wrap(err, "(auth) couldn't create session expiry index")
+	}
+	return ac.initTokenIndexes()
+}
+
+// Enabled tells whether users sign in through GitHub. If not, the admin API isn't restricted by roles.
+func (ac *AuthCtrl) Enabled() bool {
+	return ac.Config.HTTP.OAuth.Enabled
+}
+
+// PublicReadOnly tells whether anonymous visitors may read bounties and repositories.
+func (ac *AuthCtrl) PublicReadOnly() bool {
+	return ac.Config.HTTP.PublicReadOnly
+}
+
+// AuthCodeURL returns the URL of GitHub's authorization page, the state is echoed back to the callback.
+func (ac *AuthCtrl) AuthCodeURL(state string) string {
+	return ac.oauthConfig.AuthCodeURL(state)
+}
+
+// SignIn exchanges the code GitHub passed to the callback for the user's identity and opens a session.
+// The returned token identifies the session, only its hash is stored.
+func (ac *AuthCtrl) SignIn(code string) (string, *models.Session, error) {
+	ctx := context.Background()
+	token, err := ac.oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return "", nil, errors.Wrapf(ErrInvalidOAuthCode, "couldn't exchange code: %s", err.Error())
+	}
+
+	// the user's access token is only used to determine its identity and isn't kept
+	userClient := github.NewClient(ac.oauthConfig.Client(ctx, token))
+	user, _, err := userClient.Users.Get(ctx, "")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "(auth) couldn't fetch signed in GitHub user")
+	}
+
+	sessionToken, err := newSecretToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	hours := ac.Config.HTTP.OAuth.SessionHours
+	if hours <= 0 {
+		hours = defaultSessionHours
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:        hashToken(sessionToken),
+		UserID:    user.GetID(),
+		Login:     user.GetLogin(),
+		AvatarURL: user.GetAvatarURL(),
+		CreatedOn: now,
+		ExpiresOn: now.Add(time.Duration(hours) * time.Hour),
+	}
+	if _, err := ac.Coll.InsertOne(DefaultCtx(), session); err != nil {
+		return "", nil, errors.Wrapf(err, "(auth) couldn't store session of user '%s'", session.Login)
+	}
+	ac.logger.Info(fmt.Sprintf("user '%s' signed in", session.Login))
+	return sessionToken, session, nil
+}
+
+// GetSession returns the unexpired session identified by the given token.
+func (ac *AuthCtrl) GetSession(token string) (*models.Session, error) {
+	session := &models.Session{}
+	filter := bson.D{{"_id", hashToken(token)}, {"expires_on", bson.D{{"$gt", time.Now()}}}}
+	if err := ac.Coll.FindOne(DefaultCtx(), filter).Decode(session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSessionNotFound
+		}
+		return nil, errors.Wrap(err, "(auth) couldn't load session")
+	}
+	return session, nil
+}
+
+// SignOut ends the session identified by the given token.
+func (ac *AuthCtrl) SignOut(token string) error {
+	_, err := ac.Coll.DeleteOne(DefaultCtx(), bson.D{{"_id", hashToken(token)}})
+	return errors.Wrap(err, "(auth) couldn't delete session")
+}
+
+// User returns the user of the given session with its platform wide role.
+func (ac *AuthCtrl) User(session *models.Session) *models.User {
+	role := models.RoleViewer
+	if ac.IsPlatformAdmin(session.UserID) {
+		role = models.RolePlatformAdmin
+	}
+	return &models.User{ID: session.UserID, Login: session.Login, AvatarURL: session.AvatarURL, Role: role}
+}
+
+// IsPlatformAdmin tells whether the given GitHub user is configured as a platform admin.
+func (ac *AuthCtrl) IsPlatformAdmin(userID int64) bool {
+	for _, id := range ac.Config.HTTP.OAuth.PlatformAdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRepositoryManager tells whether the given GitHub user may manage the given repository,
+// which platform admins and the admins of the repository on GitHub may.
+func (ac *AuthCtrl) IsRepositoryManager(userID int64, login string, owner string, name string) (bool, error) {
+	if ac.IsPlatformAdmin(userID) {
+		return true, nil
+	}
+
+	key := strings.ToLower(fmt.Sprintf("%s/%s/%s", login, owner, name))
+	ac.permissionsMu.Lock()
+	perm, ok := ac.permissions[key]
+	ac.permissionsMu.Unlock()
+	if ok && time.Since(perm.fetchedOn) < repoPermissionCacheTTL {
+		return perm.admin, nil
+	}
+
+	level, res, err := ac.GHClient.Repositories.GetPermissionLevel(DefaultCtx(), owner, name, login)
+	if err != nil {
+		// users who aren't collaborators of the repository have no permission level
+		if res == nil || res.StatusCode != 404 {
+			return false, errors.Wrapf(err, "(auth) couldn't fetch permission of '%s' on repo '%s/%s'", login, owner, name)
+		}
+	}
+	admin := err == nil && level.GetPermission() == "admin"
+
+	ac.permissionsMu.Lock()
+	ac.permissions[key] = repoPermission{admin: admin, fetchedOn: time.Now()}
+	ac.permissionsMu.Unlock()
+	return admin, nil
+}
+
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "(auth) couldn't generate token")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
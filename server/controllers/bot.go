@@ -5,15 +5,18 @@ import (
 	"fmt"
 	"github.com/google/go-github/github"
 	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/consts"
 	"github.com/iotaledger/iota.go/guards"
 	"github.com/luca-moser/iota-bounty-platform/server/misc"
 	"github.com/luca-moser/iota-bounty-platform/server/models"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	gwb "gopkg.in/go-playground/webhooks.v5/github"
 	"gopkg.in/inconshreveable/log15.v2"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,7 +27,9 @@ This issue has been linked with the bounty platform.
 Help rising the incentive to solve this issue by sending iota tokens to the following address:
 [%s](https://thetangle.org/address/%s)
 
-> Please note that the tokens you send to the address can not be recovered
+> If the bounty gets cancelled, the remaining tokens are refunded proportionally to contributors
+> who registered a refund address, the rest goes to the platform's treasury. Register your refund address by commenting:
+> ` + "`refund contribution <bundle_hash> to <your_address>`" + `
 
 Important:
 **If you move this repository make sure to await for the bounty platform to synchronize the repository state before releasing a bounty.**
@@ -33,24 +38,151 @@ Important:
 Release the bounty by issuing following comment:
 ` + "`release bounty to @<bounty_receiver_name>`" + `
 
+#### Releasing a milestone (as a repository admin)
+If the issue's task list defines milestones with a share, e.g. ` + "`- [ ] backend (40%%)`" + ` or ` + "`- [ ] docs (500 Mi)`" + `,
+a single milestone can be released (the rest stays in the bounty) by issuing following comment:
+` + "`release milestone <number> to @<milestone_receiver_name>`" + `
+
+#### Setting a deadline (as a repository admin)
+Set or extend the deadline of the bounty (YYYY-MM-DD) by issuing following comment:
+` + "`set deadline <date>`" + `
+Once the deadline has passed, the bounty expires and can no longer be released unless the deadline is extended.
+
+#### Cancelling the bounty (as a repository admin)
+Cancel the bounty and refund the contributors by issuing following comment:
+` + "`cancel bounty`" + `
+
 #### Receiving the bounty (as the issue solver)
 Simply create a comment with your IOTA address (+checksum, must be 90 chars long!) to which to receive the tokens to after the above 'release comment' has been posted.
 `
 
 const bountyIsReleasedMessage = `
-The bounty of %d iotas has been released.
+The bounty of %s has been released.
 @%s please post your receiving IOTA address as a comment.
 The receiver of the bounty can still be changed by issuing the bounty release command again.
 `
 
 const bountyReceiverHasBeenUpdatedMessage = `
-The receiver of the bounty of %d iotas has been updated to %s.
+The receiver of the bounty of %s has been updated to %s.
 @%s please post your receiving IOTA address as a comment.
 The receiver of the bounty can still be changed by issuing the bounty release command again.
 `
 
 const bountySentMessage = `
-Hey @%s, the bounty of %d iotas has been sent off. Bundle: [%s](https://thetangle.org/bundle/%s).
+Hey @%s, the bounty of %s has been sent off. Bundle: [%s](https://thetangle.org/bundle/%s).
+%s` + spentPoolAddressNotice
+
+const spentPoolAddressNotice = `
+**The bounty address is now spent, please don't send any further tokens to it.**
+`
+
+const bountySentBreakdownMessage = `
+| Part | iotas |
+|:---|---:|
+| Receiver | %d |
+| Platform fee | %d |
+| Maintainer tip | %d |
+| **Total** | **%d** |
+`
+
+const milestoneReleasedMessage = `
+Milestone %d (%s) of the bounty has been released, its share is %s.
+@%s please post your receiving IOTA address as a comment.
+The receiver of the milestone can still be changed by issuing the milestone release command again.
+`
+
+const milestoneSentMessage = `
+Hey @%s, the share of milestone %d (%s) of %s has been sent off. Bundle: [%s](https://thetangle.org/bundle/%s).
+%sThe rest of the funds remains in the bounty.
+%s`
+
+const milestoneNewPoolAddressMessage = `
+**The previous bounty address is now spent, further tokens must be sent to the new address:** [%s](https://thetangle.org/address/%s)
+`
+
+const poolAddressRenewedMessage = `
+The new bounty address has been allocated.
+**The previous bounty address is spent, further tokens must be sent to the new address:** [%s](https://thetangle.org/address/%s)
+`
+
+const milestoneSpentPoolAddressMessage = `
+**The bounty address is now spent, please don't send any further tokens to it.** The new address is shown on the platform once it has been allocated.
+`
+
+const bountyTransferConfirmedMessage = `
+The transfer of the bounty has been confirmed by the network. Bundle: [%s](https://thetangle.org/bundle/%s).
+`
+
+const milestoneTransferConfirmedMessage = `
+The transfer of the milestone has been confirmed by the network. Bundle: [%s](https://thetangle.org/bundle/%s).
+`
+
+const bountyRefundConfirmedMessage = `
+The refund of the bounty has been confirmed by the network. Bundle: [%s](https://thetangle.org/bundle/%s).
+`
+
+const bountyRefundedMessage = `
+The bounty has been cancelled and the remaining %s have been refunded. Bundle: [%s](https://thetangle.org/bundle/%s).
+
+| Contribution | Refund address | Refunded iotas |
+|:---|:---|---:|
+%s`
+
+const bountyRefundedRowMessage = "| %s | %s | %d |\n"
+
+const payoutAwaitingApprovalMessage = `
+The payout of %s to [%s](https://thetangle.org/address/%s) exceeds the approval threshold of %s.
+It is sent off once a second repository admin (not the one who released the bounty) approves it by issuing following comment:
+` + "`approve payout`" + `
+The payout can be rejected with ` + "`reject payout`" + ` and expires on %s if it isn't approved.
+`
+
+const payoutExpiredMessage = `
+The payout of %d iotas wasn't approved in time and has expired. No tokens have left the bounty address.
+Please request the payout again by posting **your** address again.
+`
+
+const payoutRejectedMessage = `
+The payout of %d iotas has been withdrawn (%s). No tokens have left the bounty address.
+Please request the payout again by posting **your** address again.
+`
+
+const lateDepositWarningMessage = `
+A spent bounty address received tokens after it was paid out from and now holds %s.
+**Spent addresses put the tokens sent to them at risk. Please don't send any further tokens to them.**
+A repository admin can sweep the tokens to the receiver of the bounty or to the platform's treasury by issuing following comment:
+` + "`sweep bounty to <receiver|treasury>`" + `
+`
+
+const lateDepositsSweptMessage = `
+The %s which arrived on the spent bounty addresses have been sent to the %s (%s). Bundle: [%s](https://thetangle.org/bundle/%s).
+`
+
+const lateDepositsSweepConfirmedMessage = `
+The sweep of the late deposits has been confirmed by the network. Bundle: [%s](https://thetangle.org/bundle/%s).
+`
+
+const bountyDeadlineSetMessage = `
+The deadline of this bounty has been set to %s.
+`
+
+const bountyDeadlineReminderMessage = `
+Reminder: the deadline of this bounty is %s (in %s).
+After the deadline the bounty expires and can no longer be released.
+`
+
+const bountyExpiredMessage = `
+The deadline of this bounty (%s) has passed and the bounty has expired.
+Release commands are refused until a repository admin extends the deadline with ` + "`set deadline <date>`" + `.
+`
+
+const fundingNoticeMessage = `
+The bounty increased by %s to %s.
+`
+
+const contributionMatchedMessage = `
+%s matched the contribution of %s (bundle [%s](https://thetangle.org/bundle/%s)) with %s. Bundle: [%s](https://thetangle.org/bundle/%s).
+Campaign progress: %s of %s matched, the campaign ends on %s.
 `
 
 const bountyDeletedMessage = `
@@ -82,8 +214,11 @@ type Bot struct {
 	GHClient   *github.Client        `inject:""`
 	RepoCtrl   *RepoCtrl             `inject:""`
 	BountyCtrl *BountyCtrl           `inject:""`
+	PriceCtrl  *PriceCtrl            `inject:""`
 	Mongo      *mongo.Client         `inject:""`
 	logger     log15.Logger
+	// when the last reconciliation finished
+	lastReconciliation time.Time
 }
 
 func (b *Bot) Init() error {
@@ -97,8 +232,10 @@ func (b *Bot) Init() error {
 }
 
 func (b *Bot) Run() {
+	b.ResumePayouts()
 	b.InstallWebHooks()
 	go b.ListenToWebHooks()
+	go b.TrackPayouts()
 	for {
 		b.Sync()
 		time.Sleep(time.Duration(b.Config.GitHub.SyncIntervalSeconds) * time.Second)
@@ -109,7 +246,112 @@ func (b *Bot) Sync() {
 	processMu.Lock()
 	defer processMu.Unlock()
 	b.RepoCtrl.SyncRepositories()
+	// the campaign balances must be up to date before contributions are matched
+	b.BountyCtrl.SyncCampaigns()
 	b.BountyCtrl.SyncBounties()
+	b.BountyCtrl.ExpirePayouts()
+
+	// reconcile right after the sync, so that the stored contributions are up to date
+	interval := b.Config.Reconciliation.IntervalMinutes
+	if interval > 0 && time.Since(b.lastReconciliation) >= time.Duration(interval)*time.Minute {
+		if _, err := b.reconcile(); err != nil {
+			b.logger.Error(fmt.Sprintf("reconciliation failed: %s", err.Error()))
+		}
+	}
+}
+
+// Reconcile runs the reconciliation of the bounties with the tangle outside of the sync loop.
+func (b *Bot) Reconcile() (*models.ReconciliationReport, error) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.reconcile()
+}
+
+func (b *Bot) reconcile() (*models.ReconciliationReport, error) {
+	report, err := b.BountyCtrl.Reconcile()
+	if err != nil {
+		return nil, err
+	}
+	b.lastReconciliation = report.FinishedOn
+	for _, discrepancy := range report.Discrepancies {
+		b.logger.Warn(fmt.Sprintf("reconciliation: bounty %d has discrepancy %s (stored: %s, on tangle: %s)",
+			discrepancy.BountyID, discrepancy.Kind, discrepancy.Stored, discrepancy.OnTangle))
+	}
+	b.logger.Info(fmt.Sprintf("reconciled %d bounties, found %d discrepancies", report.BountiesChecked, len(report.Discrepancies)))
+	return report, nil
+}
+
+// ResumePayouts finalizes payouts which were interrupted by a crash before any new comment is handled.
+func (b *Bot) ResumePayouts() {
+	processMu.Lock()
+	defer processMu.Unlock()
+	b.BountyCtrl.ResumePayouts()
+	b.BountyCtrl.ResumeCampaignTransfers()
+}
+
+// WithdrawCampaign sends the leftover funds of an ended campaign to its refund address.
+func (b *Bot) WithdrawCampaign(id primitive.ObjectID) (*models.CampaignTransfer, error) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.BountyCtrl.WithdrawCampaign(id)
+}
+
+// CancelBounty cancels the given bounty and refunds its balance to the contributors.
+func (b *Bot) CancelBounty(id int64, actor *models.Actor) ([]models.Refund, error) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.BountyCtrl.Cancel(id, actor)
+}
+
+// DeleteBounty deletes the given bounty, refunding its balance if it wasn't paid out yet.
+func (b *Bot) DeleteBounty(id int64, actor *models.Actor) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.BountyCtrl.Delete(id, actor)
+}
+
+// DeleteRepository deletes the given repository and its bounties.
+func (b *Bot) DeleteRepository(id int64, actor *models.Actor) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.RepoCtrl.Delete(id, actor)
+}
+
+// SweepLateDeposits sends the late deposits of the given bounty to the given target.
+func (b *Bot) SweepLateDeposits(id int64, target models.SweepTarget, actor *models.Actor) (*models.Payout, error) {
+	processMu.Lock()
+	defer processMu.Unlock()
+	bounty, err := b.BountyCtrl.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return b.BountyCtrl.SweepLateDeposits(bounty, target, actor)
+}
+
+// ApprovePayout approves the given payout awaiting approval and sends it off.
+func (b *Bot) ApprovePayout(payout *models.Payout, actor *models.Actor) error {
+	processMu.Lock()
+	defer processMu.Unlock()
+	return b.BountyCtrl.ApprovePayout(payout, actor)
+}
+
+const defaultConfirmationPollSeconds = 60
+
+// TrackPayouts periodically checks whether sent payouts got confirmed
+// and settles the payouts and campaign transfers which are left pending.
+func (b *Bot) TrackPayouts() {
+	interval := b.Config.Payouts.ConfirmationPollSeconds
+	if interval <= 0 {
+		interval = defaultConfirmationPollSeconds
+	}
+	for {
+		time.Sleep(time.Duration(interval) * time.Second)
+		processMu.Lock()
+		b.BountyCtrl.ResumePayouts()
+		b.BountyCtrl.ResumeCampaignTransfers()
+		b.BountyCtrl.TrackPayouts()
+		processMu.Unlock()
+	}
 }
 
 func (b *Bot) ListenToWebHooks() {
@@ -147,8 +389,8 @@ func (b *Bot) ListenToWebHooks() {
 				return
 			}
 
-			// don't handle anything on an already transferred bounty
-			if bounty.State == models.BountyStateTransferred {
+			// don't handle anything on an already transferred or refunded bounty except sweeping late deposits
+			if bounty.Settled() && !strings.HasPrefix(strings.TrimSpace(t.Comment.Body), sweepBountyCmd) {
 				return
 			}
 
@@ -274,7 +516,7 @@ func (b *Bot) PostBountyReleasedMessage(owner string, repo string, bounty *model
 	}
 
 	comment := &github.IssueComment{
-		Body: github.String(fmt.Sprintf(bountyIsReleasedMessage, bounty.Balance, receiver.GetLogin())),
+		Body: github.String(fmt.Sprintf(bountyIsReleasedMessage, b.PriceCtrl.FormatIotas(bounty.Balance), receiver.GetLogin())),
 	}
 	_, _, err = b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
 	if err != nil {
@@ -291,7 +533,7 @@ func (b *Bot) PostBountyReceiverUpdatedMessage(owner string, repo string, bounty
 	}
 
 	comment := &github.IssueComment{
-		Body: github.String(fmt.Sprintf(bountyReceiverHasBeenUpdatedMessage, bounty.Balance, receiver.GetLogin(), receiver.GetLogin())),
+		Body: github.String(fmt.Sprintf(bountyReceiverHasBeenUpdatedMessage, b.PriceCtrl.FormatIotas(bounty.Balance), receiver.GetLogin(), receiver.GetLogin())),
 	}
 	_, _, err = b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
 	if err != nil {
@@ -301,14 +543,20 @@ func (b *Bot) PostBountyReceiverUpdatedMessage(owner string, repo string, bounty
 	return nil
 }
 
-func (b *Bot) PostBountySentMessage(owner string, repo string, bounty *models.Bounty, value uint64, bundleHash string) error {
+func (b *Bot) PostBountySentMessage(owner string, repo string, bounty *models.Bounty, split *models.PayoutSplit, bundleHash string) error {
 	receiver, _, err := b.GHClient.Users.GetByID(DefaultCtx(), bounty.ReceiverID)
 	if err != nil {
 		return err
 	}
 
+	// only show the breakdown if anything else than the receiver got a share
+	var breakdown string
+	if split.Fee > 0 || split.Tip > 0 {
+		breakdown = fmt.Sprintf(bountySentBreakdownMessage, split.Receiver, split.Fee, split.Tip, split.Total)
+	}
+
 	comment := &github.IssueComment{
-		Body: github.String(fmt.Sprintf(bountySentMessage, receiver.GetLogin(), value, bundleHash, bundleHash)),
+		Body: github.String(fmt.Sprintf(bountySentMessage, receiver.GetLogin(), b.PriceCtrl.FormatIotas(split.Receiver), bundleHash, bundleHash, breakdown)),
 	}
 	_, _, err = b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
 	if err != nil {
@@ -318,7 +566,261 @@ func (b *Bot) PostBountySentMessage(owner string, repo string, bounty *models.Bo
 	return nil
 }
 
+func (b *Bot) PostMilestoneReleasedMessage(owner string, repo string, bounty *models.Bounty, number int, receiverLogin string) error {
+	milestone := &bounty.Milestones[number-1]
+	share := b.PriceCtrl.FormatIotas(milestoneShare(bounty, milestone, bounty.Balance))
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(milestoneReleasedMessage, number, milestone.Title, share, receiverLogin)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted milestone released message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostMilestoneSentMessage(owner string, repo string, bounty *models.Bounty, payout *models.Payout) error {
+	milestone := &bounty.Milestones[payout.Milestone-1]
+	receiver, _, err := b.GHClient.Users.GetByID(DefaultCtx(), milestone.ReceiverID)
+	if err != nil {
+		return err
+	}
+
+	// only show the breakdown if anything else than the receiver got a share
+	split := payout.Split
+	var breakdown string
+	if split.Fee > 0 || split.Tip > 0 {
+		breakdown = fmt.Sprintf(bountySentBreakdownMessage, split.Receiver, split.Fee, split.Tip, split.Total)
+	}
+
+	poolAddrNotice := fmt.Sprintf(milestoneNewPoolAddressMessage, bounty.PoolAddress, bounty.PoolAddress)
+	if bounty.PoolAddressSpent {
+		poolAddrNotice = milestoneSpentPoolAddressMessage
+	}
+
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(milestoneSentMessage, receiver.GetLogin(), payout.Milestone, milestone.Title,
+			b.PriceCtrl.FormatIotas(split.Receiver), payout.BundleHash, payout.BundleHash, breakdown, poolAddrNotice)),
+	}
+	_, _, err = b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted milestone sent message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostPayoutConfirmedMessage(owner string, repo string, bounty *models.Bounty, payout *models.Payout) error {
+	msg := bountyTransferConfirmedMessage
+	switch payout.Kind {
+	case models.PayoutKindMilestone:
+		msg = milestoneTransferConfirmedMessage
+	case models.PayoutKindRefund:
+		msg = bountyRefundConfirmedMessage
+	case models.PayoutKindSweep:
+		msg = lateDepositsSweepConfirmedMessage
+	}
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(msg, payout.BundleHash, payout.BundleHash)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted payout confirmed message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostBountyRefundedMessage(owner string, repo string, bounty *models.Bounty, refunds []models.Refund, value uint64, bundleHash string) error {
+	var msg string
+	if len(refunds) == 0 {
+		msg = bountyRefundedNoFundsMessage
+	} else {
+		var rows string
+		for _, refund := range refunds {
+			contribution := refund.BundleHash
+			if refund.Treasury {
+				contribution = "unregistered contributions (treasury)"
+			}
+			rows += fmt.Sprintf(bountyRefundedRowMessage, contribution, refund.Address, refund.Value)
+		}
+		msg = fmt.Sprintf(bountyRefundedMessage, b.PriceCtrl.FormatIotas(value), bundleHash, bundleHash, rows) + spentPoolAddressNotice
+	}
+
+	comment := &github.IssueComment{Body: github.String(msg)}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted bounty refunded message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostPayoutAwaitingApprovalMessage(owner string, repo string, bounty *models.Bounty, payout *models.Payout) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(payoutAwaitingApprovalMessage, b.PriceCtrl.FormatIotas(payout.Value), payout.ReceiverAddress, payout.ReceiverAddress,
+			b.PriceCtrl.FormatIotas(b.Config.Payouts.ApprovalThreshold), formatDeadline(payout.ExpiresOn))),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted payout awaiting approval message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostPayoutWithdrawnMessage(owner string, repo string, bounty *models.Bounty, payout *models.Payout) error {
+	msg := fmt.Sprintf(payoutRejectedMessage, payout.Value, payout.Error)
+	if payout.State == models.PayoutStateExpired {
+		msg = fmt.Sprintf(payoutExpiredMessage, payout.Value)
+	}
+	comment := &github.IssueComment{Body: github.String(msg)}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted payout withdrawn message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostPoolAddressRenewedMessage(owner string, repo string, bounty *models.Bounty) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(poolAddressRenewedMessage, bounty.PoolAddress, bounty.PoolAddress)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted pool address renewed message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostLateDepositWarningMessage(owner string, repo string, bounty *models.Bounty) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(lateDepositWarningMessage, b.PriceCtrl.FormatIotas(bounty.LateBalance))),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted late deposit warning message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostLateDepositsSweptMessage(owner string, repo string, bounty *models.Bounty, payout *models.Payout) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(lateDepositsSweptMessage, b.PriceCtrl.FormatIotas(payout.Value), payout.SweepTarget, payout.ReceiverAddress, payout.BundleHash, payout.BundleHash)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted late deposits swept message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostFundingNoticeMessage(owner string, repo string, bounty *models.Bounty, from uint64, to uint64) (int64, error) {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(fundingNoticeMessage, b.PriceCtrl.FormatIotas(to-from), b.PriceCtrl.FormatIotas(to))),
+	}
+	posted, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return 0, err
+	}
+	b.logger.Info(fmt.Sprintf("posted funding notice message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return posted.GetID(), nil
+}
+
+func (b *Bot) UpdateFundingNoticeMessage(owner string, repo string, bounty *models.Bounty, commentID int64, from uint64, to uint64) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(fundingNoticeMessage, b.PriceCtrl.FormatIotas(to-from), b.PriceCtrl.FormatIotas(to))),
+	}
+	_, _, err := b.GHClient.Issues.EditComment(DefaultCtx(), owner, repo, commentID, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("updated funding notice message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostContributionMatchedMessage(owner string, repo string, bounty *models.Bounty, campaign *models.Campaign, contribution *models.Contribution, transfer *models.CampaignTransfer) error {
+	end := campaign.End()
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(contributionMatchedMessage,
+			campaign.Sponsor, b.PriceCtrl.FormatIotas(contribution.Value), contribution.BundleHash, contribution.BundleHash,
+			b.PriceCtrl.FormatIotas(transfer.Value), transfer.BundleHash, transfer.BundleHash,
+			b.PriceCtrl.FormatIotas(campaign.Matched), b.PriceCtrl.FormatIotas(campaign.Cap), formatDeadline(&end),
+		)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted contribution matched message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostBountyDeadlineReminderMessage(owner string, repo string, bounty *models.Bounty) error {
+	remaining := time.Until(*bounty.Deadline).Round(time.Hour)
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(bountyDeadlineReminderMessage, formatDeadline(bounty.Deadline), remaining)),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted bounty deadline reminder message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func (b *Bot) PostBountyExpiredMessage(owner string, repo string, bounty *models.Bounty) error {
+	comment := &github.IssueComment{
+		Body: github.String(fmt.Sprintf(bountyExpiredMessage, formatDeadline(bounty.Deadline))),
+	}
+	_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), owner, repo, bounty.IssueNumber, comment)
+	if err != nil {
+		return err
+	}
+	b.logger.Info(fmt.Sprintf("posted bounty expired message on: %s/%s issue %d - %s", owner, repo, bounty.IssueNumber, bounty.Title))
+	return nil
+}
+
+func formatDeadline(deadline *time.Time) string {
+	return deadline.UTC().Format("2006-01-02 15:04 MST")
+}
+
+// isRepoAdmin checks whether the given GitHub user has admin permissions on the given repository.
+func (b *Bot) isRepoAdmin(repo *models.Repository, userID int64) (bool, error) {
+	collaborators, _, err := b.GHClient.Repositories.ListCollaborators(DefaultCtx(), repo.Owner, repo.Name, &github.ListCollaboratorsOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	for _, collaborator := range collaborators {
+		if collaborator.GetID() == userID {
+			admin, has := collaborator.GetPermissions()["admin"]
+			return has && admin, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *Bot) postComment(repo *models.Repository, bounty *models.Bounty, msg string) {
+	comment := &github.IssueComment{Body: github.String(msg)}
+	if _, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), repo.Owner, repo.Name, bounty.IssueNumber, comment); err != nil {
+		b.logger.Info(fmt.Sprintf("unable to write comment on %s/%s issue %d: %s", repo.Owner, repo.Name, bounty.IssueNumber, err.Error()))
+	}
+}
+
 var releaseBountyCmd = "release bounty to @"
+var releaseMilestoneCmd = "release milestone "
+var cancelBountyCmd = "cancel bounty"
+var setDeadlineCmd = "set deadline "
+var refundContributionCmd = "refund contribution "
+var sweepBountyCmd = "sweep bounty to "
+var approvePayoutCmd = "approve payout"
+var rejectPayoutCmd = "reject payout"
 
 var receiverNameInvalidMessage = `
 Couldn't read out receiver name from the bounty release command.
@@ -330,17 +832,35 @@ Couldn't find the user specified in the bounty release command.
 Please make sure you use the appropriate syntax of: 
 ` + "`release bounty to @<bounty_receiver_name>`"
 
+var milestoneCommandInvalidMessage = `
+Couldn't read out the milestone or receiver name from the milestone release command.
+Please make sure you use the appropriate syntax of:
+` + "`release milestone <number> to @<milestone_receiver_name>`"
+
+var failedToReleaseMilestoneMessage = `
+Couldn't release the milestone: %s
+`
+
+var milestoneShareExceedsBalanceMessage = `
+The share of the milestone exceeds the funds of the bounty, therefore nothing has been sent.
+Please post **your** address again once there are enough funds on the bounty address.
+`
+
 var releaseCommandIssuerIsNotRepoAdminMessage = `
 Only the repository admins are allowed to issue bounty release commands.
 `
 
 var failedToTransferBountyErrorMessage = `
 Unfortunately an error occurred while sending the bounty to your address.
-Please reinitiate the sending by posting your address again.
+No tokens have left the bounty address, please reinitiate the sending by posting your address again.
 
 Error message: %s
 `
 
+var payoutAlreadyInFlightMessage = `
+A payout of this bounty was already sent off or is still being processed, therefore no further transfer is made.
+`
+
 var bountyAddressHasNoFunds = `
 Unfortunately it seems that the bounty was released but there are no funds on the bounty address.
 Please reinitiate the sending by posting **your** address again once there are funds on the address.
@@ -348,6 +868,82 @@ Please reinitiate the sending by posting **your** address again once there are f
 
 var postedAddrHasInvalidChecksumMessage = `The posted message has an invalid checksum.`
 
+var deadlineCommandIssuerIsNotRepoAdminMessage = `
+Only the repository admins are allowed to set the deadline of a bounty.
+`
+
+var deadlineInvalidMessage = `
+Couldn't set the deadline: %s
+Please make sure you use the appropriate syntax of:
+` + "`set deadline <YYYY-MM-DD>`"
+
+var bountyExpiredReleaseRefusedMessage = `
+The bounty has expired and can't be released. A repository admin must first extend the deadline with
+` + "`set deadline <YYYY-MM-DD>`"
+
+var cancelCommandIssuerIsNotRepoAdminMessage = `
+Only the repository admins are allowed to cancel bounties.
+`
+
+var failedToCancelBountyErrorMessage = `
+Unfortunately an error occurred while cancelling the bounty and refunding its contributors.
+
+Error message: %s
+`
+
+var bountyRefundedNoFundsMessage = `
+The bounty has been cancelled. There were no funds on the bounty address to refund.
+`
+
+var refundCommandInvalidMessage = `
+Couldn't read out the contribution or refund address from the refund command.
+Please make sure you use the appropriate syntax of:
+` + "`refund contribution <bundle_hash> to <your_address>`" + ` (the address must include the checksum)
+`
+
+var refundAddressRegisteredMessage = `
+The refund address for contribution %s has been registered.
+`
+
+var failedToRegisterRefundAddressMessage = `
+Couldn't register the refund address: %s
+`
+
+var payoutAlreadyAwaitingApprovalMessage = `
+A payout of this bounty is already awaiting approval. A repository admin can reject it with ` + "`reject payout`" + `
+before a new payout can be requested.
+`
+
+var approvalCommandIssuerIsNotRepoAdminMessage = `
+Only the repository admins are allowed to approve or reject payouts.
+`
+
+var noPayoutAwaitingApprovalMessage = `
+There is no payout awaiting approval on this bounty.
+`
+
+var failedToApprovePayoutMessage = `
+Couldn't approve the payout: %s
+`
+
+var sweepCommandIssuerIsNotRepoAdminMessage = `
+Only the repository admins are allowed to sweep late deposits.
+`
+
+var failedToSweepLateDepositsMessage = `
+Couldn't sweep the late deposits: %s
+`
+
+// commentActor returns the author of the given comment as the actor of the changes made by its command.
+func commentActor(issuePayload gwb.IssueCommentPayload) *models.Actor {
+	return &models.Actor{
+		Source:     models.ActorSourceGitHub,
+		GitHubID:   issuePayload.Sender.ID,
+		Login:      issuePayload.Sender.Login,
+		CommentURL: issuePayload.Comment.HTMLURL,
+	}
+}
+
 func (b *Bot) HandleIssueComment(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository) {
 	processMu.Lock()
 	defer processMu.Unlock()
@@ -361,23 +957,179 @@ func (b *Bot) HandleIssueComment(issuePayload gwb.IssueCommentPayload, bounty *m
 		return
 	}
 
-	if !strings.HasPrefix(comment, releaseBountyCmd) {
+	switch {
+	case strings.HasPrefix(comment, releaseBountyCmd):
+		b.HandleBountyRelease(issuePayload, bounty, repo, comment)
+	case strings.HasPrefix(comment, releaseMilestoneCmd):
+		b.HandleMilestoneRelease(issuePayload, bounty, repo, comment)
+	case strings.HasPrefix(comment, setDeadlineCmd):
+		b.HandleSetDeadline(issuePayload, bounty, repo, comment)
+	case comment == cancelBountyCmd:
+		b.HandleBountyCancel(issuePayload, bounty, repo)
+	case strings.HasPrefix(comment, refundContributionCmd):
+		b.HandleRefundAddressRegistration(issuePayload, bounty, repo, comment)
+	case strings.HasPrefix(comment, sweepBountyCmd):
+		b.HandleLateDepositsSweep(issuePayload, bounty, repo, comment)
+	case comment == approvePayoutCmd:
+		b.HandlePayoutApproval(issuePayload, bounty, repo, true)
+	case comment == rejectPayoutCmd:
+		b.HandlePayoutApproval(issuePayload, bounty, repo, false)
+	}
+}
+
+func (b *Bot) HandlePayoutApproval(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, approve bool) {
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		b.logger.Error("approval command issuer is not a repository admin")
+		b.postComment(repo, bounty, approvalCommandIssuerIsNotRepoAdminMessage)
+		return
+	}
+
+	payout, err := b.BountyCtrl.GetAwaitingPayoutOfBounty(bounty.ID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			b.postComment(repo, bounty, noPayoutAwaitingApprovalMessage)
+			return
+		}
+		b.logger.Error(fmt.Sprintf("unable to load payout awaiting approval: %s", err.Error()))
+		return
+	}
+
+	// the bot messages are posted by the controller
+	if !approve {
+		if err := b.BountyCtrl.RejectPayout(payout, commentActor(issuePayload)); err != nil {
+			b.logger.Error(fmt.Sprintf("failed to reject payout: %s", err.Error()))
+		}
 		return
 	}
 
-	b.HandleBountyRelease(issuePayload, bounty, repo, comment)
+	if err := b.BountyCtrl.ApprovePayout(payout, commentActor(issuePayload)); err != nil {
+		b.logger.Error(fmt.Sprintf("failed to approve payout: %s", err.Error()))
+		switch err {
+		// the withdrawal was already announced
+		case ErrApprovalExpired, ErrBountyBalanceChanged, ErrBountyExpired, ErrBountyAlreadySettled, ErrMilestoneNotReleased:
+		default:
+			b.postComment(repo, bounty, fmt.Sprintf(failedToApprovePayoutMessage, err.Error()))
+		}
+	}
+}
+
+func (b *Bot) HandleLateDepositsSweep(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		b.logger.Error("sweep command issuer is not a repository admin")
+		b.postComment(repo, bounty, sweepCommandIssuerIsNotRepoAdminMessage)
+		return
+	}
+
+	// the bot message is posted by the controller
+	target := models.SweepTarget(strings.TrimSpace(strings.TrimPrefix(comment, sweepBountyCmd)))
+	if _, err := b.BountyCtrl.SweepLateDeposits(bounty, target, commentActor(issuePayload), repo); err != nil {
+		b.logger.Error(fmt.Sprintf("failed to sweep late deposits: %s", err.Error()))
+		b.postComment(repo, bounty, fmt.Sprintf(failedToSweepLateDepositsMessage, err.Error()))
+	}
+}
+
+func (b *Bot) HandleSetDeadline(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		b.logger.Error("deadline command issuer is not a repository admin")
+		b.postComment(repo, bounty, deadlineCommandIssuerIsNotRepoAdminMessage)
+		return
+	}
+
+	deadline, err := misc.ParseDeadline(strings.TrimPrefix(comment, setDeadlineCmd))
+	if err == nil {
+		err = b.BountyCtrl.SetDeadline(bounty, deadline, commentActor(issuePayload))
+	}
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("failed to set bounty deadline: %s", err.Error()))
+		b.postComment(repo, bounty, fmt.Sprintf(deadlineInvalidMessage, err.Error()))
+		return
+	}
+
+	b.postComment(repo, bounty, fmt.Sprintf(bountyDeadlineSetMessage, formatDeadline(&deadline)))
+}
+
+func (b *Bot) HandleBountyCancel(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository) {
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		b.logger.Error("cancel command issuer is not a repository admin")
+		b.postComment(repo, bounty, cancelCommandIssuerIsNotRepoAdminMessage)
+		return
+	}
+
+	// the bot message is posted by the controller
+	if _, err := b.BountyCtrl.Cancel(bounty.ID, commentActor(issuePayload), repo); err != nil {
+		b.logger.Error(fmt.Sprintf("failed to cancel bounty: %s", err.Error()))
+		b.postComment(repo, bounty, fmt.Sprintf(failedToCancelBountyErrorMessage, err.Error()))
+	}
+}
+
+func (b *Bot) HandleRefundAddressRegistration(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
+	// refund contribution <bundle_hash> to <address>
+	args := strings.Fields(strings.TrimPrefix(comment, refundContributionCmd))
+	if len(args) != 3 || args[1] != "to" || !guards.IsTrytesOfExactLength(args[0], consts.HashTrytesSize) ||
+		!guards.IsAddressWithChecksum(args[2]) {
+		b.postComment(repo, bounty, refundCommandInvalidMessage)
+		return
+	}
+	bundleHash, addr := args[0], args[2]
+
+	if err := address.ValidChecksum(addr[:81], addr[81:]); err != nil {
+		b.postComment(repo, bounty, postedAddrHasInvalidChecksumMessage)
+		return
+	}
+
+	// repository admins are allowed to correct refund addresses
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
+	}
+
+	if err := b.BountyCtrl.RegisterRefundAddress(bounty, bundleHash, addr, commentActor(issuePayload), isAdmin); err != nil {
+		b.logger.Error(fmt.Sprintf("failed to register refund address: %s", err.Error()))
+		b.postComment(repo, bounty, fmt.Sprintf(failedToRegisterRefundAddressMessage, err.Error()))
+		return
+	}
+	b.postComment(repo, bounty, fmt.Sprintf(refundAddressRegisteredMessage, bundleHash))
 }
 
 func (b *Bot) HandleBountyTransfer(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, addr string) {
 
+	// receivers of a released milestone only get the share of the milestone
+	milestone, isMilestone := ReleasedMilestoneOf(bounty, issuePayload.Sender.ID)
+	isMilestone = isMilestone && bounty.State == models.BountyStateOpen
+
 	// check whether the bounty has actually been marked as released
-	if bounty.State != models.BountyStateReleased {
+	if bounty.State != models.BountyStateReleased && !isMilestone {
 		b.logger.Error(fmt.Sprintf("ignoring posted address as bounty has not been released"))
 		return
 	}
 
 	// check whether the correct receiver has sent the message
-	if issuePayload.Sender.ID != bounty.ReceiverID {
+	if issuePayload.Sender.ID != bounty.ReceiverID && !isMilestone {
 		b.logger.Error(fmt.Sprintf("ignoring posted address as the comment creator doesn't match the bounty receiver"))
 		return
 	}
@@ -393,49 +1145,104 @@ func (b *Bot) HandleBountyTransfer(issuePayload gwb.IssueCommentPayload, bounty
 		return
 	}
 
-	bndl, value, err := b.BountyCtrl.TransferBounty(bounty, addr)
+	var payout *models.Payout
+	var err error
+	if isMilestone {
+		payout, err = b.BountyCtrl.TransferMilestone(bounty, milestone, addr, commentActor(issuePayload))
+	} else {
+		payout, err = b.BountyCtrl.TransferBounty(bounty, addr, commentActor(issuePayload))
+	}
 	if err != nil {
 		b.logger.Error(fmt.Sprintf("failed to send bounty: %s", err.Error()))
+		switch err {
 		// bounty address is actually empty, so we can't send anything yet
-		if err == ErrBountyAddrEmpty {
-			comment := &github.IssueComment{Body: github.String(bountyAddressHasNoFunds)}
-			_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), repo.Owner, repo.Name, bounty.IssueNumber, comment)
-			if err != nil {
-				b.logger.Info(fmt.Sprintf("unable to write bounty address empty error message: %s", err.Error()))
-			}
-			return
+		case ErrBountyAddrEmpty:
+			b.postComment(repo, bounty, bountyAddressHasNoFunds)
+		case ErrMilestoneExceedsBalance:
+			b.postComment(repo, bounty, milestoneShareExceedsBalanceMessage)
+		// the bounty was already sent off, posting the address again must never send twice
+		case ErrPayoutAlreadySent, ErrPayoutPending:
+			b.postComment(repo, bounty, payoutAlreadyInFlightMessage)
+		case ErrPayoutAwaitingApproval:
+			b.postComment(repo, bounty, payoutAlreadyAwaitingApprovalMessage)
+		default:
+			b.postComment(repo, bounty, fmt.Sprintf(failedToTransferBountyErrorMessage, err.Error()))
 		}
+		return
+	}
 
-		comment := &github.IssueComment{Body: github.String(fmt.Sprintf(failedToTransferBountyErrorMessage, err.Error()))}
-		_, _, err := b.GHClient.Issues.CreateComment(DefaultCtx(), repo.Owner, repo.Name, bounty.IssueNumber, comment)
-		if err != nil {
-			b.logger.Info(fmt.Sprintf("unable to write bounty transfer failed error message: %s", err.Error()))
+	if payout.State == models.PayoutStateAwaitingApproval {
+		if err := b.PostPayoutAwaitingApprovalMessage(repo.Owner, repo.Name, bounty, payout); err != nil {
+			b.logger.Error(fmt.Sprintf("unable to post payout awaiting approval message: %s", err.Error()))
 		}
 		return
 	}
 
-	if err := b.PostBountySentMessage(repo.Owner, repo.Name, bounty, value, bndl[0].Bundle); err != nil {
+	if isMilestone {
+		err = b.PostMilestoneSentMessage(repo.Owner, repo.Name, bounty, payout)
+	} else {
+		err = b.PostBountySentMessage(repo.Owner, repo.Name, bounty, payout.Split, payout.BundleHash)
+	}
+	if err != nil {
 		b.logger.Error(fmt.Sprintf("unable to post bounty transffered message: %s", err.Error()))
 	}
 }
 
-func (b *Bot) HandleBountyRelease(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
-
-	collaborators, _, err := b.GHClient.Repositories.ListCollaborators(DefaultCtx(), repo.Owner, repo.Name, &github.ListCollaboratorsOptions{})
+func (b *Bot) HandleMilestoneRelease(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
 	if err != nil {
 		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
 		return
 	}
 
-	var isAdmin bool
-	for _, collaborator := range collaborators {
-		if collaborator.GetID() == issuePayload.Sender.ID {
-			admin, has := collaborator.GetPermissions()["admin"]
-			if has && admin {
-				isAdmin = true
-			}
-			break
-		}
+	if !isAdmin {
+		b.logger.Error("milestone release command issuer is not a repository admin")
+		b.postComment(repo, bounty, releaseCommandIssuerIsNotRepoAdminMessage)
+		return
+	}
+
+	if bounty.State == models.BountyStateExpired {
+		b.logger.Info("can't release milestone as the bounty has expired")
+		b.postComment(repo, bounty, bountyExpiredReleaseRefusedMessage)
+		return
+	}
+
+	// release milestone <number> to @<receiver>
+	args := strings.Fields(strings.TrimPrefix(comment, releaseMilestoneCmd))
+	if len(args) != 3 || args[1] != "to" || !strings.HasPrefix(args[2], "@") || len(args[2]) == 1 {
+		b.postComment(repo, bounty, milestoneCommandInvalidMessage)
+		return
+	}
+	number, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.postComment(repo, bounty, milestoneCommandInvalidMessage)
+		return
+	}
+
+	receiver, _, err := b.GHClient.Users.Get(DefaultCtx(), args[2][1:])
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("couldn't fetch milestone receiver: %s", err.Error()))
+		b.postComment(repo, bounty, receiverNotFoundOnGitHubMessage)
+		return
+	}
+
+	if err := b.BountyCtrl.ReleaseMilestone(bounty, number, receiver.GetID(), commentActor(issuePayload)); err != nil {
+		b.logger.Error(fmt.Sprintf("couldn't release milestone %d: %s", number, err.Error()))
+		b.postComment(repo, bounty, fmt.Sprintf(failedToReleaseMilestoneMessage, err.Error()))
+		return
+	}
+
+	if err := b.PostMilestoneReleasedMessage(repo.Owner, repo.Name, bounty, number, receiver.GetLogin()); err != nil {
+		b.logger.Error(fmt.Sprintf("unable to post milestone released message: %s", err.Error()))
+	}
+}
+
+func (b *Bot) HandleBountyRelease(issuePayload gwb.IssueCommentPayload, bounty *models.Bounty, repo *models.Repository, comment string) {
+
+	isAdmin, err := b.isRepoAdmin(repo, issuePayload.Sender.ID)
+	if err != nil {
+		b.logger.Error(fmt.Sprintf("unable to fetch repository collaborators from GitHub: %s", err.Error()))
+		return
 	}
 
 	if !isAdmin {
@@ -448,6 +1255,12 @@ func (b *Bot) HandleBountyRelease(issuePayload gwb.IssueCommentPayload, bounty *
 		return
 	}
 
+	if bounty.State == models.BountyStateExpired {
+		b.logger.Info("can't release bounty as it has expired")
+		b.postComment(repo, bounty, bountyExpiredReleaseRefusedMessage)
+		return
+	}
+
 	// bounty release command
 	receiverLoginName := strings.TrimSpace(strings.TrimPrefix(comment, releaseBountyCmd))
 	if receiverLoginName == "" {
@@ -477,7 +1290,7 @@ func (b *Bot) HandleBountyRelease(issuePayload gwb.IssueCommentPayload, bounty *
 	b.logger.Info(fmt.Sprintf("setting bounty as released to: %s - %s - ID: %d", receiverLoginName, receiver.GetName(), receiver.GetID()))
 
 	// this also automatically updates the receiver if previously set
-	if err := b.BountyCtrl.ReleaseBounty(bounty, receiver.GetID()); err != nil {
+	if err := b.BountyCtrl.ReleaseBounty(bounty, receiver.GetID(), commentActor(issuePayload)); err != nil {
 		b.logger.Error(fmt.Sprintf("couldn't update bounty state: %s", err.Error()))
 		return
 	}
@@ -5,7 +5,6 @@ import (
 	"github.com/google/go-github/github"
 	"github.com/iotaledger/iota.go/account"
 	"github.com/iotaledger/iota.go/account/builder"
-	"github.com/iotaledger/iota.go/account/deposit"
 	"github.com/iotaledger/iota.go/account/plugins/promoter"
 	"github.com/iotaledger/iota.go/account/store"
 	mongostore "github.com/iotaledger/iota.go/account/store/mongo"
@@ -13,31 +12,54 @@ import (
 	"github.com/iotaledger/iota.go/bundle"
 	"github.com/iotaledger/iota.go/consts"
 	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
 	"github.com/luca-moser/iota-bounty-platform/server/misc"
 	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/nodes"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
 	"gopkg.in/inconshreveable/log15.v2"
-	"net/http"
 	"time"
 )
 
 const bountyCollection = "bounties"
 const deletedBountyCollection = "deleted_bounties"
+const payoutCollection = "payouts"
+const counterCollection = "counters"
+
+// amount of addresses queried per getBalances call during the sync
+const balanceBatchSize = 100
 
 type BountyCtrl struct {
-	Config   *config.Configuration `inject:""`
-	GHClient *github.Client        `inject:""`
-	RepoCtrl *RepoCtrl             `inject:""`
-	Mongo    *mongo.Client         `inject:""`
-	Coll     *mongo.Collection
-	DelColl  *mongo.Collection
-	Bot      *Bot `inject:""`
-	logger   log15.Logger
-	store    store.Store
-	iotaAPI  *api.API
+	Config               *config.Configuration `inject:""`
+	GHClient             *github.Client        `inject:""`
+	RepoCtrl             *RepoCtrl             `inject:""`
+	Mongo                *mongo.Client         `inject:""`
+	Coll                 *mongo.Collection
+	DelColl              *mongo.Collection
+	PayoutColl           *mongo.Collection
+	CounterColl          *mongo.Collection
+	ReportColl           *mongo.Collection
+	CampaignColl         *mongo.Collection
+	CampaignTransferColl *mongo.Collection
+	Bot                  *Bot               `inject:""`
+	NodeCtrl             *NodeCtrl          `inject:""`
+	Audit                *AuditCtrl         `inject:""`
+	Events               *EventCtrl         `inject:""`
+	Vault                *vault.Vault       `inject:""`
+	Deriver              *vault.SeedDeriver `inject:""`
+	logger               log15.Logger
+	store                store.Store
+	iotaAPI              *api.API
+	// the running accounts of the campaigns which sent transfers
+	campaignAccounts map[primitive.ObjectID]*campaignAccount
 }
 
 func (bc *BountyCtrl) Init() error {
@@ -51,11 +73,7 @@ func (bc *BountyCtrl) Init() error {
 
 	// init account module
 	// init api
-	_, powFunc := pow.GetFastestProofOfWorkImpl()
-	bc.iotaAPI, err = api.ComposeAPI(api.HTTPClientSettings{
-		URI: conf.Account.Node, LocalProofOfWorkFunc: powFunc,
-		Client: &http.Client{Timeout: time.Duration(10) * time.Second},
-	})
+	bc.iotaAPI, err = newIOTAAPI(bc.NodeCtrl.Pool)
 	if err != nil {
 		return errors.Wrap(err, "unable to init IOTA API")
 	}
@@ -68,10 +86,53 @@ func (bc *BountyCtrl) Init() error {
 	dbName := bc.Config.DB.DBName
 	bc.Coll = bc.Mongo.Database(dbName).Collection(bountyCollection)
 	bc.DelColl = bc.Mongo.Database(dbName).Collection(deletedBountyCollection)
+	bc.PayoutColl = bc.Mongo.Database(dbName).Collection(payoutCollection)
+	bc.CounterColl = bc.Mongo.Database(dbName).Collection(counterCollection)
+	bc.ReportColl = bc.Mongo.Database(dbName).Collection(reconciliationCollection)
+
+	payoutBountyIndexName := "bounty_id_state"
+	payoutBountyIndex := mongo.IndexModel{
+		Keys: bsonx.Doc{
+			{Key: "bounty_id", Value: bsonx.Int32(int32(1))},
+			{Key: "state", Value: bsonx.Int32(int32(1))},
+		},
+		Options: &options.IndexOptions{Name: &payoutBountyIndexName},
+	}
+	if _, err := bc.PayoutColl.Indexes().CreateOne(DefaultCtx(), payoutBountyIndex); err != nil {
+		return err
+	}
+
+	if err := bc.initBountyIndexes(); err != nil {
+		return err
+	}
+
+	if err := bc.initSearchIndex(); err != nil {
+		return err
+	}
+
+	if err := bc.initCampaigns(); err != nil {
+		return err
+	}
+
+	// seeds must never be stored in plaintext
+	if err := bc.MigratePlaintextSeeds(); err != nil {
+		return errors.Wrap(err, "unable to encrypt plaintext seeds")
+	}
+
+	// deriving seeds from another master seed would lose access to the funds of existing bounties
+	if err := bc.checkSeedMaster(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// newIOTAAPI composes an API which routes its calls through the given node pool.
+func newIOTAAPI(pool *nodes.Pool) (*api.API, error) {
+	_, powFunc := pow.GetFastestProofOfWorkImpl()
+	return api.ComposeAPI(api.HTTPClientSettings{LocalProofOfWorkFunc: powFunc}, pool.CreateProvider)
+}
+
 func (bc *BountyCtrl) GetAll() ([]models.Bounty, error) {
 	bounties := []models.Bounty{}
 	res, err := bc.Coll.Find(DefaultCtx(), bson.D{})
@@ -83,6 +144,9 @@ func (bc *BountyCtrl) GetAll() ([]models.Bounty, error) {
 		if err := res.Decode(&bounty); err != nil {
 			return nil, err
 		}
+		if err := bc.openSeed(&bounty); err != nil {
+			return nil, err
+		}
 		bounties = append(bounties, bounty)
 	}
 	return bounties, errors.Wrap(err, "(bounties) couldn't load all bounties")
@@ -96,8 +160,10 @@ func (bc *BountyCtrl) GetByID(id int64) (*models.Bounty, error) {
 		return nil, res.Err()
 	}
 	bounty := &models.Bounty{}
-	err := res.Decode(bounty)
-	return bounty, errors.Wrapf(err, "(bounty) couldn't load bounty '%s'", id)
+	if err := res.Decode(bounty); err != nil {
+		return nil, errors.Wrapf(err, "(bounty) couldn't load bounty '%d'", id)
+	}
+	return bounty, bc.openSeed(bounty)
 }
 
 func (bc *BountyCtrl) GetByIssueNumber(repoID int64, issueID int) (*models.Bounty, error) {
@@ -109,8 +175,10 @@ func (bc *BountyCtrl) GetByIssueNumber(repoID int64, issueID int) (*models.Bount
 		return nil, res.Err()
 	}
 	bounty := &models.Bounty{}
-	err := res.Decode(bounty)
-	return bounty, errors.Wrapf(err, "(bounty) couldn't load bounty via repo id '%d' and issue number '%d'", repoID, issueID)
+	if err := res.Decode(bounty); err != nil {
+		return nil, errors.Wrapf(err, "(bounty) couldn't load bounty via repo id '%d' and issue number '%d'", repoID, issueID)
+	}
+	return bounty, bc.openSeed(bounty)
 }
 
 func (bc *BountyCtrl) GetOfRepository(owner string, name string) ([]models.Bounty, error) {
@@ -132,12 +200,18 @@ func (bc *BountyCtrl) GetOfRepository(owner string, name string) ([]models.Bount
 		if err := cursor.Decode(&bounty); err != nil {
 			return nil, err
 		}
+		if err := bc.openSeed(&bounty); err != nil {
+			return nil, err
+		}
 		bounties = append(bounties, bounty)
 	}
 	return bounties, errors.Wrapf(err, "(bounty) couldn't load bounties of repository %d", repo.ID)
 }
 
-func (bc *BountyCtrl) Add(owner string, repoName string, issueID int) (*models.Bounty, error) {
+func (bc *BountyCtrl) Add(owner string, repoName string, issueID int, deadline *time.Time, actor *models.Actor) (*models.Bounty, error) {
+	if deadline != nil && !deadline.After(time.Now()) {
+		return nil, ErrDeadlineInPast
+	}
 
 	repo, err := bc.RepoCtrl.GetByOwnerAndName(owner, repoName)
 	if err != nil {
@@ -152,23 +226,41 @@ func (bc *BountyCtrl) Add(owner string, repoName string, issueID int) (*models.B
 		return nil, err
 	}
 
-	seed, err := misc.GenerateSeed()
+	seedIndex, seed, err := bc.allocateSeed()
 	if err != nil {
 		return nil, err
 	}
+	t := time.Now()
 	bounty := &models.Bounty{
 		Model: models.Model{
-			CreatedOn: time.Now(),
+			CreatedOn: t,
+			// set right away so that every bounty can be listed by its update time
+			UpdatedOn: &t,
 		},
-		ID:           issue.GetID(),
-		IssueNumber:  issue.GetNumber(),
-		RepositoryID: repo.ID,
-		ReceiverID:   0,
-		Seed:         seed,
-		URL:          issue.GetHTMLURL(),
-		Title:        issue.GetTitle(),
-		Body:         issue.GetBody(),
-		State:        models.BountyStateOpen,
+		ID:              issue.GetID(),
+		IssueNumber:     issue.GetNumber(),
+		RepositoryID:    repo.ID,
+		ReceiverID:      0,
+		Seed:            seed,
+		SeedIndex:       &seedIndex,
+		URL:             issue.GetHTMLURL(),
+		Title:           issue.GetTitle(),
+		Body:            issue.GetBody(),
+		RepoName:        repo.Name,
+		RepoDescription: repo.Description,
+		State:           models.BountyStateOpen,
+		Deadline:        deadline,
+		RemindersSent:   []int{},
+	}
+
+	// milestones of the task list in the issue body
+	if milestones := ParseMilestones(bounty.Body); len(milestones) > 0 {
+		if err := validateMilestones(milestones); err != nil {
+			bc.logger.Warn(fmt.Sprintf("ignoring milestones of the issue of bounty %d: %s", bounty.ID, err.Error()))
+		} else {
+			bounty.Milestones = milestones
+			bounty.MilestoneSource = models.MilestoneSourceIssue
+		}
 	}
 
 	// initialize a new account for this issue
@@ -180,22 +272,28 @@ func (bc *BountyCtrl) Add(owner string, repoName string, issueID int) (*models.B
 		return nil, err
 	}
 
-	// generate pool address, extra short timeout so it will be selected
-	timeout := time.Now().Add(time.Duration(3) * time.Minute)
-	cda, err := acc.AllocateDepositAddress(&deposit.Conditions{
-		TimeoutAt: &timeout,
-	})
+	// generate pool address
+	bounty.PoolAddress, err = newDepositAddress(acc)
 	if err != nil {
 		return nil, err
 	}
-	bounty.PoolAddress = cda.Address
 	if err := acc.Shutdown(); err != nil {
 		return nil, err
 	}
 
-	if _, err := bc.Coll.InsertOne(DefaultCtx(), bounty); err != nil {
+	sealed, err := bc.sealSeed(bounty)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bc.Coll.InsertOne(DefaultCtx(), sealed); err != nil {
 		return nil, errors.Wrap(err, "(bounty) couldn't insert bounty")
 	}
+	bc.audit(bounty.ID, models.AuditEventCreated, actor, "",
+		auditChange("state", nil, bounty.State),
+		auditChange("pool_address", nil, bounty.PoolAddress),
+		auditChange("deadline", nil, bounty.Deadline),
+	)
+	bc.publishBounty(models.LiveEventBountyCreated, bounty, bounty.State, bounty.Balance)
 
 	// post message to the issue
 	if err := bc.Bot.PostNewBountyMessage(owner, repoName, bounty); err != nil {
@@ -216,7 +314,10 @@ func (bc *BountyCtrl) LoadAccount(seed string) (account.Account, error) {
 		Build()
 }
 
-func (bc *BountyCtrl) LoadAccountForSending(seed string) (account.Account, error) {
+// LoadAccountForSending loads the account with the promoter plugin. The optional onPrepared callback
+// is invoked with the bundle hash of a transfer after it was signed but before it is attached to the tangle,
+// an error returned by it aborts the transfer.
+func (bc *BountyCtrl) LoadAccountForSending(seed string, onPrepared func(bundleHash string) error) (account.Account, error) {
 	build := builder.NewBuilder().
 		WithSeed(seed).
 		WithAPI(bc.iotaAPI).
@@ -224,6 +325,23 @@ func (bc *BountyCtrl) LoadAccountForSending(seed string) (account.Account, error
 		WithDepth(bc.Config.Account.GTTADepth).
 		WithMWM(bc.Config.Account.MWM).
 		WithSecurityLevel(consts.SecurityLevel(bc.Config.Account.SecurityLevel))
+	if onPrepared != nil {
+		prepare := account.DefaultPrepareTransfers(bc.iotaAPI, build.Settings().SeedProv)
+		build.WithPrepareTransfersFunc(func(transfers bundle.Transfers, opts api.PrepareTransfersOptions) ([]trinary.Trytes, error) {
+			bundleTrytes, err := prepare(transfers, opts)
+			if err != nil {
+				return nil, err
+			}
+			tx, err := transaction.AsTransactionObject(bundleTrytes[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := onPrepared(tx.Bundle); err != nil {
+				return nil, err
+			}
+			return bundleTrytes, nil
+		})
+	}
 	return build.Build(promoter.NewPromoter(build.Settings(), time.Duration(30)*time.Second))
 }
 
@@ -252,7 +370,17 @@ func (bc *BountyCtrl) GetAccountBalance(seed string) (uint64, error) {
 	return balance, nil
 }
 
-func (bc *BountyCtrl) ReleaseBounty(bounty *models.Bounty, receiverID int64) error {
+// ReleaseBounty releases the bounty to the given receiver on behalf of the given actor.
+func (bc *BountyCtrl) ReleaseBounty(bounty *models.Bounty, receiverID int64, actor *models.Actor) error {
+	if bounty.State == models.BountyStateExpired {
+		return ErrBountyExpired
+	}
+
+	// a payout requested by the previous receiver must not be approved anymore
+	if err := bc.withdrawPayoutOfOtherReceiver(bounty, receiverID, 0, actor); err != nil {
+		return err
+	}
+
 	// load up account balance
 	availBalance, err := bc.GetAccountBalance(bounty.Seed)
 	if err != nil {
@@ -263,59 +391,91 @@ func (bc *BountyCtrl) ReleaseBounty(bounty *models.Bounty, receiverID int64) err
 	mut := bson.D{{"$set", bson.D{
 		{"state", models.BountyStateReleased},
 		{"receiver_id", receiverID},
+		{"released_by", actor.GitHubID},
 		{"balance", availBalance},
 		{"model.updated_on", t},
 	}}}
-	_, err = bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut)
-	return errors.Wrapf(err, "(bounty) couldn't update bounty state '%s'", bounty.ID)
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update bounty state '%d'", bounty.ID)
+	}
+
+	kind := models.AuditEventReleased
+	if bounty.ReceiverID != 0 {
+		kind = models.AuditEventReceiverChanged
+	}
+	bc.audit(bounty.ID, kind, actor, "",
+		auditChange("state", bounty.State, models.BountyStateReleased),
+		auditChange("receiver_id", bounty.ReceiverID, receiverID),
+		auditChange("released_by", bounty.ReleasedBy, actor.GitHubID),
+		auditChange("balance", bounty.Balance, availBalance),
+	)
+	bc.publishBounty(models.LiveEventBountyReleased, bounty, models.BountyStateReleased, availBalance)
+	return nil
 }
 
 var ErrBountyAddrEmpty = errors.New("the bounty address has no funds")
 
-func (bc *BountyCtrl) TransferBounty(bounty *models.Bounty, addr string) (bundle.Bundle, uint64, error) {
+// TransferBounty sends the funds of the released bounty to the given address posted by the receiver (the actor).
+func (bc *BountyCtrl) TransferBounty(bounty *models.Bounty, addr string, actor *models.Actor) (*models.Payout, error) {
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// refuse to send again if a previous payout is still in flight or was already sent
+	if err := bc.checkNoActivePayout(bounty.ID); err != nil {
+		return nil, err
+	}
+
 	// note, since TransferBounty is only called from within a issue comment handling
 	// which is synchronized globally, it is safe to load the account and starting it
-	acc, err := bc.LoadAccountForSending(bounty.Seed)
+	availBalance, err := bc.GetAccountBalance(bounty.Seed)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	if err := acc.Start(); err != nil {
-		return nil, 0, err
+
+	if availBalance == 0 {
+		return nil, ErrBountyAddrEmpty
 	}
 
-	availBalance, err := acc.AvailableBalance()
+	split, err := bc.computePayoutSplit(availBalance, repo)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	if availBalance == 0 {
-		return nil, 0, ErrBountyAddrEmpty
+	payout := &models.Payout{
+		Kind:            models.PayoutKindTransfer,
+		Value:           availBalance,
+		ReceiverAddress: addr,
+		ReceiverID:      bounty.ReceiverID,
+		Split:           split,
+		RequestedBy:     bounty.ReleasedBy,
 	}
+	bc.audit(bounty.ID, models.AuditEventTransferAttempted, actor, "",
+		auditChange("receiver_address", bounty.ReceiverAddress, addr),
+		auditChange("value", nil, availBalance),
+	)
 
-	trsnf := account.Recipient{
-		Address: addr,
-		Value:   availBalance,
-		Tag:     bundle.PadTag("IOTABOUNTY"),
+	// high-value payouts are held until a second person approves them
+	if bc.requiresApproval(payout) {
+		if err := bc.holdForApproval(bounty, payout); err != nil {
+			return nil, err
+		}
+		bc.audit(bounty.ID, models.AuditEventTransferHeld, actor, fmt.Sprintf("payout %s awaits approval", payout.ID.Hex()))
+		return payout, nil
 	}
 
-	bndl, err := acc.Send(trsnf)
-	if err != nil {
-		return nil, 0, err
+	if err := bc.sendPayout(bounty, payout, payoutRecipients(split, addr)); err != nil {
+		bc.audit(bounty.ID, models.AuditEventTransferFailed, actor, err.Error())
+		return nil, err
 	}
 
-	t := time.Now()
-	mut := bson.D{{"$set", bson.D{
-		{"state", models.BountyStateTransferred},
-		{"receiver_address", addr},
-		{"bundle_hash", bndl[0].Bundle},
-		{"balance", availBalance},
-		{"model.updated_on", t},
-	}}}
-	if _, err = bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
-		return nil, 0, errors.Wrapf(err, "(bounty) couldn't update bounty state '%d'", bounty.ID)
+	if err := bc.finalizePayout(payout); err != nil {
+		return nil, err
 	}
+	bc.auditPayoutSent(bounty, payout, actor)
 
-	return bndl, availBalance, nil
+	return payout, nil
 }
 
 func (bc *BountyCtrl) SyncBounties() {
@@ -325,16 +485,73 @@ func (bc *BountyCtrl) SyncBounties() {
 		return
 	}
 
+	balances, err := bc.poolBalances(bounties)
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't batch query pool balances, falling back to account balances: %s", err.Error()))
+		balances = map[int64]uint64{}
+	}
+
 	for i := range bounties {
 		bounty := &bounties[i]
 		bc.logger.Info(fmt.Sprintf("syncing bounty: %d/%s", bounty.ID, bounty.Title))
-		if err := bc.SyncBounty(bounty); err != nil {
+		if balance, ok := balances[bounty.ID]; ok {
+			err = bc.SyncBounty(bounty, balance)
+		} else {
+			err = bc.SyncBounty(bounty)
+		}
+		if err != nil {
 			bc.logger.Error(fmt.Sprintf("can't sync bounty %d/%s: %s", bounty.ID, bounty.Title, err.Error()))
 		}
 	}
 }
 
-func (bc *BountyCtrl) SyncBounty(bounty *models.Bounty) error {
+// poolBalances queries the balances of the pool addresses of all unsettled bounties in batches.
+// Bounties with a payout in flight are left out, as their balance must be computed by the account
+// which knows about the pending transfer. So are bounties with paid out milestones, whose remaining
+// funds moved onto a remainder address of the account.
+func (bc *BountyCtrl) poolBalances(bounties []models.Bounty) (map[int64]uint64, error) {
+	inFlight, err := bc.PayoutColl.Distinct(DefaultCtx(), "bounty_id", bson.D{{"state", bson.D{{"$in", activePayoutStates}}}})
+	if err != nil {
+		return nil, errors.Wrap(err, "(bounty) couldn't load bounties with payouts in flight")
+	}
+	skip := map[int64]bool{}
+	for _, id := range inFlight {
+		if bountyID, ok := id.(int64); ok {
+			skip[bountyID] = true
+		}
+	}
+
+	ids := []int64{}
+	addrs := trinary.Hashes{}
+	for i := range bounties {
+		bounty := &bounties[i]
+		if bounty.Settled() || skip[bounty.ID] || bounty.PaidOut > 0 || len(bounty.PoolAddress) < consts.HashTrytesSize {
+			continue
+		}
+		ids = append(ids, bounty.ID)
+		addrs = append(addrs, bounty.PoolAddress[:consts.HashTrytesSize])
+	}
+
+	balances := make(map[int64]uint64, len(ids))
+	for start := 0; start < len(addrs); start += balanceBatchSize {
+		end := start + balanceBatchSize
+		if end > len(addrs) {
+			end = len(addrs)
+		}
+		res, err := bc.iotaAPI.GetBalances(addrs[start:end], 100)
+		if err != nil {
+			return nil, err
+		}
+		for i, balance := range res.Balances {
+			balances[ids[start+i]] = balance
+		}
+	}
+	return balances, nil
+}
+
+// SyncBounty syncs the given bounty with its issue and the tangle. The balance of the pool address can be
+// passed in if it was already queried, otherwise it is computed by the bounty's account.
+func (bc *BountyCtrl) SyncBounty(bounty *models.Bounty, poolBalance ...uint64) error {
 
 	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
 	if err != nil {
@@ -345,7 +562,7 @@ func (bc *BountyCtrl) SyncBounty(bounty *models.Bounty) error {
 	if err != nil {
 		if res != nil && res.StatusCode == 404 {
 			// delete the bounty as the associated issue no longer exists
-			if err := bc.Delete(bounty.ID); err != nil {
+			if err := bc.Delete(bounty.ID, PlatformActor); err != nil {
 				return err
 			}
 			return ErrIssueDoesntExist
@@ -354,10 +571,14 @@ func (bc *BountyCtrl) SyncBounty(bounty *models.Bounty) error {
 	}
 
 	balance := bounty.Balance
-	// only updated bounty balance if it wasn't transferred yet
-	if bounty.State != models.BountyStateTransferred {
-		balance, err = bc.GetAccountBalance(bounty.Seed)
-		if err != nil {
+	// only updated bounty balance and contributions if it wasn't transferred or refunded yet
+	if !bounty.Settled() {
+		if len(poolBalance) > 0 {
+			balance = poolBalance[0]
+		} else if balance, err = bc.GetAccountBalance(bounty.Seed); err != nil {
+			return err
+		}
+		if err := bc.SyncContributions(bounty); err != nil {
 			return err
 		}
 	}
@@ -368,20 +589,72 @@ func (bc *BountyCtrl) SyncBounty(bounty *models.Bounty) error {
 		{"body", issue.GetBody()},
 		{"url", issue.GetHTMLURL()},
 		{"balance", balance},
+		{"repo_name", repo.Name},
+		{"repo_description", repo.Description},
 		{"model.updated_on", t},
 	}}}
 
-	_, err = bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut)
-	return errors.Wrapf(err, "(bounty) couldn't update bounty '%d'", bounty.ID)
+	if _, err = bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update bounty '%d'", bounty.ID)
+	}
+
+	var changes []models.AuditChange
+	if bounty.Title != issue.GetTitle() {
+		changes = append(changes, auditChange("title", bounty.Title, issue.GetTitle()))
+	}
+	if bounty.Body != issue.GetBody() {
+		changes = append(changes, auditChange("body", bounty.Body, issue.GetBody()))
+	}
+	if bounty.URL != issue.GetHTMLURL() {
+		changes = append(changes, auditChange("url", bounty.URL, issue.GetHTMLURL()))
+	}
+	if bounty.Balance != balance {
+		changes = append(changes, auditChange("balance", bounty.Balance, balance))
+		bc.publishBounty(models.LiveEventBountyBalanceChanged, bounty, bounty.State, balance)
+	}
+	if len(changes) > 0 {
+		bc.audit(bounty.ID, models.AuditEventSynced, PlatformActor, "", changes...)
+	}
+
+	if bounty.Settled() {
+		return bc.checkLateDeposits(bounty, repo)
+	}
+	if bounty.PoolAddressSpent {
+		bc.renewPoolAddress(bounty)
+		// the milestone sent message couldn't name the new pool address
+		if !bounty.PoolAddressSpent {
+			if err := bc.Bot.PostPoolAddressRenewedMessage(repo.Owner, repo.Name, bounty); err != nil {
+				bc.logger.Error(fmt.Sprintf("unable to post pool address renewed message: %s", err.Error()))
+			}
+		}
+	}
+	if len(bounty.SpentPoolAddresses) > 0 {
+		if err := bc.checkLateDeposits(bounty, repo); err != nil {
+			bc.logger.Error(fmt.Sprintf("unable to check the spent pool addresses of bounty %d: %s", bounty.ID, err.Error()))
+		}
+	}
+	// a failed announcement, milestone update or matching must not hold up the deadline handling
+	if err := bc.syncIssueMilestones(bounty, issue.GetBody()); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to update the milestones of bounty %d: %s", bounty.ID, err.Error()))
+	}
+	if err := bc.checkFundingNotice(bounty, repo, balance); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to announce the funding of bounty %d: %s", bounty.ID, err.Error()))
+	}
+	if err := bc.matchContributions(bounty, repo); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to match the contributions of bounty %d: %s", bounty.ID, err.Error()))
+	}
+	return bc.checkDeadline(bounty, repo)
 }
 
-func (bc *BountyCtrl) Delete(id int64, repo ...*models.Repository) error {
+// Delete removes the bounty on behalf of the given actor, refunding its funds if it wasn't paid out yet.
+func (bc *BountyCtrl) Delete(id int64, actor *models.Actor, repo ...*models.Repository) error {
 	bounty, err := bc.GetByID(id)
 	if err != nil {
 		return err
 	}
 
-	if bounty.State != models.BountyStateTransferred {
+	// paid out bounties are removed without notice
+	if bounty.State != models.BountyStateTransferred && bounty.State != models.BountyStateConfirmed {
 		// load the repo if not given
 		var r *models.Repository
 		if len(repo) > 0 {
@@ -393,6 +666,17 @@ func (bc *BountyCtrl) Delete(id int64, repo ...*models.Repository) error {
 			}
 		}
 
+		// refund the remaining funds before the bounty is removed, as otherwise
+		// they would be stuck on the pool address forever
+		if !bounty.Settled() {
+			if _, err := bc.Cancel(bounty.ID, actor, r); err != nil {
+				return errors.Wrapf(err, "(bounty) couldn't refund bounty '%d' before deletion", id)
+			}
+			if bounty, err = bc.GetByID(id); err != nil {
+				return err
+			}
+		}
+
 		// ignore error as we want to delete the bounty whether we fail posting or not
 		bc.Bot.PostBountyDeletedFromPlatformMessage(r.Owner, r.Name, bounty)
 	}
@@ -400,6 +684,10 @@ func (bc *BountyCtrl) Delete(id int64, repo ...*models.Repository) error {
 	if _, err = bc.Coll.DeleteOne(DefaultCtx(), bson.D{{"_id", id}}); err != nil {
 		return errors.Wrapf(err, "(bounty) couldn't delete bounty '%d'", id)
 	}
+	bc.audit(id, models.AuditEventDeleted, actor, "", auditChange("state", bounty.State, nil))
+	bc.Events.Publish(models.LiveEvent{Kind: models.LiveEventBountyDeleted, RepositoryID: bounty.RepositoryID, BountyID: id})
+	// only keep the encrypted seed
+	bounty.Seed = ""
 	_, err = bc.DelColl.InsertOne(DefaultCtx(), models.DeletedModel{Object: bounty})
-	return errors.Wrapf(err, "(bounty) couldn't move bounty '%s' to deleted collection", id)
+	return errors.Wrapf(err, "(bounty) couldn't move bounty '%d' to deleted collection", id)
 }
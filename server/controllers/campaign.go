@@ -0,0 +1,632 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/account"
+	"github.com/iotaledger/iota.go/account/deposit"
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/api"
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/iotaledger/iota.go/guards"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"math"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCampaign = errors.New("invalid campaign")
+var ErrCampaignEnded = errors.New("the campaign has already ended")
+var ErrCampaignNotEnded = errors.New("the campaign hasn't ended yet")
+var ErrNoCampaignRefundAddress = errors.New("the campaign has no refund address")
+var ErrNoCampaignFunds = errors.New("the campaign account holds no funds")
+
+const campaignCollection = "campaigns"
+const campaignTransferCollection = "campaign_transfers"
+
+// transfers whose value counts towards the cap of their campaign, failed matches are retried by the next sync
+var committedCampaignTransferStates = bson.A{models.PayoutStatePending, models.PayoutStateSent, models.PayoutStateConfirmed}
+
+// campaignAccount is the running account of a campaign. It is kept running so that its promoter
+// takes care of all transfers of the campaign.
+type campaignAccount struct {
+	account.Account
+	// the transfer currently being sent, its bundle hash is stored once the bundle is signed
+	sending *models.CampaignTransfer
+}
+
+func (bc *BountyCtrl) initCampaigns() error {
+	dbName := bc.Config.DB.DBName
+	bc.CampaignColl = bc.Mongo.Database(dbName).Collection(campaignCollection)
+	bc.CampaignTransferColl = bc.Mongo.Database(dbName).Collection(campaignTransferCollection)
+	bc.campaignAccounts = map[primitive.ObjectID]*campaignAccount{}
+
+	campaignIndexName := "campaign_id_state"
+	bountyIndexName := "bounty_id_kind"
+	_, err := bc.CampaignTransferColl.Indexes().CreateMany(DefaultCtx(), []mongo.IndexModel{
+		{
+			Keys: bsonx.Doc{
+				{Key: "campaign_id", Value: bsonx.Int32(int32(1))},
+				{Key: "state", Value: bsonx.Int32(int32(1))},
+			},
+			Options: &options.IndexOptions{Name: &campaignIndexName},
+		},
+		{
+			Keys: bsonx.Doc{
+				{Key: "bounty_id", Value: bsonx.Int32(int32(1))},
+				{Key: "kind", Value: bsonx.Int32(int32(1))},
+			},
+			Options: &options.IndexOptions{Name: &bountyIndexName},
+		},
+	})
+	return err
+}
+
+// AddCampaign validates and stores the given campaign and allocates the funding address of its account.
+func (bc *BountyCtrl) AddCampaign(campaign *models.Campaign) (*models.Campaign, error) {
+	if err := validateCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	seedIndex, seed, err := bc.allocateSeed()
+	if err != nil {
+		return nil, err
+	}
+	fundingAddr, err := bc.allocateDepositAddress(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign.Model = models.Model{CreatedOn: time.Now()}
+	campaign.ID = primitive.NewObjectID()
+	campaign.SeedIndex = seedIndex
+	campaign.FundingAddress = fundingAddr
+	campaign.EndedOn = nil
+	campaign.Balance = 0
+	if campaign.RepositoryIDs == nil {
+		campaign.RepositoryIDs = []int64{}
+	}
+	if campaign.BountyIDs == nil {
+		campaign.BountyIDs = []int64{}
+	}
+
+	if _, err := bc.CampaignColl.InsertOne(DefaultCtx(), campaign); err != nil {
+		return nil, errors.Wrap(err, "(campaign) couldn't insert campaign")
+	}
+	bc.logger.Info(fmt.Sprintf("added campaign %s of %s", campaign.ID.Hex(), campaign.Sponsor))
+	return campaign, nil
+}
+
+func validateCampaign(campaign *models.Campaign) error {
+	campaign.Sponsor = strings.TrimSpace(campaign.Sponsor)
+	switch {
+	case campaign.Sponsor == "":
+		return errors.Wrap(ErrInvalidCampaign, "the sponsor is missing")
+	case campaign.Ratio <= 0 || math.IsInf(campaign.Ratio, 0) || math.IsNaN(campaign.Ratio):
+		return errors.Wrap(ErrInvalidCampaign, "the ratio must be positive")
+	case campaign.Cap == 0:
+		return errors.Wrap(ErrInvalidCampaign, "the cap must be positive")
+	case !campaign.EndsOn.After(campaign.StartsOn) || !campaign.EndsOn.After(time.Now()):
+		return errors.Wrap(ErrInvalidCampaign, "the campaign must end after it starts and in the future")
+	}
+	if addr := campaign.RefundAddress; addr != "" {
+		if !guards.IsAddressWithChecksum(addr) || address.ValidChecksum(addr[:81], addr[81:]) != nil {
+			return errors.Wrap(ErrInvalidCampaign, "invalid refund address")
+		}
+	}
+	return nil
+}
+
+// allocateDepositAddress allocates a new deposit address of the account with the given seed.
+func (bc *BountyCtrl) allocateDepositAddress(seed string) (string, error) {
+	acc, err := bc.LoadAccount(seed)
+	if err != nil {
+		return "", err
+	}
+	if err := acc.Start(); err != nil {
+		return "", err
+	}
+	cda, err := newDepositAddress(acc)
+	if err != nil {
+		return "", err
+	}
+	return cda, acc.Shutdown()
+}
+
+func newDepositAddress(acc account.Account) (string, error) {
+	// extra short timeout so that the funds on the address are selected as inputs
+	timeout := time.Now().Add(time.Duration(3) * time.Minute)
+	cda, err := acc.AllocateDepositAddress(&deposit.Conditions{TimeoutAt: &timeout})
+	if err != nil {
+		return "", err
+	}
+	return cda.Address, nil
+}
+
+// GetCampaigns returns all campaigns, newest first, including their progress.
+func (bc *BountyCtrl) GetCampaigns() ([]models.Campaign, error) {
+	return bc.getCampaigns(bson.D{}, options.Find().SetSort(bson.D{{"model.created_on", -1}}))
+}
+
+func (bc *BountyCtrl) GetCampaignByID(id primitive.ObjectID) (*models.Campaign, error) {
+	campaign := &models.Campaign{}
+	if err := bc.CampaignColl.FindOne(DefaultCtx(), bson.D{{"_id", id}}).Decode(campaign); err != nil {
+		return nil, err
+	}
+	matched, err := bc.campaignMatched(campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+	campaign.Matched = matched
+	return campaign, nil
+}
+
+func (bc *BountyCtrl) getCampaigns(filter bson.D, opts ...*options.FindOptions) ([]models.Campaign, error) {
+	campaigns := []models.Campaign{}
+	cursor, err := bc.CampaignColl.Find(DefaultCtx(), filter, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "(campaign) couldn't load campaigns")
+	}
+	for cursor.Next(DefaultCtx()) {
+		var campaign models.Campaign
+		if err := cursor.Decode(&campaign); err != nil {
+			return nil, err
+		}
+		if campaign.Matched, err = bc.campaignMatched(campaign.ID); err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, nil
+}
+
+// campaignMatched sums up the value of all matching transfers of the given campaign which didn't fail.
+func (bc *BountyCtrl) campaignMatched(id primitive.ObjectID) (uint64, error) {
+	cursor, err := bc.CampaignTransferColl.Aggregate(DefaultCtx(), mongo.Pipeline{
+		{{"$match", bson.D{
+			{"campaign_id", id},
+			{"kind", models.CampaignTransferKindMatch},
+			{"state", bson.D{{"$in", committedCampaignTransferStates}}},
+		}}},
+		{{"$group", bson.D{{"_id", nil}, {"matched", bson.D{{"$sum", "$value"}}}}}},
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "(campaign) couldn't sum up matched value of campaign '%s'", id.Hex())
+	}
+	var res struct {
+		Matched int64 `bson:"matched"`
+	}
+	if cursor.Next(DefaultCtx()) {
+		if err := cursor.Decode(&res); err != nil {
+			return 0, err
+		}
+	}
+	return uint64(res.Matched), nil
+}
+
+// GetCampaignTransfers returns the transfers of the given campaign in the order they were made.
+func (bc *BountyCtrl) GetCampaignTransfers(id primitive.ObjectID) ([]models.CampaignTransfer, error) {
+	return bc.getCampaignTransfers(bson.D{{"campaign_id", id}})
+}
+
+func (bc *BountyCtrl) getCampaignTransfers(filter bson.D) ([]models.CampaignTransfer, error) {
+	transfers := []models.CampaignTransfer{}
+	cursor, err := bc.CampaignTransferColl.Find(DefaultCtx(), filter, options.Find().SetSort(bson.D{{"model.created_on", 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "(campaign) couldn't load campaign transfers")
+	}
+	for cursor.Next(DefaultCtx()) {
+		var transfer models.CampaignTransfer
+		if err := cursor.Decode(&transfer); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, nil
+}
+
+// EndCampaign ends the given campaign before its end date, contributions detected afterwards are no longer matched.
+func (bc *BountyCtrl) EndCampaign(id primitive.ObjectID) (*models.Campaign, error) {
+	campaign, err := bc.GetCampaignByID(id)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Now()
+	if !campaign.End().After(t) {
+		return nil, ErrCampaignEnded
+	}
+	mut := bson.D{{"$set", bson.D{
+		{"ended_on", t},
+		{"model.updated_on", t},
+	}}}
+	if _, err := bc.CampaignColl.UpdateOne(DefaultCtx(), bson.D{{"_id", id}}, mut); err != nil {
+		return nil, errors.Wrapf(err, "(campaign) couldn't end campaign '%s'", id.Hex())
+	}
+	campaign.EndedOn = &t
+	return campaign, nil
+}
+
+// SyncCampaigns refreshes the balances of the campaign accounts and tracks the confirmation of sent transfers.
+func (bc *BountyCtrl) SyncCampaigns() {
+	campaigns, err := bc.getCampaigns(bson.D{})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load campaigns for sync: %s", err.Error()))
+		return
+	}
+
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		balance, err := bc.GetAccountBalance(bc.Deriver.Derive(uint64(campaign.SeedIndex)))
+		if err != nil {
+			bc.logger.Error(fmt.Sprintf("can't query balance of campaign %s: %s", campaign.ID.Hex(), err.Error()))
+			continue
+		}
+		if balance == campaign.Balance {
+			continue
+		}
+		mut := bson.D{{"$set", bson.D{
+			{"balance", balance},
+			{"model.updated_on", time.Now()},
+		}}}
+		if _, err := bc.CampaignColl.UpdateOne(DefaultCtx(), bson.D{{"_id", campaign.ID}}, mut); err != nil {
+			bc.logger.Error(fmt.Sprintf("can't update balance of campaign %s: %s", campaign.ID.Hex(), err.Error()))
+		}
+	}
+
+	transfers, err := bc.getCampaignTransfers(bson.D{{"state", models.PayoutStateSent}})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load sent campaign transfers for confirmation tracking: %s", err.Error()))
+		return
+	}
+	for i := range transfers {
+		if err := bc.trackCampaignTransfer(&transfers[i]); err != nil {
+			bc.logger.Error(fmt.Sprintf("can't track campaign transfer %s: %s", transfers[i].ID.Hex(), err.Error()))
+		}
+	}
+}
+
+// trackCampaignTransfer marks the given sent transfer as confirmed once one of its tails got confirmed.
+func (bc *BountyCtrl) trackCampaignTransfer(transfer *models.CampaignTransfer) error {
+	txs, err := bc.iotaAPI.FindTransactionObjects(api.FindTransactionsQuery{Bundles: trinary.Hashes{transfer.BundleHash}})
+	if err != nil {
+		return err
+	}
+	tails := trinary.Hashes{}
+	for i := range txs {
+		if txs[i].CurrentIndex == 0 {
+			tails = append(tails, txs[i].Hash)
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+
+	states, err := bc.iotaAPI.GetLatestInclusion(tails)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if !state {
+			continue
+		}
+		t := time.Now()
+		transfer.State = models.PayoutStateConfirmed
+		transfer.ConfirmedOn = &t
+		bc.logger.Info(fmt.Sprintf("campaign transfer %s of campaign %s confirmed", transfer.ID.Hex(), transfer.CampaignID.Hex()))
+		return bc.campaignOutbox().update(transfer.ID, bson.D{
+			{"state", transfer.State},
+			{"confirmed_on", t},
+		})
+	}
+	return nil
+}
+
+// campaignMatch is a matching transfer sent onto the pool address of a bounty.
+type campaignMatch struct {
+	transfer *models.CampaignTransfer
+	// the address the matched funds are refunded to if the bounty gets cancelled
+	refundAddress string
+}
+
+// campaignMatchesOfBounty returns the sent matching transfers of the given bounty by their bundle hash.
+func (bc *BountyCtrl) campaignMatchesOfBounty(bountyID int64) (map[string]campaignMatch, error) {
+	transfers, err := bc.getCampaignTransfers(bson.D{
+		{"bounty_id", bountyID},
+		{"kind", models.CampaignTransferKindMatch},
+		{"state", bson.D{{"$in", bson.A{models.PayoutStateSent, models.PayoutStateConfirmed}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	matches := make(map[string]campaignMatch, len(transfers))
+	campaigns := map[primitive.ObjectID]*models.Campaign{}
+	for i := range transfers {
+		transfer := &transfers[i]
+		campaign, ok := campaigns[transfer.CampaignID]
+		if !ok {
+			if campaign, err = bc.GetCampaignByID(transfer.CampaignID); err != nil {
+				return nil, err
+			}
+			campaigns[transfer.CampaignID] = campaign
+		}
+		matches[transfer.BundleHash] = campaignMatch{transfer: transfer, refundAddress: campaign.RefundAddress}
+	}
+	return matches, nil
+}
+
+// matchContributions sends the matching transfers of all active campaigns covering the given bounty for the
+// contributions which were detected while the campaigns were running and which aren't matched yet.
+func (bc *BountyCtrl) matchContributions(bounty *models.Bounty, repo *models.Repository) error {
+	if bounty.State != models.BountyStateOpen && bounty.State != models.BountyStateReleased {
+		return nil
+	}
+
+	t := time.Now()
+	campaigns, err := bc.getCampaigns(bson.D{
+		{"starts_on", bson.D{{"$lte", t}}},
+		{"ends_on", bson.D{{"$gt", t}}},
+		{"ended_on", bson.D{{"$exists", false}}},
+	})
+	if err != nil || len(campaigns) == 0 {
+		return err
+	}
+
+	matched, err := bc.getCampaignTransfers(bson.D{
+		{"bounty_id", bounty.ID},
+		{"kind", models.CampaignTransferKindMatch},
+		{"state", bson.D{{"$in", committedCampaignTransferStates}}},
+	})
+	if err != nil {
+		return err
+	}
+	isMatched := map[string]bool{}
+	for _, transfer := range matched {
+		isMatched[transfer.CampaignID.Hex()+transfer.ContributionBundleHash] = true
+	}
+
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		if !campaign.Covers(bounty) {
+			continue
+		}
+		for j := range bounty.Contributions {
+			contribution := &bounty.Contributions[j]
+			if contribution.CampaignID != nil || contribution.DetectedOn == nil || !campaign.Active(*contribution.DetectedOn) {
+				continue
+			}
+			if isMatched[campaign.ID.Hex()+contribution.BundleHash] {
+				continue
+			}
+			// a failed match is retried by the next sync
+			if err := bc.matchContribution(campaign, bounty, repo, contribution); err != nil {
+				bc.logger.Error(err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// matchContribution sends the matching transfer of the given contribution. The matched value is limited by
+// the remaining cap of the campaign. If the campaign account doesn't hold enough funds, the contribution
+// is matched by a later sync once the sponsor topped up the account.
+func (bc *BountyCtrl) matchContribution(campaign *models.Campaign, bounty *models.Bounty, repo *models.Repository, contribution *models.Contribution) error {
+	value := uint64(math.Floor(float64(contribution.Value) * campaign.Ratio))
+	if campaign.Matched >= campaign.Cap {
+		return nil
+	}
+	if remaining := campaign.Cap - campaign.Matched; value > remaining {
+		value = remaining
+	}
+	if value == 0 {
+		return nil
+	}
+	if value > campaign.Balance {
+		bc.logger.Info(fmt.Sprintf("campaign %s lacks the funds to match contribution %s of bounty %d (%d of %d iotas)",
+			campaign.ID.Hex(), contribution.BundleHash, bounty.ID, campaign.Balance, value))
+		return nil
+	}
+
+	transfer := &models.CampaignTransfer{
+		Kind:                   models.CampaignTransferKindMatch,
+		BountyID:               bounty.ID,
+		ContributionBundleHash: contribution.BundleHash,
+		ContributionValue:      contribution.Value,
+		Value:                  value,
+		Address:                bounty.PoolAddress,
+	}
+	// the balance of the campaign is lowered by the sent transfer
+	if err := bc.sendCampaignTransfer(campaign, transfer); err != nil {
+		// the transfer might have left, its value is kept back until it is resumed
+		if sendUnverified(err) {
+			campaign.Matched += value
+			campaign.Balance -= value
+		}
+		return errors.Wrapf(err, "(campaign) couldn't match contribution '%s' of bounty '%d'", contribution.BundleHash, bounty.ID)
+	}
+	campaign.Matched += value
+	bc.logger.Info(fmt.Sprintf("campaign %s matched contribution %s of bounty %d with %d iotas", campaign.ID.Hex(), contribution.BundleHash, bounty.ID, value))
+
+	bc.audit(bounty.ID, models.AuditEventContributionMatched, PlatformActor, fmt.Sprintf("campaign %s", campaign.ID.Hex()),
+		auditChange("matched_contribution", nil, contribution.BundleHash),
+		auditChange("match_bundle_hash", nil, transfer.BundleHash),
+		auditChange("match_value", nil, value),
+	)
+	return bc.Bot.PostContributionMatchedMessage(repo.Owner, repo.Name, bounty, campaign, contribution, transfer)
+}
+
+// WithdrawCampaign sends the leftover funds of the given ended campaign to its refund address.
+func (bc *BountyCtrl) WithdrawCampaign(id primitive.ObjectID) (*models.CampaignTransfer, error) {
+	campaign, err := bc.GetCampaignByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.End().After(time.Now()) {
+		return nil, ErrCampaignNotEnded
+	}
+	if campaign.RefundAddress == "" {
+		return nil, ErrNoCampaignRefundAddress
+	}
+
+	ca, err := bc.campaignAccount(campaign)
+	if err != nil {
+		return nil, err
+	}
+	// funds of sent but unconfirmed transfers aren't available
+	balance, err := ca.AvailableBalance()
+	if err != nil {
+		return nil, err
+	}
+	if balance == 0 {
+		return nil, ErrNoCampaignFunds
+	}
+
+	transfer := &models.CampaignTransfer{
+		Kind:    models.CampaignTransferKindWithdrawal,
+		Value:   balance,
+		Address: campaign.RefundAddress,
+	}
+	if err := bc.sendCampaignTransfer(campaign, transfer); err != nil {
+		return nil, err
+	}
+	bc.logger.Info(fmt.Sprintf("withdrew %d iotas of campaign %s to %s", balance, campaign.ID.Hex(), campaign.RefundAddress))
+	return transfer, nil
+}
+
+// campaignAccount returns the running account of the given campaign, starting it if needed.
+func (bc *BountyCtrl) campaignAccount(campaign *models.Campaign) (*campaignAccount, error) {
+	if ca, ok := bc.campaignAccounts[campaign.ID]; ok {
+		return ca, nil
+	}
+	ca := &campaignAccount{}
+	acc, err := bc.LoadAccountForSending(bc.Deriver.Derive(uint64(campaign.SeedIndex)), func(bundleHash string) error {
+		ca.sending.BundleHash = bundleHash
+		return bc.campaignOutbox().update(ca.sending.ID, bson.D{{"bundle_hash", bundleHash}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := acc.Start(); err != nil {
+		return nil, err
+	}
+	ca.Account = acc
+	bc.campaignAccounts[campaign.ID] = ca
+	return ca, nil
+}
+
+// sendCampaignTransfer persists the given transfer in the campaign outbox, signs and sends it off.
+func (bc *BountyCtrl) sendCampaignTransfer(campaign *models.Campaign, transfer *models.CampaignTransfer) error {
+	transfer.Model = models.Model{CreatedOn: time.Now()}
+	transfer.ID = primitive.NewObjectID()
+	transfer.CampaignID = campaign.ID
+	transfer.State = models.PayoutStatePending
+	if err := bc.campaignOutbox().insert(transfer); err != nil {
+		return err
+	}
+
+	ca, err := bc.campaignAccount(campaign)
+	if err != nil {
+		return bc.failCampaignTransfer(transfer, err)
+	}
+
+	ca.sending = transfer
+	bndl, err := bc.sendFromAccount(ca.Account, &transfer.BundleHash,
+		account.Recipient{Address: transfer.Address, Value: transfer.Value, Tag: bundle.PadTag("IOTABOUNTY")})
+	ca.sending = nil
+	if sendUnverified(err) {
+		return err
+	}
+	if err != nil {
+		return bc.failCampaignTransfer(transfer, err)
+	}
+	if bndl != nil {
+		transfer.BundleHash = bndl[0].Bundle
+	}
+	if err := bc.markCampaignTransferSent(transfer); err != nil {
+		return err
+	}
+
+	// the funding address was used as input and is now spent
+	fundingAddr, err := newDepositAddress(ca.Account)
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't renew funding address of campaign %s: %s", campaign.ID.Hex(), err.Error()))
+		fundingAddr = campaign.FundingAddress
+	}
+	balance := uint64(0)
+	if campaign.Balance > transfer.Value {
+		balance = campaign.Balance - transfer.Value
+	}
+	mut := bson.D{{"$set", bson.D{
+		{"funding_address", fundingAddr},
+		{"balance", balance},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.CampaignColl.UpdateOne(DefaultCtx(), bson.D{{"_id", campaign.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(campaign) couldn't update campaign '%s'", campaign.ID.Hex())
+	}
+	campaign.FundingAddress = fundingAddr
+	campaign.Balance = balance
+	return nil
+}
+
+func (bc *BountyCtrl) markCampaignTransferSent(transfer *models.CampaignTransfer) error {
+	t, err := bc.campaignOutbox().markSent(transfer.ID, bson.D{{"bundle_hash", transfer.BundleHash}})
+	transfer.State = models.PayoutStateSent
+	transfer.SentOn = &t
+	return err
+}
+
+func (bc *BountyCtrl) failCampaignTransfer(transfer *models.CampaignTransfer, cause error) error {
+	transfer.State = models.PayoutStateFailed
+	return bc.campaignOutbox().fail(transfer.ID, cause)
+}
+
+// ResumeCampaignTransfers resumes the campaign transfers which were interrupted by a crash like ResumePayouts.
+// The accounts of campaigns with sent transfers are started so that their promoter keeps promoting them.
+func (bc *BountyCtrl) ResumeCampaignTransfers() {
+	transfers, err := bc.getCampaignTransfers(bson.D{{"state", bson.D{{"$in", activePayoutStates}}}})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load campaign transfers to resume: %s", err.Error()))
+		return
+	}
+
+	for i := range transfers {
+		if err := bc.resumeCampaignTransfer(&transfers[i]); err != nil {
+			bc.logger.Error(fmt.Sprintf("can't resume campaign transfer %s: %s", transfers[i].ID.Hex(), err.Error()))
+		}
+	}
+}
+
+func (bc *BountyCtrl) resumeCampaignTransfer(transfer *models.CampaignTransfer) error {
+	campaign, err := bc.GetCampaignByID(transfer.CampaignID)
+	if err != nil {
+		return err
+	}
+	ca, err := bc.campaignAccount(campaign)
+	if err != nil {
+		return err
+	}
+	if transfer.State != models.PayoutStatePending {
+		return nil
+	}
+
+	sent, err := bc.isBundleOnTangle(transfer.BundleHash)
+	if err == nil && !sent {
+		sent, err = bc.isBundleInAccount(ca.Account, transfer.BundleHash)
+	}
+	if err != nil {
+		return err
+	}
+	if !sent {
+		transfer.State = models.PayoutStateFailed
+		bc.campaignOutbox().failInterrupted(transfer.ID)
+		return nil
+	}
+	bc.logger.Info(fmt.Sprintf("finalizing interrupted campaign transfer %s", transfer.ID.Hex()))
+	return bc.markCampaignTransferSent(transfer)
+}
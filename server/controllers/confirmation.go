@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/api"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const defaultStuckAfterMinutes = 60
+
+// GetPayouts returns all payouts, optionally filtered by the given state.
+func (bc *BountyCtrl) GetPayouts(state *models.PayoutState) ([]models.Payout, error) {
+	filter := bson.D{}
+	if state != nil {
+		filter = append(filter, bson.E{"state", *state})
+	}
+	return bc.getPayouts(filter)
+}
+
+// GetStuckPayouts returns the sent payouts which didn't confirm within the configured amount of minutes.
+func (bc *BountyCtrl) GetStuckPayouts() ([]models.Payout, error) {
+	return bc.getPayouts(bson.D{
+		{"state", models.PayoutStateSent},
+		{"sent_on", bson.D{{"$lt", bc.stuckThreshold()}}},
+	})
+}
+
+// stuckThreshold returns the point in time before which sent payouts should have confirmed.
+func (bc *BountyCtrl) stuckThreshold() time.Time {
+	stuckAfter := bc.Config.Payouts.StuckAfterMinutes
+	if stuckAfter <= 0 {
+		stuckAfter = defaultStuckAfterMinutes
+	}
+	return time.Now().Add(-time.Duration(stuckAfter) * time.Minute)
+}
+
+// TrackPayouts checks the inclusion states of all sent payouts and marks them (and their bounties) as confirmed.
+func (bc *BountyCtrl) TrackPayouts() {
+	payouts, err := bc.getPayouts(bson.D{{"state", models.PayoutStateSent}})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load sent payouts for confirmation tracking: %s", err.Error()))
+		return
+	}
+
+	for i := range payouts {
+		if err := bc.trackPayout(&payouts[i]); err != nil {
+			bc.logger.Error(fmt.Sprintf("can't track payout %s of bounty %d: %s", payouts[i].ID.Hex(), payouts[i].BountyID, err.Error()))
+		}
+	}
+}
+
+func (bc *BountyCtrl) trackPayout(payout *models.Payout) error {
+	tails, err := bc.collectTailHashes(payout)
+	if err != nil {
+		return err
+	}
+	payout.TailHashes = tails
+
+	if len(tails) == 0 {
+		return bc.updatePayout(payout.ID, bson.D{{"last_checked_on", time.Now()}})
+	}
+
+	states, err := bc.iotaAPI.GetLatestInclusion(tails)
+	if err != nil {
+		return err
+	}
+
+	var confirmed bool
+	for _, state := range states {
+		if state {
+			confirmed = true
+			break
+		}
+	}
+
+	t := time.Now()
+	if !confirmed {
+		if payout.Kind == models.PayoutKindSweep {
+			if tails, err = bc.reattachSweep(payout, tails); err != nil {
+				bc.logger.Warn(fmt.Sprintf("can't reattach sweep %s: %s", payout.ID.Hex(), err.Error()))
+			}
+		}
+		return bc.updatePayout(payout.ID, bson.D{
+			{"tail_hashes", tails},
+			{"last_checked_on", t},
+		})
+	}
+
+	payout.State = models.PayoutStateConfirmed
+	payout.ConfirmedOn = &t
+	if err := bc.updatePayout(payout.ID, bson.D{
+		{"state", payout.State},
+		{"tail_hashes", tails},
+		{"confirmed_on", t},
+		{"last_checked_on", t},
+	}); err != nil {
+		return err
+	}
+	bc.logger.Info(fmt.Sprintf("payout %s of bounty %d confirmed", payout.ID.Hex(), payout.BountyID))
+
+	changes := []models.AuditChange{auditChange("payout_state", models.PayoutStateSent, models.PayoutStateConfirmed)}
+	if payout.Kind == models.PayoutKindTransfer {
+		if err := bc.updateState(payout.BountyID, models.BountyStateConfirmed); err != nil {
+			return err
+		}
+		changes = append(changes, auditChange("state", models.BountyStateTransferred, models.BountyStateConfirmed))
+	}
+	bc.audit(payout.BountyID, models.AuditEventPayoutConfirmed, PlatformActor, fmt.Sprintf("payout %s", payout.ID.Hex()), changes...)
+
+	bounty, err := bc.GetByID(payout.BountyID)
+	if err != nil {
+		return err
+	}
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return err
+	}
+	return bc.Bot.PostPayoutConfirmedMessage(repo.Owner, repo.Name, bounty, payout)
+}
+
+// collectTailHashes gathers the tail transactions of the payout's bundle, including the ones
+// of reattachments made by the promoter, from the tangle and the account store.
+func (bc *BountyCtrl) collectTailHashes(payout *models.Payout) (trinary.Hashes, error) {
+	tails := trinary.Hashes{}
+	known := map[string]bool{}
+	add := func(hash string) {
+		if hash == "" || known[hash] {
+			return
+		}
+		known[hash] = true
+		tails = append(tails, hash)
+	}
+
+	for _, tail := range payout.TailHashes {
+		add(tail)
+	}
+	add(payout.TailHash)
+
+	if payout.BundleHash == "" {
+		return tails, nil
+	}
+
+	txs, err := bc.iotaAPI.FindTransactionObjects(api.FindTransactionsQuery{Bundles: trinary.Hashes{payout.BundleHash}})
+	if err != nil {
+		return nil, err
+	}
+	for i := range txs {
+		if txs[i].CurrentIndex == 0 {
+			add(txs[i].Hash)
+		}
+	}
+	return tails, nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var ErrDeadlineInPast = errors.New("the deadline must be in the future")
+var ErrBountyExpired = errors.New("the bounty's deadline has passed")
+
+// SetDeadline sets or extends the deadline of the given bounty. An expired bounty is reopened
+// (or put back into the released state if a receiver was already set).
+func (bc *BountyCtrl) SetDeadline(bounty *models.Bounty, deadline time.Time, actor *models.Actor) error {
+	if bounty.Settled() {
+		return ErrBountyAlreadySettled
+	}
+
+	if !deadline.After(time.Now()) {
+		return ErrDeadlineInPast
+	}
+
+	state := bounty.State
+	if state == models.BountyStateExpired {
+		state = reopenedState(bounty)
+	}
+
+	mut := bson.D{
+		{"$set", bson.D{
+			{"deadline", deadline},
+			{"state", state},
+			{"reminders_sent", []int{}},
+			{"model.updated_on", time.Now()},
+		}},
+		{"$unset", bson.D{{"expired_on", ""}}},
+	}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update deadline of bounty '%d'", bounty.ID)
+	}
+	bc.audit(bounty.ID, models.AuditEventDeadlineSet, actor, "",
+		auditChange("deadline", bounty.Deadline, &deadline),
+		auditChange("state", bounty.State, state),
+	)
+	bounty.Deadline = &deadline
+	bounty.State = state
+	bounty.RemindersSent = []int{}
+	bounty.ExpiredOn = nil
+	return nil
+}
+
+// checkDeadline posts reminders before the deadline of the given bounty, expires it once the deadline
+// has passed and refunds it after the configured grace period.
+func (bc *BountyCtrl) checkDeadline(bounty *models.Bounty, repo *models.Repository) error {
+	if bounty.Deadline == nil || bounty.Settled() {
+		return nil
+	}
+
+	now := time.Now()
+
+	if bounty.State == models.BountyStateExpired {
+		refundAfter := bc.Config.Deadline.RefundAfterHours
+		if refundAfter <= 0 || bounty.ExpiredOn == nil {
+			return nil
+		}
+		if now.Before(bounty.ExpiredOn.Add(time.Duration(refundAfter) * time.Hour)) {
+			return nil
+		}
+		bc.logger.Info(fmt.Sprintf("refunding expired bounty %d/%s", bounty.ID, bounty.Title))
+		_, err := bc.Cancel(bounty.ID, PlatformActor, repo)
+		return err
+	}
+
+	if now.After(*bounty.Deadline) {
+		mut := bson.D{{"$set", bson.D{
+			{"state", models.BountyStateExpired},
+			{"expired_on", now},
+			{"model.updated_on", now},
+		}}}
+		if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+			return errors.Wrapf(err, "(bounty) couldn't expire bounty '%d'", bounty.ID)
+		}
+		bc.audit(bounty.ID, models.AuditEventExpired, PlatformActor, "",
+			auditChange("state", bounty.State, models.BountyStateExpired),
+			auditChange("expired_on", nil, now),
+		)
+		return bc.Bot.PostBountyExpiredMessage(repo.Owner, repo.Name, bounty)
+	}
+
+	// only post a single reminder even if multiple reminder thresholds were crossed since the last sync
+	remaining := bounty.Deadline.Sub(now)
+	sent := map[int]bool{}
+	for _, hours := range bounty.RemindersSent {
+		sent[hours] = true
+	}
+	var due []int
+	for _, hours := range bc.Config.Deadline.ReminderHours {
+		if !sent[hours] && remaining <= time.Duration(hours)*time.Hour {
+			due = append(due, hours)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	if err := bc.Bot.PostBountyDeadlineReminderMessage(repo.Owner, repo.Name, bounty); err != nil {
+		return err
+	}
+	mut := bson.D{{"$push", bson.D{{"reminders_sent", bson.D{{"$each", due}}}}}}
+	_, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut)
+	return errors.Wrapf(err, "(bounty) couldn't update sent reminders of bounty '%d'", bounty.ID)
+}
+
+// reopenedState returns the state a bounty falls back to when it becomes active again.
+func reopenedState(bounty *models.Bounty) models.BountyState {
+	if bounty.ReceiverID != 0 {
+		return models.BountyStateReleased
+	}
+	return models.BountyStateOpen
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"gopkg.in/inconshreveable/log15.v2"
+	"sync"
+	"time"
+)
+
+// amount of recent events kept to resume interrupted streams
+const liveEventBacklogSize = 1000
+
+// amount of events buffered per subscriber before it is dropped as too slow
+const liveEventSubscriberBuffer = 64
+
+// EventCtrl distributes the changes of bounties and repositories to the subscribed live streams.
+// Events are only held in memory, a stream can be resumed as long as the missed events are in the backlog.
+type EventCtrl struct {
+	logger log15.Logger
+	mu     sync.Mutex
+	// the ID of the last published event
+	lastID      uint64
+	backlog     []models.LiveEvent
+	subscribers map[*EventSubscription]struct{}
+}
+
+// EventSubscription receives the events published after it was created. C is closed when
+// the subscription ends, either through Unsubscribe or because the subscriber fell behind.
+type EventSubscription struct {
+	C            <-chan models.LiveEvent
+	c            chan models.LiveEvent
+	repositoryID int64
+}
+
+func (ec *EventCtrl) Init() error {
+	logger, err := misc.GetLogger("event-ctrl")
+	if err != nil {
+		return err
+	}
+	ec.logger = logger
+
+	// IDs continue above the ones of a previous run, so that streams of clients which
+	// reconnect after a restart are detected as not resumable instead of skipping events
+	ec.lastID = uint64(time.Now().UnixNano() / int64(time.Microsecond))
+	ec.backlog = make([]models.LiveEvent, 0, liveEventBacklogSize)
+	ec.subscribers = map[*EventSubscription]struct{}{}
+	return nil
+}
+
+// Publish assigns the next ID to the event and sends it to all subscribers of its repository.
+func (ec *EventCtrl) Publish(event models.LiveEvent) {
+	// controllers used outside of the server have no event controller
+	if ec == nil {
+		return
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.lastID++
+	event.ID = ec.lastID
+	event.CreatedOn = time.Now()
+
+	if len(ec.backlog) == liveEventBacklogSize {
+		copy(ec.backlog, ec.backlog[1:])
+		ec.backlog = ec.backlog[:liveEventBacklogSize-1]
+	}
+	ec.backlog = append(ec.backlog, event)
+
+	for sub := range ec.subscribers {
+		if !sub.matches(&event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			// the client reconnects and resumes from the backlog
+			ec.logger.Warn(fmt.Sprintf("dropping live event subscriber which fell behind at event %d", event.ID))
+			ec.unsubscribe(sub)
+		}
+	}
+}
+
+// Subscribe creates a subscription to the events of the given repository, or of all repositories if the ID is 0.
+// If lastEventID is set, the events published after it are returned to be sent ahead of the subscription's events.
+// If the missed events are no longer in the backlog, a single reset event is returned instead.
+func (ec *EventCtrl) Subscribe(repositoryID int64, lastEventID uint64) (*EventSubscription, []models.LiveEvent) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	c := make(chan models.LiveEvent, liveEventSubscriberBuffer)
+	sub := &EventSubscription{C: c, c: c, repositoryID: repositoryID}
+	ec.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 || lastEventID == ec.lastID {
+		return sub, nil
+	}
+
+	// the backlog doesn't reach back far enough or the ID is of another run
+	if lastEventID > ec.lastID || len(ec.backlog) == 0 || lastEventID < ec.backlog[0].ID-1 {
+		reset := models.LiveEvent{ID: ec.lastID, Kind: models.LiveEventReset, CreatedOn: time.Now()}
+		return sub, []models.LiveEvent{reset}
+	}
+
+	missed := []models.LiveEvent{}
+	for i := range ec.backlog {
+		if ec.backlog[i].ID > lastEventID && sub.matches(&ec.backlog[i]) {
+			missed = append(missed, ec.backlog[i])
+		}
+	}
+	return sub, missed
+}
+
+// Unsubscribe ends the given subscription.
+func (ec *EventCtrl) Unsubscribe(sub *EventSubscription) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.unsubscribe(sub)
+}
+
+func (ec *EventCtrl) unsubscribe(sub *EventSubscription) {
+	if _, ok := ec.subscribers[sub]; !ok {
+		return
+	}
+	delete(ec.subscribers, sub)
+	close(sub.c)
+}
+
+func (sub *EventSubscription) matches(event *models.LiveEvent) bool {
+	return sub.repositoryID == 0 || sub.repositoryID == event.RepositoryID
+}
+
+// publishBounty announces the given change of the bounty with its current state and balance.
+func (bc *BountyCtrl) publishBounty(kind models.LiveEventKind, bounty *models.Bounty, state models.BountyState, balance uint64, milestone ...int) {
+	event := models.LiveEvent{
+		Kind:         kind,
+		RepositoryID: bounty.RepositoryID,
+		BountyID:     bounty.ID,
+		State:        &state,
+		Balance:      &balance,
+	}
+	if len(milestone) > 0 {
+		event.Milestone = milestone[0]
+	}
+	bc.Events.Publish(event)
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"gopkg.in/inconshreveable/log15.v2"
+	"reflect"
+	"testing"
+)
+
+func newTestEventCtrl(lastID uint64) *EventCtrl {
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+	return &EventCtrl{
+		logger:      logger,
+		lastID:      lastID,
+		backlog:     make([]models.LiveEvent, 0, liveEventBacklogSize),
+		subscribers: map[*EventSubscription]struct{}{},
+	}
+}
+
+func eventIDs(events []models.LiveEvent) []uint64 {
+	ids := []uint64{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestEventSubscribeResumesFromBacklog(t *testing.T) {
+	ec := newTestEventCtrl(100)
+	for _, repoID := range []int64{1, 2, 1, 1} {
+		ec.Publish(models.LiveEvent{Kind: models.LiveEventBountyReleased, RepositoryID: repoID})
+	}
+
+	tests := []struct {
+		name         string
+		repositoryID int64
+		lastEventID  uint64
+		want         []uint64
+	}{
+		{"new stream", 0, 0, []uint64{}},
+		{"up to date", 0, 104, []uint64{}},
+		{"missed events of all repositories", 0, 102, []uint64{103, 104}},
+		{"missed events of a repository", 1, 101, []uint64{103, 104}},
+		{"whole backlog", 2, 100, []uint64{102}},
+	}
+	for _, test := range tests {
+		sub, missed := ec.Subscribe(test.repositoryID, test.lastEventID)
+		if got := eventIDs(missed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got events %v, want %v", test.name, got, test.want)
+		}
+		ec.Unsubscribe(sub)
+	}
+}
+
+func TestEventSubscribeResets(t *testing.T) {
+	ec := newTestEventCtrl(100)
+
+	// nothing was published in this run
+	if _, missed := ec.Subscribe(0, 50); len(missed) != 1 || missed[0].Kind != models.LiveEventReset {
+		t.Errorf("empty backlog: got events %+v, want a reset", missed)
+	}
+
+	for i := 0; i < liveEventBacklogSize+10; i++ {
+		ec.Publish(models.LiveEvent{Kind: models.LiveEventBountyReleased, RepositoryID: 1})
+	}
+	if len(ec.backlog) != liveEventBacklogSize {
+		t.Fatalf("got a backlog of %d events, want %d", len(ec.backlog), liveEventBacklogSize)
+	}
+	if first := ec.backlog[0].ID; first != 111 {
+		t.Errorf("got first backlog event %d, want 111", first)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID uint64
+		reset       bool
+	}{
+		{"fell out of the backlog", 109, true},
+		{"ID of a later run", ec.lastID + 1, true},
+		{"right before the backlog", 110, false},
+	}
+	for _, test := range tests {
+		_, missed := ec.Subscribe(0, test.lastEventID)
+		isReset := len(missed) == 1 && missed[0].Kind == models.LiveEventReset
+		if isReset != test.reset {
+			t.Errorf("%s: got reset %v, want %v", test.name, isReset, test.reset)
+		}
+		if isReset && missed[0].ID != ec.lastID {
+			t.Errorf("%s: got reset at %d, want %d", test.name, missed[0].ID, ec.lastID)
+		}
+	}
+}
+
+func TestEventPublish(t *testing.T) {
+	ec := newTestEventCtrl(0)
+	all, _ := ec.Subscribe(0, 0)
+	repo, _ := ec.Subscribe(1, 0)
+	other, _ := ec.Subscribe(2, 0)
+
+	ec.Publish(models.LiveEvent{Kind: models.LiveEventBountyReleased, RepositoryID: 1})
+
+	for name, sub := range map[string]*EventSubscription{"all": all, "repository": repo} {
+		select {
+		case event := <-sub.C:
+			if event.ID != 1 {
+				t.Errorf("%s: got event %d, want 1", name, event.ID)
+			}
+		default:
+			t.Errorf("%s: didn't receive the event", name)
+		}
+	}
+	select {
+	case event := <-other.C:
+		t.Errorf("other repository: received event %+v", event)
+	default:
+	}
+
+	ec.Unsubscribe(repo)
+	if _, ok := <-repo.C; ok {
+		t.Errorf("the channel of an ended subscription isn't closed")
+	}
+	// ending a subscription twice is harmless
+	ec.Unsubscribe(repo)
+}
+
+func TestEventPublishDropsSlowSubscribers(t *testing.T) {
+	ec := newTestEventCtrl(0)
+	sub, _ := ec.Subscribe(0, 0)
+
+	for i := 0; i < liveEventSubscriberBuffer+1; i++ {
+		ec.Publish(models.LiveEvent{Kind: models.LiveEventBountyReleased, RepositoryID: 1})
+	}
+	if _, ok := ec.subscribers[sub]; ok {
+		t.Fatalf("the subscriber which fell behind wasn't dropped")
+	}
+
+	var received int
+	for range sub.C {
+		received++
+	}
+	if received != liveEventSubscriberBuffer {
+		t.Errorf("got %d buffered events, want %d", received, liveEventSubscriberBuffer)
+	}
+
+	// the dropped subscriber resumes from the backlog
+	_, missed := ec.Subscribe(0, uint64(received))
+	if len(missed) != 1 || missed[0].ID != ec.lastID {
+		t.Errorf("got missed events %v, want [%d]", eventIDs(missed), ec.lastID)
+	}
+
+	// controllers used outside of the server have no event controller
+	var noEvents *EventCtrl
+	noEvents.Publish(models.LiveEvent{})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var ErrInvalidFundingNoticeSettings = errors.New("invalid funding notice settings")
+
+// fundingNoticeSettings returns the threshold and quiet period of the funding notices of the given repository.
+// The repository settings override the config ones.
+func (bc *BountyCtrl) fundingNoticeSettings(repo *models.Repository) (uint64, time.Duration) {
+	threshold := bc.Config.FundingNotices.Threshold
+	if repo.Settings.FundingNoticeThreshold != nil {
+		threshold = *repo.Settings.FundingNoticeThreshold
+	}
+	quietMinutes := bc.Config.FundingNotices.QuietMinutes
+	if repo.Settings.FundingNoticeQuietMinutes != nil {
+		quietMinutes = *repo.Settings.FundingNoticeQuietMinutes
+	}
+	return threshold, time.Duration(quietMinutes) * time.Minute
+}
+
+// checkFundingNotice announces on the issue when the balance of the given unsettled bounty increased by at least
+// the threshold since the last announcement. Increases within the quiet period of the last announcement update
+// the existing comment instead of posting a new one. A threshold of 0 disables the announcements.
+func (bc *BountyCtrl) checkFundingNotice(bounty *models.Bounty, repo *models.Repository, balance uint64) error {
+	notice := bounty.FundingNotice
+	if notice == nil {
+		// bounties which were never announced measure from their last synced balance
+		notice = &models.FundingNotice{From: bounty.Balance, To: bounty.Balance}
+	}
+	next := *notice
+
+	threshold, quietPeriod := bc.fundingNoticeSettings(repo)
+	switch {
+	case balance < notice.To:
+		// funds left the pool address, increases are measured from the lower balance
+		next.From, next.To = balance, balance
+	case threshold > 0 && balance-notice.To >= threshold:
+		now := time.Now()
+		folded := false
+		if notice.CommentID != 0 && now.Before(notice.PostedOn.Add(quietPeriod)) {
+			err := bc.Bot.UpdateFundingNoticeMessage(repo.Owner, repo.Name, bounty, notice.CommentID, notice.From, balance)
+			if err != nil {
+				bc.logger.Warn(fmt.Sprintf("unable to update funding notice of bounty %d, posting a new one: %s", bounty.ID, err.Error()))
+			}
+			folded = err == nil
+		}
+		if folded {
+			next.To = balance
+			break
+		}
+		commentID, err := bc.Bot.PostFundingNoticeMessage(repo.Owner, repo.Name, bounty, notice.To, balance)
+		if err != nil {
+			return err
+		}
+		next = models.FundingNotice{CommentID: commentID, From: notice.To, To: balance, PostedOn: now}
+	}
+
+	if bounty.FundingNotice != nil && next == *bounty.FundingNotice {
+		return nil
+	}
+	mut := bson.D{{"$set", bson.D{{"funding_notice", next}}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update funding notice of bounty '%d'", bounty.ID)
+	}
+	bounty.FundingNotice = &next
+	return nil
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/units"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMilestones = errors.New("invalid milestones")
+var ErrMilestonesLocked = errors.New("the milestones can't be changed once one of them was released")
+var ErrMilestoneNotFound = errors.New("the bounty has no such milestone")
+var ErrMilestoneAlreadyPaid = errors.New("the milestone was already paid out")
+var ErrMilestoneNotReleased = errors.New("the milestone hasn't been released")
+var ErrMilestoneBountyNotOpen = errors.New("milestones can only be released while the bounty is open")
+var ErrMilestoneExceedsBalance = errors.New("the share of the milestone exceeds the balance of the bounty")
+
+// matches task list items which end with the share of the milestone, e.g. "- [ ] backend (40%)" or "- [x] docs (500 Mi)"
+var milestoneTaskRegex = regexp.MustCompile(`^\s*[-*]\s+\[[ xX]\]\s+(.+?)\s*\(\s*(\d+(?:\.\d+)?)\s*(%|i|Ki|Mi|Gi|Ti|Pi)\s*\)\s*$`)
+
+var milestoneUnits = map[string]units.Unit{
+	"i": units.I, "Ki": units.Ki, "Mi": units.Mi, "Gi": units.Gi, "Ti": units.Ti, "Pi": units.Pi,
+}
+
+// ParseMilestones extracts the milestones from the task list of the given issue body.
+// Task items without a share are ignored.
+func ParseMilestones(body string) []models.Milestone {
+	milestones := []models.Milestone{}
+	for _, line := range strings.Split(body, "\n") {
+		match := milestoneTaskRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+		amount, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		milestone := models.Milestone{Title: match[1]}
+		if match[3] == "%" {
+			milestone.Percent = amount
+		} else {
+			milestone.Value = uint64(units.ConvertUnits(amount, milestoneUnits[match[3]], units.I))
+		}
+		milestones = append(milestones, milestone)
+	}
+	return milestones
+}
+
+// validateMilestones ensures that each milestone defines exactly one of a value or a percentage
+// and that the percentages don't exceed the total funding of the bounty.
+func validateMilestones(milestones []models.Milestone) error {
+	var percent float64
+	for i := range milestones {
+		milestone := &milestones[i]
+		if strings.TrimSpace(milestone.Title) == "" {
+			return errors.Wrapf(ErrInvalidMilestones, "milestone %d has no title", i+1)
+		}
+		if (milestone.Value == 0) == (milestone.Percent == 0) || milestone.Percent < 0 {
+			return errors.Wrapf(ErrInvalidMilestones, "milestone %d must define either a value or a percentage", i+1)
+		}
+		percent += milestone.Percent
+	}
+	if percent > 100 {
+		return errors.Wrapf(ErrInvalidMilestones, "the percentages add up to %.2f%%", percent)
+	}
+	return nil
+}
+
+// milestonesLocked tells whether a milestone of the bounty was already released or paid out.
+func milestonesLocked(bounty *models.Bounty) bool {
+	for i := range bounty.Milestones {
+		if bounty.Milestones[i].State != models.MilestoneStateOpen {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMilestones replaces the milestones of the given bounty on behalf of the given actor.
+// Milestones set through the API take precedence over the task list of the issue.
+func (bc *BountyCtrl) SetMilestones(bounty *models.Bounty, milestones []models.Milestone, actor *models.Actor) error {
+	if bounty.Settled() {
+		return ErrBountyAlreadySettled
+	}
+	if milestonesLocked(bounty) {
+		return ErrMilestonesLocked
+	}
+	for i := range milestones {
+		milestones[i] = models.Milestone{Title: milestones[i].Title, Value: milestones[i].Value, Percent: milestones[i].Percent}
+	}
+	if err := validateMilestones(milestones); err != nil {
+		return err
+	}
+	return bc.updateMilestones(bounty, milestones, models.MilestoneSourceAPI, actor)
+}
+
+// syncIssueMilestones updates the milestones of the given bounty from the task list of its issue, unless they
+// were set through the API or one of them was already released. Invalid task lists are ignored.
+func (bc *BountyCtrl) syncIssueMilestones(bounty *models.Bounty, body string) error {
+	if bounty.MilestoneSource == models.MilestoneSourceAPI || milestonesLocked(bounty) {
+		return nil
+	}
+	milestones := ParseMilestones(body)
+	if len(milestones) == 0 && len(bounty.Milestones) == 0 {
+		return nil
+	}
+	if err := validateMilestones(milestones); err != nil {
+		bc.logger.Warn(fmt.Sprintf("ignoring milestones of the issue of bounty %d: %s", bounty.ID, err.Error()))
+		return nil
+	}
+	if milestonesEqual(bounty.Milestones, milestones) {
+		return nil
+	}
+	return bc.updateMilestones(bounty, milestones, models.MilestoneSourceIssue, PlatformActor)
+}
+
+func milestonesEqual(a []models.Milestone, b []models.Milestone) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (bc *BountyCtrl) updateMilestones(bounty *models.Bounty, milestones []models.Milestone, source models.MilestoneSource, actor *models.Actor) error {
+	mut := bson.D{{"$set", bson.D{
+		{"milestones", milestones},
+		{"milestone_source", source},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update milestones of bounty '%d'", bounty.ID)
+	}
+	bc.audit(bounty.ID, models.AuditEventMilestonesSet, actor, string(source),
+		auditChange("milestones", bounty.Milestones, milestones),
+	)
+	bounty.Milestones = milestones
+	bounty.MilestoneSource = source
+	return nil
+}
+
+// milestoneShare computes the iotas of the given milestone. Percentages refer to the total funding
+// of the bounty, which is its balance plus the iotas already paid out through milestones.
+func milestoneShare(bounty *models.Bounty, milestone *models.Milestone, balance uint64) uint64 {
+	if milestone.Value > 0 {
+		return milestone.Value
+	}
+	return percentageOf(balance+bounty.PaidOut, milestone.Percent)
+}
+
+// getMilestone returns the milestone with the given number (starting at 1) of the bounty.
+func getMilestone(bounty *models.Bounty, number int) (*models.Milestone, error) {
+	if number < 1 || number > len(bounty.Milestones) {
+		return nil, ErrMilestoneNotFound
+	}
+	return &bounty.Milestones[number-1], nil
+}
+
+// ReleasedMilestoneOf returns the number of the released but not yet paid out milestone of the given receiver.
+func ReleasedMilestoneOf(bounty *models.Bounty, receiverID int64) (int, bool) {
+	for i := range bounty.Milestones {
+		milestone := &bounty.Milestones[i]
+		if milestone.State == models.MilestoneStateReleased && milestone.ReceiverID == receiverID {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// ReleaseMilestone releases the milestone with the given number to the given receiver on behalf of the given actor.
+// The receiver of a released milestone can be changed by releasing it again.
+func (bc *BountyCtrl) ReleaseMilestone(bounty *models.Bounty, number int, receiverID int64, actor *models.Actor) error {
+	if bounty.State == models.BountyStateExpired {
+		return ErrBountyExpired
+	}
+	if bounty.State != models.BountyStateOpen {
+		return ErrMilestoneBountyNotOpen
+	}
+	milestone, err := getMilestone(bounty, number)
+	if err != nil {
+		return err
+	}
+	if milestone.State == models.MilestoneStatePaid {
+		return ErrMilestoneAlreadyPaid
+	}
+
+	// a payout requested by the previous receiver must not be approved anymore
+	if err := bc.withdrawPayoutOfOtherReceiver(bounty, receiverID, number, actor); err != nil {
+		return err
+	}
+
+	field := fmt.Sprintf("milestones.%d.", number-1)
+	mut := bson.D{{"$set", bson.D{
+		{field + "state", models.MilestoneStateReleased},
+		{field + "receiver_id", receiverID},
+		{field + "released_by", actor.GitHubID},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't release milestone %d of bounty '%d'", number, bounty.ID)
+	}
+
+	bc.audit(bounty.ID, models.AuditEventMilestoneReleased, actor, fmt.Sprintf("milestone %d", number),
+		auditChange("state", milestone.State, models.MilestoneStateReleased),
+		auditChange("receiver_id", milestone.ReceiverID, receiverID),
+		auditChange("released_by", milestone.ReleasedBy, actor.GitHubID),
+	)
+	bc.publishBounty(models.LiveEventBountyReleased, bounty, bounty.State, bounty.Balance, number)
+	milestone.State = models.MilestoneStateReleased
+	milestone.ReceiverID = receiverID
+	milestone.ReleasedBy = actor.GitHubID
+	return nil
+}
+
+// TransferMilestone sends the share of the released milestone to the given address posted by its receiver (the actor).
+// The rest of the funds remains in the pool of the bounty.
+func (bc *BountyCtrl) TransferMilestone(bounty *models.Bounty, number int, addr string, actor *models.Actor) (*models.Payout, error) {
+	milestone, err := getMilestone(bounty, number)
+	if err != nil {
+		return nil, err
+	}
+	if milestone.State != models.MilestoneStateReleased {
+		return nil, ErrMilestoneNotReleased
+	}
+
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// refuse to send if another payout is still in flight or awaiting approval
+	if err := bc.checkNoActivePayout(bounty.ID); err != nil {
+		return nil, err
+	}
+
+	availBalance, err := bc.GetAccountBalance(bounty.Seed)
+	if err != nil {
+		return nil, err
+	}
+	if availBalance == 0 {
+		return nil, ErrBountyAddrEmpty
+	}
+
+	value := milestoneShare(bounty, milestone, availBalance)
+	if value > availBalance {
+		return nil, ErrMilestoneExceedsBalance
+	}
+
+	split, err := bc.computePayoutSplit(value, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &models.Payout{
+		Kind:            models.PayoutKindMilestone,
+		Milestone:       number,
+		Value:           value,
+		ReceiverAddress: addr,
+		ReceiverID:      milestone.ReceiverID,
+		Split:           split,
+		RequestedBy:     milestone.ReleasedBy,
+	}
+	bc.audit(bounty.ID, models.AuditEventTransferAttempted, actor, fmt.Sprintf("milestone %d", number),
+		auditChange("receiver_address", nil, addr),
+		auditChange("value", nil, value),
+	)
+
+	// high-value payouts are held until a second person approves them
+	if bc.requiresApproval(payout) {
+		if err := bc.holdForApproval(bounty, payout); err != nil {
+			return nil, err
+		}
+		bc.audit(bounty.ID, models.AuditEventTransferHeld, actor, fmt.Sprintf("payout %s awaits approval", payout.ID.Hex()))
+		return payout, nil
+	}
+
+	if err := bc.sendPayout(bounty, payout, payoutRecipients(split, addr)); err != nil {
+		bc.audit(bounty.ID, models.AuditEventTransferFailed, actor, err.Error())
+		return nil, err
+	}
+
+	if err := bc.finalizePayout(payout); err != nil {
+		return nil, err
+	}
+	bc.auditPayoutSent(bounty, payout, actor)
+	bc.renewPoolAddress(bounty)
+
+	return payout, nil
+}
+
+// renewPoolAddress allocates a new pool address for the given bounty after a milestone payout spent the current one.
+// Failures are retried by the next sync of the bounty.
+func (bc *BountyCtrl) renewPoolAddress(bounty *models.Bounty) {
+	bounty.PoolAddressSpent = true
+	poolAddr, err := bc.allocateDepositAddress(bounty.Seed)
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to allocate a new pool address for bounty %d: %s", bounty.ID, err.Error()))
+		return
+	}
+
+	mut := bson.D{
+		{"$set", bson.D{
+			{"pool_address", poolAddr},
+			{"model.updated_on", time.Now()},
+		}},
+		{"$unset", bson.D{{"pool_address_spent", ""}}},
+		{"$push", bson.D{{"spent_pool_addresses", bounty.PoolAddress}}},
+	}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to store the new pool address of bounty %d: %s", bounty.ID, err.Error()))
+		return
+	}
+	bc.audit(bounty.ID, models.AuditEventPoolAddressRenewed, PlatformActor, "",
+		auditChange("pool_address", bounty.PoolAddress, poolAddr),
+	)
+	bounty.SpentPoolAddresses = append(bounty.SpentPoolAddresses, bounty.PoolAddress)
+	bounty.PoolAddress = poolAddr
+	bounty.PoolAddressSpent = false
+}
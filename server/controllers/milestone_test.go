@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMilestones(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []models.Milestone
+	}{
+		{
+			name: "percentages and values",
+			body: "Tasks:\r\n- [ ] backend (40%)\r\n* [x] frontend ( 2.5 Mi )\r\n  - [X] docs (500i)\r\n",
+			want: []models.Milestone{
+				{Title: "backend", Percent: 40},
+				{Title: "frontend", Value: 2500000},
+				{Title: "docs", Value: 500},
+			},
+		},
+		{
+			name: "fractional percentage",
+			body: "- [ ] tests (12.5%)",
+			want: []models.Milestone{{Title: "tests", Percent: 12.5}},
+		},
+		{
+			name: "title with parentheses",
+			body: "- [ ] port (the old one) to go (1 Ki)",
+			want: []models.Milestone{{Title: "port (the old one) to go", Value: 1000}},
+		},
+		{
+			name: "items without a share are ignored",
+			body: "- [ ] backend\n- [ ] frontend (soon)\n- backend (40%)\n[ ] docs (10%)\n- [ ] tests (10 Xi)",
+			want: []models.Milestone{},
+		},
+		{
+			name: "no task list",
+			body: "",
+			want: []models.Milestone{},
+		},
+	}
+	for _, test := range tests {
+		if got := ParseMilestones(test.body); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got milestones %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidateMilestones(t *testing.T) {
+	tests := []struct {
+		name       string
+		milestones []models.Milestone
+		valid      bool
+	}{
+		{"none", []models.Milestone{}, true},
+		{"percentages up to 100%", []models.Milestone{{Title: "a", Percent: 60}, {Title: "b", Percent: 40}}, true},
+		{"values and percentages", []models.Milestone{{Title: "a", Percent: 60}, {Title: "b", Value: 1000}}, true},
+		{"percentages above 100%", []models.Milestone{{Title: "a", Percent: 60}, {Title: "b", Percent: 41}}, false},
+		{"no title", []models.Milestone{{Title: " ", Percent: 10}}, false},
+		{"no share", []models.Milestone{{Title: "a"}}, false},
+		{"value and percentage", []models.Milestone{{Title: "a", Value: 10, Percent: 10}}, false},
+		{"negative percentage", []models.Milestone{{Title: "a", Percent: -10}}, false},
+	}
+	for _, test := range tests {
+		err := validateMilestones(test.milestones)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && errors.Cause(err) != ErrInvalidMilestones {
+			t.Errorf("%s: got error %v, want %v", test.name, err, ErrInvalidMilestones)
+		}
+	}
+}
+
+func TestMilestoneShare(t *testing.T) {
+	bounty := &models.Bounty{PaidOut: 400}
+	// percentages refer to the total funding, including what was already paid out
+	if share := milestoneShare(bounty, &models.Milestone{Percent: 50}, 600); share != 500 {
+		t.Errorf("percentage: got share %d, want 500", share)
+	}
+	if share := milestoneShare(bounty, &models.Milestone{Value: 123}, 600); share != 123 {
+		t.Errorf("value: got share %d, want 123", share)
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/nodes"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"gopkg.in/inconshreveable/log15.v2"
+	"time"
+)
+
+const defaultNodeCheckSeconds = 30
+const nodeRequestTimeoutSeconds = 10
+
+type NodeCtrl struct {
+	Config *config.Configuration `inject:""`
+	Pool   *nodes.Pool
+	logger log15.Logger
+}
+
+func (nc *NodeCtrl) Init() error {
+	logger, err := misc.GetLogger("node-ctrl")
+	if err != nil {
+		return err
+	}
+	nc.logger = logger
+
+	nc.Pool, err = newNodePool(nc.Config)
+	if err != nil {
+		return err
+	}
+
+	// run the first check synchronously so that the initial API calls go to a healthy node
+	nc.check()
+	go nc.checkPeriodically()
+	return nil
+}
+
+func newNodePool(conf *config.Configuration) (*nodes.Pool, error) {
+	return nodes.NewPool(conf.Account.NodeURIs(), conf.Account.MaxMilestoneLag, time.Duration(nodeRequestTimeoutSeconds)*time.Second)
+}
+
+func (nc *NodeCtrl) checkPeriodically() {
+	interval := nc.Config.Account.NodeCheckSeconds
+	if interval <= 0 {
+		interval = defaultNodeCheckSeconds
+	}
+	for {
+		time.Sleep(time.Duration(interval) * time.Second)
+		nc.check()
+	}
+}
+
+func (nc *NodeCtrl) check() {
+	nc.Pool.Check()
+	var usable int
+	for _, status := range nc.Pool.Status() {
+		switch {
+		case !status.Healthy:
+			nc.logger.Warn(fmt.Sprintf("node %s is unhealthy: %s", status.URI, status.LastError))
+		case !status.Synced:
+			nc.logger.Warn(fmt.Sprintf("node %s is out of sync (milestone %d, solid milestone %d)",
+				status.URI, status.LatestMilestoneIndex, status.LatestSolidSubtangleMilestoneIndex))
+		default:
+			usable++
+		}
+	}
+	if usable == 0 {
+		nc.logger.Error("no healthy and synced IOTA node available")
+	}
+}
+
+// Status returns the health state of all configured nodes.
+func (nc *NodeCtrl) Status() []nodes.Status {
+	return nc.Pool.Status()
+}
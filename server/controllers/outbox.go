@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/account"
+	"github.com/iotaledger/iota.go/api"
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/inconshreveable/log15.v2"
+	"time"
+)
+
+// outbox holds the outgoing transfers of the platform. An entry is persisted as pending before its bundle
+// is signed and the bundle hash is stored before the bundle is attached to the tangle, so that an entry
+// interrupted by a crash is verified against the tangle and the account store instead of being sent twice.
+type outbox struct {
+	coll *mongo.Collection
+	// the name of the entries in errors and logs
+	name   string
+	logger log15.Logger
+}
+
+// payoutOutbox holds the payouts of bounties.
+func (bc *BountyCtrl) payoutOutbox() *outbox {
+	return &outbox{coll: bc.PayoutColl, name: "payout", logger: bc.logger}
+}
+
+// campaignOutbox holds the transfers of campaign accounts.
+func (bc *BountyCtrl) campaignOutbox() *outbox {
+	return &outbox{coll: bc.CampaignTransferColl, name: "campaign transfer", logger: bc.logger}
+}
+
+func (o *outbox) insert(entry interface{}) error {
+	_, err := o.coll.InsertOne(DefaultCtx(), entry)
+	return errors.Wrapf(err, "(outbox) couldn't insert %s", o.name)
+}
+
+func (o *outbox) update(id primitive.ObjectID, fields bson.D) error {
+	fields = append(fields, bson.E{"model.updated_on", time.Now()})
+	_, err := o.coll.UpdateOne(DefaultCtx(), bson.D{{"_id", id}}, bson.D{{"$set", fields}})
+	return errors.Wrapf(err, "(outbox) couldn't update %s '%s'", o.name, id.Hex())
+}
+
+// markSent moves the entry into the sent state, alongside the given fields, and returns the time it was sent on.
+func (o *outbox) markSent(id primitive.ObjectID, fields bson.D) (time.Time, error) {
+	t := time.Now()
+	fields = append(bson.D{{"state", models.PayoutStateSent}, {"sent_on", t}}, fields...)
+	return t, o.update(id, fields)
+}
+
+// fail marks the entry as failed if its bundle can't have left the platform and returns the cause.
+func (o *outbox) fail(id primitive.ObjectID, cause error) error {
+	if err := o.update(id, bson.D{
+		{"state", models.PayoutStateFailed},
+		{"error", cause.Error()},
+	}); err != nil {
+		o.logger.Error(fmt.Sprintf("couldn't mark %s %s as failed: %s", o.name, id.Hex(), err.Error()))
+	}
+	return cause
+}
+
+// failInterrupted marks an entry whose bundle never left the platform before a crash as failed.
+func (o *outbox) failInterrupted(id primitive.ObjectID) {
+	o.logger.Warn(fmt.Sprintf("%s %s never left the platform, marking it as failed", o.name, id.Hex()))
+	if err := o.fail(id, errors.New("interrupted before the bundle was sent")); err != nil {
+		o.logger.Warn(err.Error())
+	}
+}
+
+// errSendUnverified is returned when sending an entry failed and it couldn't be verified whether its bundle
+// left the platform. The entry then stays pending until it is resumed against the tangle and the account store.
+var errSendUnverified = errors.New("couldn't verify whether the bundle was sent")
+
+// sendUnverified tells whether the given error left its entry pending instead of failed.
+func sendUnverified(err error) bool {
+	return errors.Cause(err) == errSendUnverified
+}
+
+// sendFromAccount sends the recipients off with the given account. bundleHash must point to where the entry's
+// bundle hash is stored once the bundle is signed. No bundle is returned if broadcasting failed after the bundle
+// was stored in the account, as the entry then counts as sent.
+func (bc *BountyCtrl) sendFromAccount(acc account.Account, bundleHash *string, recipients ...account.Recipient) (bundle.Bundle, error) {
+	bndl, err := acc.Send(recipients...)
+	if err == nil {
+		return bndl, nil
+	}
+	// the bundle might have been stored in the account before broadcasting failed,
+	// in which case the promoter takes care of it and the entry must not be retried
+	stored, checkErr := bc.isBundleInAccount(acc, *bundleHash)
+	if checkErr != nil {
+		bc.logger.Error(fmt.Sprintf("can't verify bundle %s after sending failed: %s", *bundleHash, checkErr.Error()))
+		return nil, errors.Wrap(errSendUnverified, err.Error())
+	}
+	if !stored {
+		return nil, err
+	}
+	bc.logger.Warn(fmt.Sprintf("broadcasting bundle %s failed but it is stored in the account: %s", *bundleHash, err.Error()))
+	return nil, nil
+}
+
+// isBundleOnTangle tells whether the bundle with the given hash was attached to the tangle.
+func (bc *BountyCtrl) isBundleOnTangle(bundleHash string) (bool, error) {
+	// interrupted before the bundle was signed
+	if bundleHash == "" {
+		return false, nil
+	}
+	txHashes, err := bc.iotaAPI.FindTransactions(api.FindTransactionsQuery{Bundles: trinary.Hashes{bundleHash}})
+	if err != nil {
+		return false, err
+	}
+	return len(txHashes) > 0, nil
+}
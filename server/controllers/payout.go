@@ -0,0 +1,444 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/account"
+	"github.com/iotaledger/iota.go/account/store"
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math"
+	"math/big"
+	"time"
+)
+
+var ErrInvalidFeeSettings = errors.New("invalid fee settings")
+
+const percentBasisPoints = 100
+const fullBasisPoints = 100 * percentBasisPoints
+
+// computePayoutSplit splits the given value into the platform fee, the maintainer tip and the rest
+// for the receiver of the bounty. The platform fee of the repository settings overrides the config one.
+func (bc *BountyCtrl) computePayoutSplit(value uint64, repo *models.Repository) (*models.PayoutSplit, error) {
+	feePercent := bc.Config.Fees.PlatformFeePercent
+	if repo.Settings.PlatformFeePercent != nil {
+		feePercent = *repo.Settings.PlatformFeePercent
+	}
+	tipPercent := repo.Settings.MaintainerTipPercent
+
+	if err := validateFeePercentages(feePercent, tipPercent); err != nil {
+		return nil, err
+	}
+
+	split := &models.PayoutSplit{Total: value}
+	if feePercent > 0 {
+		if bc.Config.Fees.TreasuryAddress == "" {
+			return nil, errors.Wrap(ErrInvalidFeeSettings, "no treasury address configured for the platform fee")
+		}
+		split.Fee = percentageOf(value, feePercent)
+		split.FeeAddress = bc.Config.Fees.TreasuryAddress
+	}
+	if tipPercent > 0 {
+		if repo.Settings.MaintainerTipAddress == "" {
+			return nil, errors.Wrap(ErrInvalidFeeSettings, "no maintainer tip address set on the repository")
+		}
+		split.Tip = percentageOf(value, tipPercent)
+		split.TipAddress = repo.Settings.MaintainerTipAddress
+	}
+	split.Receiver = value - split.Fee - split.Tip
+	return split, nil
+}
+
+func validateFeePercentages(feePercent float64, tipPercent float64) error {
+	if feePercent < 0 || tipPercent < 0 || feePercent+tipPercent >= 100 {
+		return ErrInvalidFeeSettings
+	}
+	return nil
+}
+
+// percentageOf computes the given percentage (rounded to basis points) of the value, rounding down.
+func percentageOf(value uint64, percent float64) uint64 {
+	bps := new(big.Int).SetUint64(uint64(math.Round(percent * percentBasisPoints)))
+	share := new(big.Int).SetUint64(value)
+	share.Mul(share, bps).Div(share, big.NewInt(fullBasisPoints))
+	return share.Uint64()
+}
+
+// payoutRecipients converts the payout split into the recipients of a single bundle.
+func payoutRecipients(split *models.PayoutSplit, receiverAddr string) account.Recipients {
+	tag := bundle.PadTag("IOTABOUNTY")
+	recipients := account.Recipients{{Address: receiverAddr, Value: split.Receiver, Tag: tag}}
+	if split.Fee > 0 {
+		recipients = append(recipients, account.Recipient{Address: split.FeeAddress, Value: split.Fee, Tag: tag})
+	}
+	if split.Tip > 0 {
+		recipients = append(recipients, account.Recipient{Address: split.TipAddress, Value: split.Tip, Tag: tag})
+	}
+	return recipients
+}
+
+var ErrPayoutAlreadySent = errors.New("a payout was already sent for this bounty")
+var ErrPayoutPending = errors.New("a payout of this bounty is pending and must be resolved first")
+
+// outbox entries which might still need to be finalized
+var activePayoutStates = bson.A{models.PayoutStatePending, models.PayoutStateSent}
+
+// outbox entries which block new payouts of the same bounty
+var blockingPayoutStates = bson.A{
+	models.PayoutStatePending, models.PayoutStateSent, models.PayoutStateConfirmed, models.PayoutStateAwaitingApproval,
+}
+
+// GetActivePayout returns the pending, sent, confirmed or awaiting approval payout of the given bounty if there is one.
+// Sent milestone payouts don't block further payouts, as the rest of the funds remains in the pool.
+func (bc *BountyCtrl) GetActivePayout(bountyID int64) (*models.Payout, error) {
+	res := bc.PayoutColl.FindOne(DefaultCtx(), bson.D{
+		{"bounty_id", bountyID},
+		{"state", bson.D{{"$in", blockingPayoutStates}}},
+		{"$or", bson.A{
+			bson.D{{"kind", bson.D{{"$ne", models.PayoutKindMilestone}}}},
+			bson.D{{"state", bson.D{{"$in", bson.A{models.PayoutStatePending, models.PayoutStateAwaitingApproval}}}}},
+		}},
+	})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	payout := &models.Payout{}
+	err := res.Decode(payout)
+	return payout, errors.Wrapf(err, "(payout) couldn't load active payout of bounty '%d'", bountyID)
+}
+
+func (bc *BountyCtrl) GetPayoutsOfBounty(bountyID int64) ([]models.Payout, error) {
+	return bc.getPayouts(bson.D{{"bounty_id", bountyID}})
+}
+
+func (bc *BountyCtrl) getPayouts(filter bson.D) ([]models.Payout, error) {
+	payouts := []models.Payout{}
+	cursor, err := bc.PayoutColl.Find(DefaultCtx(), filter)
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(DefaultCtx()) {
+		var payout models.Payout
+		if err := cursor.Decode(&payout); err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts, errors.Wrap(err, "(payout) couldn't load payouts")
+}
+
+// checkNoActivePayout ensures that no other payout of the given bounty is in flight or already sent.
+func (bc *BountyCtrl) checkNoActivePayout(bountyID int64) error {
+	payout, err := bc.GetActivePayout(bountyID)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch payout.State {
+	case models.PayoutStatePending:
+		return ErrPayoutPending
+	case models.PayoutStateAwaitingApproval:
+		return ErrPayoutAwaitingApproval
+	}
+	return ErrPayoutAlreadySent
+}
+
+func (bc *BountyCtrl) updatePayout(id primitive.ObjectID, fields bson.D) error {
+	return bc.payoutOutbox().update(id, fields)
+}
+
+// sendPayout persists the given payout in the outbox, signs and sends it off.
+func (bc *BountyCtrl) sendPayout(bounty *models.Bounty, payout *models.Payout, recipients account.Recipients) error {
+	if err := bc.checkNoActivePayout(bounty.ID); err != nil {
+		return err
+	}
+
+	if err := bc.insertPayout(bounty, payout); err != nil {
+		return err
+	}
+
+	return bc.dispatchPayout(bounty, payout, recipients)
+}
+
+// dispatchPayout signs and sends off the given pending payout.
+func (bc *BountyCtrl) dispatchPayout(bounty *models.Bounty, payout *models.Payout, recipients account.Recipients) error {
+	acc, err := bc.LoadAccountForSending(bounty.Seed, func(bundleHash string) error {
+		payout.BundleHash = bundleHash
+		return bc.updatePayout(payout.ID, bson.D{{"bundle_hash", bundleHash}})
+	})
+	if err != nil {
+		return bc.failPayout(payout, err)
+	}
+	if err := acc.Start(); err != nil {
+		return bc.failPayout(payout, err)
+	}
+
+	bndl, err := bc.sendFromAccount(acc, &payout.BundleHash, recipients...)
+	if sendUnverified(err) {
+		return err
+	}
+	if err != nil {
+		return bc.failPayout(payout, err)
+	}
+	if bndl != nil {
+		payout.BundleHash = bndl[0].Bundle
+		payout.TailHash = bndl[0].Hash
+		payout.TailHashes = []string{payout.TailHash}
+	}
+	return bc.markPayoutSent(payout)
+}
+
+// insertPayout persists the given payout as pending (unless another state is set) in the outbox.
+func (bc *BountyCtrl) insertPayout(bounty *models.Bounty, payout *models.Payout) error {
+	payout.ID = primitive.NewObjectID()
+	payout.BountyID = bounty.ID
+	if payout.State != models.PayoutStateAwaitingApproval {
+		payout.State = models.PayoutStatePending
+	}
+	payout.Model = models.Model{CreatedOn: time.Now()}
+	return bc.payoutOutbox().insert(payout)
+}
+
+func (bc *BountyCtrl) markPayoutSent(payout *models.Payout) error {
+	if payout.TailHashes == nil {
+		payout.TailHashes = []string{}
+	}
+	t, err := bc.payoutOutbox().markSent(payout.ID, bson.D{
+		{"bundle_hash", payout.BundleHash},
+		{"tail_hash", payout.TailHash},
+		{"tail_hashes", payout.TailHashes},
+	})
+	payout.State = models.PayoutStateSent
+	payout.SentOn = &t
+	return err
+}
+
+func (bc *BountyCtrl) failPayout(payout *models.Payout, cause error) error {
+	payout.State = models.PayoutStateFailed
+	return bc.payoutOutbox().fail(payout.ID, cause)
+}
+
+func (bc *BountyCtrl) isBundleInAccount(acc account.Account, bundleHash string) (bool, error) {
+	if bundleHash == "" {
+		return false, nil
+	}
+	pendingTransfers, err := bc.store.GetPendingTransfers(acc.ID())
+	if err != nil {
+		return false, err
+	}
+	for _, pendingTransfer := range pendingTransfers {
+		bndl, err := store.PendingTransferToBundle(pendingTransfer)
+		if err != nil {
+			return false, err
+		}
+		if bndl[0].Bundle == bundleHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// finalizePayout applies the sent payout onto its bounty.
+func (bc *BountyCtrl) finalizePayout(payout *models.Payout) error {
+	t := time.Now()
+	var mut bson.D
+	switch payout.Kind {
+	case models.PayoutKindTransfer:
+		mut = bson.D{{"$set", bson.D{
+			{"state", models.BountyStateTransferred},
+			{"receiver_address", payout.ReceiverAddress},
+			{"bundle_hash", payout.BundleHash},
+			{"balance", payout.Value},
+			{"payout", payout.Split},
+			{"model.updated_on", t},
+		}}}
+	case models.PayoutKindRefund:
+		mut = bson.D{{"$set", bson.D{
+			{"state", models.BountyStateRefunded},
+			{"refunds", payout.Refunds},
+			{"refund_bundle_hash", payout.BundleHash},
+			{"balance", payout.Value},
+			{"model.updated_on", t},
+		}}}
+	case models.PayoutKindSweep:
+		mut = bson.D{
+			{"$set", bson.D{
+				{"late_balance", 0},
+				{"late_balance_notified", 0},
+				{"model.updated_on", t},
+			}},
+			{"$addToSet", bson.D{{"sweep_bundle_hashes", payout.BundleHash}}},
+		}
+	case models.PayoutKindMilestone:
+		field := fmt.Sprintf("milestones.%d.", payout.Milestone-1)
+		mut = bson.D{
+			{"$set", bson.D{
+				{field + "state", models.MilestoneStatePaid},
+				{field + "paid", payout.Value},
+				{field + "bundle_hash", payout.BundleHash},
+				{"pool_address_spent", true},
+				{"model.updated_on", t},
+			}},
+			{"$inc", bson.D{{"paid_out", payout.Value}}},
+		}
+	}
+	_, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", payout.BountyID}}, mut)
+	return errors.Wrapf(err, "(bounty) couldn't apply payout '%s' on bounty '%d'", payout.ID.Hex(), payout.BountyID)
+}
+
+// auditPayoutSent records the changes finalizePayout applied onto the bounty of the given payout
+// and announces them to the live streams.
+func (bc *BountyCtrl) auditPayoutSent(bounty *models.Bounty, payout *models.Payout, actor *models.Actor) {
+	note := fmt.Sprintf("payout %s", payout.ID.Hex())
+	switch payout.Kind {
+	case models.PayoutKindTransfer:
+		bc.audit(bounty.ID, models.AuditEventTransferSent, actor, note,
+			auditChange("state", bounty.State, models.BountyStateTransferred),
+			auditChange("receiver_address", bounty.ReceiverAddress, payout.ReceiverAddress),
+			auditChange("bundle_hash", bounty.BundleHash, payout.BundleHash),
+			auditChange("balance", bounty.Balance, payout.Value),
+		)
+		bc.publishBounty(models.LiveEventBountyTransferred, bounty, models.BountyStateTransferred, payout.Value)
+	case models.PayoutKindRefund:
+		bc.audit(bounty.ID, models.AuditEventCancelled, actor, note,
+			auditChange("state", bounty.State, models.BountyStateRefunded),
+			auditChange("refund_bundle_hash", bounty.RefundBundleHash, payout.BundleHash),
+			auditChange("balance", bounty.Balance, payout.Value),
+		)
+		bc.publishBounty(models.LiveEventBountyRefunded, bounty, models.BountyStateRefunded, payout.Value)
+	case models.PayoutKindSweep:
+		bc.audit(bounty.ID, models.AuditEventLateDepositsSwept, actor, note,
+			auditChange("late_balance", bounty.LateBalance, 0),
+			auditChange("sweep_bundle_hash", nil, payout.BundleHash),
+			auditChange("sweep_target", nil, payout.SweepTarget),
+		)
+	case models.PayoutKindMilestone:
+		bc.audit(bounty.ID, models.AuditEventMilestonePaid, actor, fmt.Sprintf("%s, milestone %d", note, payout.Milestone),
+			auditChange("state", models.MilestoneStateReleased, models.MilestoneStatePaid),
+			auditChange("bundle_hash", nil, payout.BundleHash),
+			auditChange("paid_out", bounty.PaidOut, bounty.PaidOut+payout.Value),
+		)
+		bc.publishBounty(models.LiveEventBountyTransferred, bounty, bounty.State, bounty.Balance, payout.Milestone)
+	}
+}
+
+// ResumePayouts verifies payouts which were interrupted by a crash or whose sending couldn't be verified
+// against the tangle and the account store and finalizes their bounties.
+// Payouts whose bundle never left the platform are marked as failed.
+func (bc *BountyCtrl) ResumePayouts() {
+	payouts, err := bc.getPayouts(bson.D{{"state", bson.D{{"$in", activePayoutStates}}}})
+	if err != nil {
+		bc.logger.Error(fmt.Sprintf("can't load payouts to resume: %s", err.Error()))
+		return
+	}
+
+	for i := range payouts {
+		if err := bc.resumePayout(&payouts[i]); err != nil {
+			bc.logger.Error(fmt.Sprintf("can't resume payout %s of bounty %d: %s", payouts[i].ID.Hex(), payouts[i].BountyID, err.Error()))
+		}
+	}
+}
+
+func (bc *BountyCtrl) resumePayout(payout *models.Payout) error {
+	bounty, err := bc.GetByID(payout.BountyID)
+	if err != nil {
+		return err
+	}
+
+	if payout.State == models.PayoutStatePending {
+		sent, err := bc.verifyPendingPayout(bounty, payout)
+		if err != nil {
+			return err
+		}
+		if !sent {
+			payout.State = models.PayoutStateFailed
+			bc.payoutOutbox().failInterrupted(payout.ID)
+			bc.audit(bounty.ID, models.AuditEventTransferFailed, PlatformActor,
+				fmt.Sprintf("payout %s was interrupted before the bundle was sent", payout.ID.Hex()))
+			// let the interrupted cancellation be retried
+			if bounty.State == models.BountyStateRefunding {
+				return bc.updateState(bounty.ID, reopenedState(bounty))
+			}
+			return nil
+		}
+		if err := bc.markPayoutSent(payout); err != nil {
+			return err
+		}
+	}
+
+	// the bounty is already up to date
+	if payout.Kind == models.PayoutKindSweep {
+		if containsHash(bounty.SweepBundleHashes, payout.BundleHash) {
+			return nil
+		}
+	} else if payout.Kind == models.PayoutKindMilestone {
+		if milestone, err := getMilestone(bounty, payout.Milestone); err != nil || milestone.State == models.MilestoneStatePaid {
+			return err
+		}
+	} else if bounty.Settled() && bounty.State != models.BountyStateRefunding {
+		return nil
+	}
+
+	bc.logger.Info(fmt.Sprintf("finalizing interrupted payout %s of bounty %d", payout.ID.Hex(), bounty.ID))
+	if err := bc.finalizePayout(payout); err != nil {
+		return err
+	}
+	bc.auditPayoutSent(bounty, payout, PlatformActor)
+	if payout.Kind == models.PayoutKindMilestone {
+		bc.renewPoolAddress(bounty)
+	}
+
+	repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+	if err != nil {
+		return err
+	}
+	switch payout.Kind {
+	case models.PayoutKindTransfer:
+		return bc.Bot.PostBountySentMessage(repo.Owner, repo.Name, bounty, payout.Split, payout.BundleHash)
+	case models.PayoutKindRefund:
+		return bc.Bot.PostBountyRefundedMessage(repo.Owner, repo.Name, bounty, payout.Refunds, payout.Value, payout.BundleHash)
+	case models.PayoutKindSweep:
+		return bc.Bot.PostLateDepositsSweptMessage(repo.Owner, repo.Name, bounty, payout)
+	case models.PayoutKindMilestone:
+		return bc.Bot.PostMilestoneSentMessage(repo.Owner, repo.Name, bounty, payout)
+	}
+	return nil
+}
+
+func containsHash(hashes []string, hash string) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPendingPayout checks whether the bundle of a pending payout exists on the tangle or in the account store.
+func (bc *BountyCtrl) verifyPendingPayout(bounty *models.Bounty, payout *models.Payout) (bool, error) {
+	onTangle, err := bc.isBundleOnTangle(payout.BundleHash)
+	if err != nil || onTangle || payout.BundleHash == "" {
+		return onTangle, err
+	}
+
+	// sweeps are sent without the account
+	if payout.Kind == models.PayoutKindSweep {
+		return false, nil
+	}
+
+	// starting the account lets the promoter reattach the stored bundle
+	acc, err := bc.LoadAccountForSending(bounty.Seed, nil)
+	if err != nil {
+		return false, err
+	}
+	stored, err := bc.isBundleInAccount(acc, payout.BundleHash)
+	if err != nil || !stored {
+		return false, err
+	}
+	return true, acc.Start()
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+const testTreasuryAddr = "TREASURY"
+const testTipAddr = "TIP"
+
+func TestPercentageOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   uint64
+		percent float64
+		want    uint64
+	}{
+		{"zero value", 0, 5, 0},
+		{"zero percent", 1000, 0, 0},
+		{"whole percent", 1000, 5, 50},
+		{"basis points", 10000, 0.01, 1},
+		{"rounds down", 999, 5, 49},
+		{"percent rounded to basis points", 1000000, 1.23456, 12300},
+		{"full", 1000, 100, 1000},
+		{"no overflow", 1 << 62, 50, 1 << 61},
+	}
+	for _, test := range tests {
+		if got := percentageOf(test.value, test.percent); got != test.want {
+			t.Errorf("%s: percentageOf(%d, %v) = %d, want %d", test.name, test.value, test.percent, got, test.want)
+		}
+	}
+}
+
+func TestComputePayoutSplit(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name      string
+		value     uint64
+		configFee float64
+		treasury  string
+		settings  models.RepositorySettings
+		want      *models.PayoutSplit
+		wantErr   error
+	}{
+		{
+			name:  "no fees",
+			value: 1000,
+			want:  &models.PayoutSplit{Total: 1000, Receiver: 1000},
+		},
+		{
+			name:      "config fee",
+			value:     1000,
+			configFee: 5,
+			treasury:  testTreasuryAddr,
+			want:      &models.PayoutSplit{Total: 1000, Fee: 50, FeeAddress: testTreasuryAddr, Receiver: 950},
+		},
+		{
+			name:      "repository fee overrides config fee",
+			value:     1000,
+			configFee: 5,
+			treasury:  testTreasuryAddr,
+			settings:  models.RepositorySettings{PlatformFeePercent: percent(0)},
+			want:      &models.PayoutSplit{Total: 1000, Receiver: 1000},
+		},
+		{
+			name:      "rounding remainders go to the receiver",
+			value:     999,
+			configFee: 3.33,
+			treasury:  testTreasuryAddr,
+			settings:  models.RepositorySettings{MaintainerTipPercent: 3.33, MaintainerTipAddress: testTipAddr},
+			want: &models.PayoutSplit{
+				Total: 999, Fee: 33, FeeAddress: testTreasuryAddr, Tip: 33, TipAddress: testTipAddr, Receiver: 933,
+			},
+		},
+		{
+			name:      "zero total",
+			value:     0,
+			configFee: 5,
+			treasury:  testTreasuryAddr,
+			settings:  models.RepositorySettings{MaintainerTipPercent: 5, MaintainerTipAddress: testTipAddr},
+			want:      &models.PayoutSplit{FeeAddress: testTreasuryAddr, TipAddress: testTipAddr},
+		},
+		{
+			name:      "fee and tip of 100%",
+			value:     1000,
+			configFee: 60,
+			treasury:  testTreasuryAddr,
+			settings:  models.RepositorySettings{MaintainerTipPercent: 40, MaintainerTipAddress: testTipAddr},
+			wantErr:   ErrInvalidFeeSettings,
+		},
+		{
+			name:      "fee of 100%",
+			value:     1000,
+			configFee: 100,
+			treasury:  testTreasuryAddr,
+			wantErr:   ErrInvalidFeeSettings,
+		},
+		{
+			name:     "negative fee",
+			value:    1000,
+			treasury: testTreasuryAddr,
+			settings: models.RepositorySettings{PlatformFeePercent: percent(-1)},
+			wantErr:  ErrInvalidFeeSettings,
+		},
+		{
+			name:      "fee without treasury",
+			value:     1000,
+			configFee: 5,
+			wantErr:   ErrInvalidFeeSettings,
+		},
+		{
+			name:     "tip without address",
+			value:    1000,
+			settings: models.RepositorySettings{MaintainerTipPercent: 5},
+			wantErr:  ErrInvalidFeeSettings,
+		},
+	}
+	for _, test := range tests {
+		bc := &BountyCtrl{Config: &config.Configuration{
+			Fees: config.FeeConfig{PlatformFeePercent: test.configFee, TreasuryAddress: test.treasury},
+		}}
+		split, err := bc.computePayoutSplit(test.value, &models.Repository{Settings: test.settings})
+		if test.wantErr != nil {
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if *split != *test.want {
+			t.Errorf("%s: got split %+v, want %+v", test.name, *split, *test.want)
+		}
+		if split.Fee+split.Tip+split.Receiver != split.Total {
+			t.Errorf("%s: split %+v doesn't add up to the total", test.name, *split)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/price"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"gopkg.in/inconshreveable/log15.v2"
+	"math"
+	"sync"
+	"time"
+)
+
+const defaultPriceRefreshSeconds = 300
+
+// rates older than this amount of refresh intervals are no longer shown
+const staleRatesIntervals = 3
+
+type PriceCtrl struct {
+	Config *config.Configuration `inject:""`
+	// the source of the rates, defaults to the HTTP provider if a price URL is configured
+	Provider price.Provider
+	logger   log15.Logger
+	mu       sync.RWMutex
+	rates    *price.Rates
+}
+
+func (pc *PriceCtrl) Init() error {
+	logger, err := misc.GetLogger("price-ctrl")
+	if err != nil {
+		return err
+	}
+	pc.logger = logger
+
+	if pc.Provider == nil && pc.Config.Price.URL != "" {
+		pc.Provider = price.NewHTTPProvider(pc.Config.Price.URL, pc.Config.Price.KeyPath)
+	}
+	if pc.Provider == nil {
+		return nil
+	}
+
+	go pc.refreshPeriodically()
+	return nil
+}
+
+func (pc *PriceCtrl) refreshInterval() time.Duration {
+	interval := pc.Config.Price.RefreshSeconds
+	if interval <= 0 {
+		interval = defaultPriceRefreshSeconds
+	}
+	return time.Duration(interval) * time.Second
+}
+
+func (pc *PriceCtrl) refreshPeriodically() {
+	for {
+		pc.refresh()
+		time.Sleep(pc.refreshInterval())
+	}
+}
+
+func (pc *PriceCtrl) refresh() {
+	rates, err := pc.Provider.Rates()
+	if err != nil {
+		pc.logger.Warn(fmt.Sprintf("unable to refresh IOTA rates: %s", err.Error()))
+		return
+	}
+	pc.mu.Lock()
+	pc.rates = rates
+	pc.mu.Unlock()
+	pc.logger.Debug(fmt.Sprintf("refreshed IOTA rates: %f USD, %f EUR", rates.USD, rates.EUR))
+}
+
+// Rates returns the cached rates or nil if there are none or they are outdated.
+func (pc *PriceCtrl) Rates() *price.Rates {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if pc.rates == nil || time.Since(pc.rates.UpdatedOn) > staleRatesIntervals*pc.refreshInterval() {
+		return nil
+	}
+	return pc.rates
+}
+
+// Fiat returns the fiat value of the given amount of iotas or nil if no current rates are available.
+func (pc *PriceCtrl) Fiat(value uint64) *models.FiatValue {
+	rates := pc.Rates()
+	if rates == nil {
+		return nil
+	}
+	mi := float64(value) / price.QuoteUnit
+	return &models.FiatValue{
+		USD:            roundCents(mi * rates.USD),
+		EUR:            roundCents(mi * rates.EUR),
+		RatesUpdatedOn: rates.UpdatedOn,
+	}
+}
+
+// AddFiat sets the fiat value of the balance of the given bounties.
+func (pc *PriceCtrl) AddFiat(bounties ...*models.Bounty) {
+	for _, bounty := range bounties {
+		bounty.Fiat = pc.Fiat(bounty.Balance)
+	}
+}
+
+// FormatIotas formats the given amount of iotas, including its fiat value if enabled for bot messages.
+func (pc *PriceCtrl) FormatIotas(value uint64) string {
+	if !pc.Config.Price.ShowInMessages {
+		return fmt.Sprintf("%d iotas", value)
+	}
+	fiat := pc.Fiat(value)
+	if fiat == nil {
+		return fmt.Sprintf("%d iotas", value)
+	}
+	return fmt.Sprintf("%d iotas (~$%.2f / ~€%.2f)", value, fiat.USD, fiat.EUR)
+}
+
+func roundCents(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"strings"
+	"time"
+)
+
+const DefaultBountyPageSize = 50
+const maxBountyPageSize = 500
+
+// the fields bounty listings can be sorted by and their document keys
+var bountySortFields = map[string]string{
+	"created_on": "model.created_on",
+	"updated_on": "model.updated_on",
+	"balance":    "balance",
+}
+
+// BountyQuery filters, sorts and pages a bounty listing. Unset fields don't filter.
+type BountyQuery struct {
+	States        []models.BountyState
+	RepositoryID  int64
+	MinBalance    *uint64
+	MaxBalance    *uint64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// one of the bountySortFields, prefixed with "-" for a descending order
+	Sort string
+	// the cursor returned with the previous page
+	Cursor string
+	// 0 returns all matching bounties
+	Limit int64
+}
+
+// bountyCursor marks the last bounty of a page by its sort value (dates in milliseconds) and its ID.
+type bountyCursor struct {
+	Value int64 `json:"v"`
+	ID    int64 `json:"id"`
+}
+
+func (bc *BountyCtrl) initBountyIndexes() error {
+	indexes := []mongo.IndexModel{}
+	add := func(name string, keys bsonx.Doc) {
+		indexName := name
+		indexes = append(indexes, mongo.IndexModel{Keys: keys, Options: &options.IndexOptions{Name: &indexName}})
+	}
+	for name, field := range bountySortFields {
+		keys := bsonx.Doc{{Key: field, Value: bsonx.Int32(int32(-1))}, {Key: "_id", Value: bsonx.Int32(int32(-1))}}
+		add(name, keys)
+		add("state_"+name, append(bsonx.Doc{{Key: "state", Value: bsonx.Int32(int32(1))}}, keys...))
+		add("repository_id_"+name, append(bsonx.Doc{{Key: "repository_id", Value: bsonx.Int32(int32(1))}}, keys...))
+	}
+	_, err := bc.Coll.Indexes().CreateMany(DefaultCtx(), indexes)
+	return errors.Wrap(err, "(bounty) couldn't create listing indexes")
+}
+
+// QueryBounties returns the bounties matching the given query and the cursor of the next page,
+// which is empty if there are no further bounties. The seeds of the listed bounties aren't loaded.
+func (bc *BountyCtrl) QueryBounties(query *BountyQuery) ([]models.Bounty, string, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = "-created_on"
+	}
+	dir := 1
+	if strings.HasPrefix(sort, "-") {
+		dir = -1
+		sort = sort[1:]
+	}
+	sortField, ok := bountySortFields[sort]
+	if !ok {
+		return nil, "", errors.Wrapf(ErrInvalidQuery, "can't sort by '%s'", sort)
+	}
+	if query.Limit < 0 {
+		return nil, "", errors.Wrap(ErrInvalidQuery, "negative limit")
+	}
+	if query.Limit > maxBountyPageSize {
+		query.Limit = maxBountyPageSize
+	}
+
+	filter := bountyFilter(query)
+
+	// continue after the last bounty of the previous page, the ID breaks ties of equal sort values
+	if query.Cursor != "" {
+		cursor, err := decodeBountyCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		var value interface{} = cursor.Value
+		if sortField != "balance" {
+			value = time.Unix(0, cursor.Value*int64(time.Millisecond))
+		}
+		cmp := "$gt"
+		if dir < 0 {
+			cmp = "$lt"
+		}
+		filter = append(filter, bson.E{"$or", bson.A{
+			bson.D{{sortField, bson.D{{cmp, value}}}},
+			bson.D{{sortField, value}, {"_id", bson.D{{cmp, cursor.ID}}}},
+		}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{sortField, dir}, {"_id", dir}})
+	if query.Limit > 0 {
+		// one more to know whether there is a next page
+		opts.SetLimit(query.Limit + 1)
+	}
+	res, err := bc.Coll.Find(DefaultCtx(), filter, opts)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "(bounty) couldn't query bounties")
+	}
+	bounties := []models.Bounty{}
+	for res.Next(DefaultCtx()) {
+		var bounty models.Bounty
+		if err := res.Decode(&bounty); err != nil {
+			return nil, "", err
+		}
+		bounties = append(bounties, bounty)
+	}
+	if err := res.Err(); err != nil {
+		return nil, "", errors.Wrap(err, "(bounty) couldn't query bounties")
+	}
+
+	if query.Limit == 0 || int64(len(bounties)) <= query.Limit {
+		return bounties, "", nil
+	}
+	bounties = bounties[:query.Limit]
+	next, err := encodeBountyCursor(&bounties[len(bounties)-1], sort)
+	return bounties, next, err
+}
+
+// bountyFilter converts the filters of the given query into a MongoDB filter.
+func bountyFilter(query *BountyQuery) bson.D {
+	filter := bson.D{}
+	if len(query.States) > 0 {
+		filter = append(filter, bson.E{"state", bson.D{{"$in", query.States}}})
+	}
+	if query.RepositoryID != 0 {
+		filter = append(filter, bson.E{"repository_id", query.RepositoryID})
+	}
+	if balance := rangeFilter(query.MinBalance, query.MaxBalance); len(balance) > 0 {
+		filter = append(filter, bson.E{"balance", balance})
+	}
+	if created := timeRangeFilter(query.CreatedAfter, query.CreatedBefore); len(created) > 0 {
+		filter = append(filter, bson.E{"model.created_on", created})
+	}
+	if updated := timeRangeFilter(query.UpdatedAfter, query.UpdatedBefore); len(updated) > 0 {
+		filter = append(filter, bson.E{"model.updated_on", updated})
+	}
+	return filter
+}
+
+func rangeFilter(min *uint64, max *uint64) bson.D {
+	r := bson.D{}
+	if min != nil {
+		r = append(r, bson.E{"$gte", *min})
+	}
+	if max != nil {
+		r = append(r, bson.E{"$lte", *max})
+	}
+	return r
+}
+
+func timeRangeFilter(after *time.Time, before *time.Time) bson.D {
+	r := bson.D{}
+	if after != nil {
+		r = append(r, bson.E{"$gte", *after})
+	}
+	if before != nil {
+		r = append(r, bson.E{"$lt", *before})
+	}
+	return r
+}
+
+func encodeBountyCursor(bounty *models.Bounty, sort string) (string, error) {
+	cursor := bountyCursor{ID: bounty.ID}
+	switch sort {
+	case "created_on":
+		cursor.Value = bounty.CreatedOn.UnixNano() / int64(time.Millisecond)
+	case "updated_on":
+		if bounty.UpdatedOn != nil {
+			cursor.Value = bounty.UpdatedOn.UnixNano() / int64(time.Millisecond)
+		}
+	case "balance":
+		cursor.Value = int64(bounty.Balance)
+	}
+	content, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+func decodeBountyCursor(s string) (*bountyCursor, error) {
+	content, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidQuery, "malformed cursor")
+	}
+	cursor := &bountyCursor{}
+	if err := json.Unmarshal(content, cursor); err != nil {
+		return nil, errors.Wrap(ErrInvalidQuery, "malformed cursor")
+	}
+	return cursor, nil
+}
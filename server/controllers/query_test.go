@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBountyCursor(t *testing.T) {
+	created := time.Date(2026, 10, 19, 10, 0, 0, 123456789, time.UTC)
+	updated := created.Add(time.Hour)
+	bounty := &models.Bounty{ID: 42, Balance: 1000, Model: models.Model{CreatedOn: created, UpdatedOn: &updated}}
+
+	tests := []struct {
+		sort string
+		want bountyCursor
+	}{
+		{"created_on", bountyCursor{Value: created.UnixNano() / int64(time.Millisecond), ID: 42}},
+		{"updated_on", bountyCursor{Value: updated.UnixNano() / int64(time.Millisecond), ID: 42}},
+		{"balance", bountyCursor{Value: 1000, ID: 42}},
+	}
+	for _, test := range tests {
+		encoded, err := encodeBountyCursor(bounty, test.sort)
+		if err != nil {
+			t.Fatalf("%s: couldn't encode cursor: %v", test.sort, err)
+		}
+		cursor, err := decodeBountyCursor(encoded)
+		if err != nil {
+			t.Fatalf("%s: couldn't decode cursor %s: %v", test.sort, encoded, err)
+		}
+		if *cursor != test.want {
+			t.Errorf("%s: got cursor %+v, want %+v", test.sort, *cursor, test.want)
+		}
+	}
+
+	// bounties which were never updated sort by the zero value
+	encoded, err := encodeBountyCursor(&models.Bounty{ID: 1}, "updated_on")
+	if err != nil {
+		t.Fatalf("couldn't encode cursor: %v", err)
+	}
+	if cursor, err := decodeBountyCursor(encoded); err != nil || *cursor != (bountyCursor{ID: 1}) {
+		t.Errorf("never updated: got cursor %+v (%v), want the zero value", cursor, err)
+	}
+}
+
+func TestDecodeMalformedBountyCursor(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm90IGpzb24", "eyJ2IjoiYSJ9"} {
+		if _, err := decodeBountyCursor(s); errors.Cause(err) != ErrInvalidQuery {
+			t.Errorf("%s: got error %v, want %v", s, err, ErrInvalidQuery)
+		}
+	}
+}
+
+func TestBountyFilter(t *testing.T) {
+	min, max := uint64(10), uint64(20)
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if filter := bountyFilter(&BountyQuery{}); len(filter) != 0 {
+		t.Errorf("empty query: got filter %v, want none", filter)
+	}
+
+	filter := bountyFilter(&BountyQuery{
+		States:       []models.BountyState{models.BountyStateOpen},
+		RepositoryID: 7,
+		MinBalance:   &min,
+		MaxBalance:   &max,
+		CreatedAfter: &after,
+	})
+	want := bson.D{
+		{"state", bson.D{{"$in", []models.BountyState{models.BountyStateOpen}}}},
+		{"repository_id", int64(7)},
+		{"balance", bson.D{{"$gte", min}, {"$lte", max}}},
+		{"model.created_on", bson.D{{"$gte", after}}},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("got filter %v, want %v", filter, want)
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const reconciliationCollection = "reconciliation_reports"
+
+const defaultReconciliationReports = 10
+
+// Reconcile compares the stored state of all bounties with the data on the tangle and stores
+// the found discrepancies in a new report.
+func (bc *BountyCtrl) Reconcile() (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		ID:            primitive.NewObjectID(),
+		StartedOn:     time.Now(),
+		Discrepancies: []models.Discrepancy{},
+		Errors:        []string{},
+	}
+
+	bounties, err := bc.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// bounties with a payout in flight are left out, as their pool address is expected to change
+	balances, err := bc.poolBalances(bounties)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range bounties {
+		bounty := &bounties[i]
+		discrepancies, err := bc.reconcileBounty(bounty, balances)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("bounty %d: %s", bounty.ID, err.Error()))
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+		report.BountiesChecked++
+	}
+
+	report.FinishedOn = time.Now()
+	if _, err := bc.ReportColl.InsertOne(DefaultCtx(), report); err != nil {
+		return nil, errors.Wrap(err, "(reconciliation) couldn't insert report")
+	}
+	return report, nil
+}
+
+func (bc *BountyCtrl) reconcileBounty(bounty *models.Bounty, balances map[int64]uint64) ([]models.Discrepancy, error) {
+	switch bounty.State {
+	case models.BountyStateTransferred:
+		return bc.reconcileBundle(bounty, bounty.BundleHash)
+	case models.BountyStateRefunded:
+		return bc.reconcileBundle(bounty, bounty.RefundBundleHash)
+	case models.BountyStateConfirmed, models.BountyStateRefunding:
+		return nil, nil
+	}
+
+	balance, ok := balances[bounty.ID]
+	if !ok {
+		return nil, nil
+	}
+	if discrepancy := balanceDiscrepancy(bounty, balance); discrepancy != nil {
+		return []models.Discrepancy{*discrepancy}, nil
+	}
+	return nil, nil
+}
+
+// balanceDiscrepancy compares the balance of the pool address with the confirmed contributions minus the
+// paid out value. The stored balance isn't used as the sync overwrites it with the balance on the tangle.
+func balanceDiscrepancy(bounty *models.Bounty, balance uint64) *models.Discrepancy {
+	var contributed uint64
+	for _, contribution := range bounty.Contributions {
+		contributed += contribution.Value
+	}
+	var expected uint64
+	if contributed > bounty.PaidOut {
+		expected = contributed - bounty.PaidOut
+	}
+	if balance == expected {
+		return nil
+	}
+
+	discrepancy := &models.Discrepancy{
+		BountyID: bounty.ID,
+		Kind:     models.DiscrepancyBalanceMismatch,
+		Stored:   fmt.Sprintf("%d iotas", expected),
+		OnTangle: fmt.Sprintf("%d iotas", balance),
+	}
+	if balance == 0 {
+		discrepancy.Kind = models.DiscrepancyPoolDrained
+		discrepancy.Detail = fmt.Sprintf("the pool address %s received %d iotas but is empty without a payout having been made",
+			bounty.PoolAddress[:consts.HashTrytesSize], contributed)
+	}
+	return discrepancy
+}
+
+// reconcileBundle checks whether the given payout bundle of a paid out bounty confirmed. Bundles which were sent
+// recently and bundles of payouts which were already confirmed by the tracking aren't checked against the tangle.
+func (bc *BountyCtrl) reconcileBundle(bounty *models.Bounty, bundleHash string) ([]models.Discrepancy, error) {
+	// refunds of empty bounties don't produce a bundle
+	if bundleHash == "" {
+		return nil, nil
+	}
+
+	payout := &models.Payout{}
+	err := bc.PayoutColl.FindOne(DefaultCtx(), bson.D{{"bundle_hash", bundleHash}}).Decode(payout)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// bounties paid out before payouts were recorded
+		payout = &models.Payout{BundleHash: bundleHash}
+	case err != nil:
+		return nil, errors.Wrapf(err, "(reconciliation) couldn't load payout of bundle '%s'", bundleHash)
+	case payout.State == models.PayoutStateConfirmed:
+		return nil, nil
+	case payout.SentOn != nil && payout.SentOn.After(bc.stuckThreshold()):
+		return nil, nil
+	}
+
+	tails, err := bc.collectTailHashes(payout)
+	if err != nil {
+		return nil, err
+	}
+	if len(tails) == 0 {
+		return []models.Discrepancy{{
+			BountyID: bounty.ID,
+			Kind:     models.DiscrepancyBundleNotFound,
+			Stored:   bundleHash,
+			OnTangle: "no transactions",
+		}}, nil
+	}
+
+	states, err := bc.iotaAPI.GetLatestInclusion(tails)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if state {
+			return nil, nil
+		}
+	}
+	return []models.Discrepancy{{
+		BountyID: bounty.ID,
+		Kind:     models.DiscrepancyBundleUnconfirmed,
+		Stored:   bundleHash,
+		OnTangle: fmt.Sprintf("%d unconfirmed tails", len(tails)),
+	}}, nil
+}
+
+// GetReconciliationReports returns the latest reports, newest first.
+func (bc *BountyCtrl) GetReconciliationReports(limit int64) ([]models.ReconciliationReport, error) {
+	if limit <= 0 {
+		limit = defaultReconciliationReports
+	}
+	reports := []models.ReconciliationReport{}
+	opts := options.Find().SetSort(bson.D{{"started_on", -1}}).SetLimit(limit)
+	cursor, err := bc.ReportColl.Find(DefaultCtx(), bson.D{}, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "(reconciliation) couldn't load reports")
+	}
+	for cursor.Next(DefaultCtx()) {
+		var report models.ReconciliationReport
+		if err := cursor.Decode(&report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Reconcile runs the reconciliation against the configured database and stores its report.
+func Reconcile(conf *config.Configuration, mongoClient *mongo.Client, seedVault *vault.Vault, deriver *vault.SeedDeriver) (*models.ReconciliationReport, error) {
+	pool, err := newNodePool(conf)
+	if err != nil {
+		return nil, err
+	}
+	pool.Check()
+	iotaAPI, err := newIOTAAPI(pool)
+	if err != nil {
+		return nil, err
+	}
+	db := mongoClient.Database(conf.DB.DBName)
+	bc := &BountyCtrl{
+		Config:     conf,
+		Mongo:      mongoClient,
+		Vault:      seedVault,
+		Deriver:    deriver,
+		Coll:       db.Collection(bountyCollection),
+		PayoutColl: db.Collection(payoutCollection),
+		ReportColl: db.Collection(reconciliationCollection),
+		iotaAPI:    iotaAPI,
+	}
+	return bc.Reconcile()
+}
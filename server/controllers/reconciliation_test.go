@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"strings"
+	"testing"
+)
+
+func TestBalanceDiscrepancy(t *testing.T) {
+	poolAddr := strings.Repeat("A", 90)
+
+	tests := []struct {
+		name     string
+		bounty   models.Bounty
+		balance  uint64
+		wantKind models.DiscrepancyKind
+	}{
+		{
+			name: "balance matches the contributions",
+			bounty: models.Bounty{Contributions: []models.Contribution{
+				{BundleHash: "A", Value: 100}, {BundleHash: "B", Value: 50},
+			}},
+			balance: 150,
+		},
+		{
+			// the sync stores the balance on the tangle before the reconciliation runs
+			name: "drained pool is reported although the stored balance was synced",
+			bounty: models.Bounty{
+				Balance:       0,
+				Contributions: []models.Contribution{{BundleHash: "A", Value: 100}},
+			},
+			balance:  0,
+			wantKind: models.DiscrepancyPoolDrained,
+		},
+		{
+			name: "balance differs from the contributions",
+			bounty: models.Bounty{
+				Balance:       70,
+				Contributions: []models.Contribution{{BundleHash: "A", Value: 100}},
+			},
+			balance:  70,
+			wantKind: models.DiscrepancyBalanceMismatch,
+		},
+		{
+			name: "paid out value is subtracted",
+			bounty: models.Bounty{
+				PaidOut:       60,
+				Contributions: []models.Contribution{{BundleHash: "A", Value: 100}},
+			},
+			balance: 40,
+		},
+		{
+			name:    "empty pool without contributions",
+			balance: 0,
+		},
+	}
+	for _, test := range tests {
+		test.bounty.PoolAddress = poolAddr
+		discrepancy := balanceDiscrepancy(&test.bounty, test.balance)
+		if test.wantKind == "" {
+			if discrepancy != nil {
+				t.Errorf("%s: unexpected discrepancy %+v", test.name, *discrepancy)
+			}
+			continue
+		}
+		if discrepancy == nil {
+			t.Errorf("%s: got no discrepancy, want %s", test.name, test.wantKind)
+			continue
+		}
+		if discrepancy.Kind != test.wantKind {
+			t.Errorf("%s: got discrepancy %s, want %s", test.name, discrepancy.Kind, test.wantKind)
+		}
+	}
+}
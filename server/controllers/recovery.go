@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RecoveredAccount is the balance of an account derived from the master seed.
+type RecoveredAccount struct {
+	SeedIndex int64
+	// the bounty using the seed index, 0 if none was found
+	BountyID int64
+	Deleted  bool
+	// the campaign using the seed index, empty if none was found
+	CampaignID string
+	// the addresses holding funds
+	Addresses trinary.Hashes
+	Balances  []uint64
+	Balance   uint64
+}
+
+// RecoverAccounts re-derives the seeds of all bounties from the master seed and scans the first addrCount addresses
+// of each seed (plus the recorded pool address) for funds. If indexCount is 0, all indices recorded in the database are scanned.
+func (bc *BountyCtrl) RecoverAccounts(indexCount int64, addrCount uint64) ([]RecoveredAccount, error) {
+	bounties := map[int64]*models.Bounty{}
+	deleted := map[int64]bool{}
+	var highestIndex int64 = -1
+	for _, docs := range bc.seedDocs() {
+		cursor, err := docs.coll.Find(DefaultCtx(), bson.D{{docs.prefix + "seed_index", bson.D{{"$exists", true}}}})
+		if err != nil {
+			return nil, err
+		}
+		for cursor.Next(DefaultCtx()) {
+			_, bounty, err := docs.decode(cursor)
+			if err != nil {
+				return nil, err
+			}
+			index := *bounty.SeedIndex
+			// a live bounty takes precedence over a deleted one
+			if _, has := bounties[index]; has && docs.prefix != "" {
+				continue
+			}
+			bounties[index] = bounty
+			deleted[index] = docs.prefix != ""
+			if index > highestIndex {
+				highestIndex = index
+			}
+		}
+	}
+
+	campaigns, err := bc.getCampaigns(bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	campaignsByIndex := map[int64]*models.Campaign{}
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		campaignsByIndex[campaign.SeedIndex] = campaign
+		if campaign.SeedIndex > highestIndex {
+			highestIndex = campaign.SeedIndex
+		}
+	}
+
+	if indexCount == 0 {
+		// indices might have been allocated without the bounty being stored
+		allocated, err := bc.allocatedSeedIndices()
+		if err != nil {
+			return nil, err
+		}
+		indexCount = allocated
+		if highestIndex+1 > indexCount {
+			indexCount = highestIndex + 1
+		}
+	}
+
+	secLvl := consts.SecurityLevel(bc.Config.Account.SecurityLevel)
+	accounts := []RecoveredAccount{}
+	for index := int64(0); index < indexCount; index++ {
+		addrs, err := address.GenerateAddresses(bc.Deriver.Derive(uint64(index)), 0, addrCount, secLvl)
+		if err != nil {
+			return nil, err
+		}
+
+		recovered := RecoveredAccount{SeedIndex: index, Addresses: trinary.Hashes{}, Balances: []uint64{}}
+		if bounty, has := bounties[index]; has {
+			recovered.BountyID = bounty.ID
+			recovered.Deleted = deleted[index]
+			if bounty.PoolAddress != "" {
+				addrs = appendAddress(addrs, bounty.PoolAddress[:consts.HashTrytesSize])
+			}
+			for _, addr := range bounty.SpentPoolAddresses {
+				addrs = appendAddress(addrs, addr[:consts.HashTrytesSize])
+			}
+		}
+		if campaign, has := campaignsByIndex[index]; has {
+			recovered.CampaignID = campaign.ID.Hex()
+			addrs = appendAddress(addrs, campaign.FundingAddress[:consts.HashTrytesSize])
+		}
+
+		balances, err := bc.iotaAPI.GetBalances(addrs, 100)
+		if err != nil {
+			return nil, err
+		}
+		for i, balance := range balances.Balances {
+			if balance == 0 {
+				continue
+			}
+			recovered.Addresses = append(recovered.Addresses, addrs[i])
+			recovered.Balances = append(recovered.Balances, balance)
+			recovered.Balance += balance
+		}
+		accounts = append(accounts, recovered)
+	}
+	return accounts, nil
+}
+
+func appendAddress(addrs trinary.Hashes, addr trinary.Hash) trinary.Hashes {
+	for _, a := range addrs {
+		if a == addr {
+			return addrs
+		}
+	}
+	return append(addrs, addr)
+}
+
+// RecoverAccounts scans the accounts derived from the given master seed for funds.
+func RecoverAccounts(conf *config.Configuration, mongoClient *mongo.Client, deriver *vault.SeedDeriver, indexCount int64, addrCount uint64) ([]RecoveredAccount, error) {
+	pool, err := newNodePool(conf)
+	if err != nil {
+		return nil, err
+	}
+	pool.Check()
+	iotaAPI, err := newIOTAAPI(pool)
+	if err != nil {
+		return nil, err
+	}
+	db := mongoClient.Database(conf.DB.DBName)
+	bc := &BountyCtrl{
+		Config:               conf,
+		Mongo:                mongoClient,
+		Deriver:              deriver,
+		Coll:                 db.Collection(bountyCollection),
+		DelColl:              db.Collection(deletedBountyCollection),
+		CounterColl:          db.Collection(counterCollection),
+		CampaignColl:         db.Collection(campaignCollection),
+		CampaignTransferColl: db.Collection(campaignTransferCollection),
+		iotaAPI:              iotaAPI,
+	}
+	return bc.RecoverAccounts(indexCount, addrCount)
+}
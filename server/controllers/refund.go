@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/account"
+	"github.com/iotaledger/iota.go/api"
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"math/big"
+	"time"
+)
+
+var ErrBountyAlreadySettled = errors.New("the bounty was already transferred or refunded")
+var ErrContributionNotFound = errors.New("contribution not found on the bounty address")
+var ErrRefundAddressAlreadyRegistered = errors.New("a refund address was already registered for this contribution")
+var ErrRefundTreasuryNotConfigured = errors.New("no treasury address configured for unregistered contributions")
+
+// SyncContributions scans the tangle for confirmed deposits onto the pool address of the given bounty
+// and adds new ones to the bounty's contributions. Already registered refund addresses are kept.
+func (bc *BountyCtrl) SyncContributions(bounty *models.Bounty) error {
+	txs, err := bc.iotaAPI.FindTransactionObjects(api.FindTransactionsQuery{
+		Addresses: trinary.Hashes{bounty.PoolAddress},
+	})
+	if err != nil {
+		return err
+	}
+
+	poolAddr := bounty.PoolAddress[:consts.HashTrytesSize]
+	deposits := map[string]uint64{}
+	seen := map[string]bool{}
+	depositTxHashes := trinary.Hashes{}
+	depositTxBundles := []string{}
+	for i := range txs {
+		tx := &txs[i]
+		if tx.Value <= 0 || tx.Address != poolAddr {
+			continue
+		}
+		depositTxHashes = append(depositTxHashes, tx.Hash)
+		depositTxBundles = append(depositTxBundles, tx.Bundle)
+		// reattachments contain the same outputs, therefore only count each bundle index once
+		key := fmt.Sprintf("%s%d", tx.Bundle, tx.CurrentIndex)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deposits[tx.Bundle] += uint64(tx.Value)
+	}
+
+	if len(depositTxHashes) == 0 {
+		return nil
+	}
+
+	states, err := bc.iotaAPI.GetLatestInclusion(depositTxHashes)
+	if err != nil {
+		return err
+	}
+	confirmed := map[string]bool{}
+	for i, state := range states {
+		if state {
+			confirmed[depositTxBundles[i]] = true
+		}
+	}
+
+	known := map[string]bool{}
+	for _, contribution := range bounty.Contributions {
+		known[contribution.BundleHash] = true
+	}
+
+	matches, err := bc.campaignMatchesOfBounty(bounty.ID)
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+	contributions := bounty.Contributions
+	for bundleHash, value := range deposits {
+		if known[bundleHash] || !confirmed[bundleHash] {
+			continue
+		}
+		contribution := models.Contribution{BundleHash: bundleHash, Value: value, DetectedOn: &t}
+		if match, ok := matches[bundleHash]; ok {
+			// matched funds are refunded to the sponsor
+			contribution.CampaignID = &match.transfer.CampaignID
+			contribution.RefundAddress = match.refundAddress
+		}
+		contributions = append(contributions, contribution)
+	}
+
+	if len(contributions) == len(bounty.Contributions) {
+		return nil
+	}
+
+	mut := bson.D{{"$set", bson.D{
+		{"contributions", contributions},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't update contributions of bounty '%d'", bounty.ID)
+	}
+	bounty.Contributions = contributions
+	return nil
+}
+
+// RegisterRefundAddress registers the address to which the given contribution is refunded in case
+// the bounty gets cancelled. An already registered address can only be changed by the same GitHub user
+// or when override is set (repository admins). The registering GitHub user is taken from the actor.
+func (bc *BountyCtrl) RegisterRefundAddress(bounty *models.Bounty, bundleHash string, addr string, actor *models.Actor, override bool) error {
+	if bounty.Settled() {
+		return ErrBountyAlreadySettled
+	}
+
+	index := findContribution(bounty, bundleHash)
+	if index == -1 {
+		// the contribution might have been confirmed since the last sync
+		if err := bc.SyncContributions(bounty); err != nil {
+			return err
+		}
+		index = findContribution(bounty, bundleHash)
+		if index == -1 {
+			return ErrContributionNotFound
+		}
+	}
+
+	registeredBy := actor.GitHubID
+	contribution := bounty.Contributions[index]
+	if contribution.RefundAddress != "" && contribution.RegisteredBy != registeredBy && !override {
+		return ErrRefundAddressAlreadyRegistered
+	}
+	// matched funds belong to the sponsor of the campaign
+	if contribution.CampaignID != nil && !override {
+		return ErrRefundAddressAlreadyRegistered
+	}
+
+	mut := bson.D{{"$set", bson.D{
+		{fmt.Sprintf("contributions.%d.refund_address", index), addr},
+		{fmt.Sprintf("contributions.%d.registered_by", index), registeredBy},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't register refund address on bounty '%d'", bounty.ID)
+	}
+	bc.audit(bounty.ID, models.AuditEventRefundAddressRegistered, actor, fmt.Sprintf("contribution %s", bundleHash),
+		auditChange("refund_address", contribution.RefundAddress, addr),
+		auditChange("registered_by", contribution.RegisteredBy, registeredBy),
+	)
+	return nil
+}
+
+func findContribution(bounty *models.Bounty, bundleHash string) int {
+	for i := range bounty.Contributions {
+		if bounty.Contributions[i].BundleHash == bundleHash {
+			return i
+		}
+	}
+	return -1
+}
+
+// Cancel cancels the given bounty and refunds its available balance proportionally to the contributors
+// which registered a refund address. The share of unregistered contributions goes to the treasury address.
+func (bc *BountyCtrl) Cancel(id int64, actor *models.Actor, repo ...*models.Repository) ([]models.Refund, error) {
+	bounty, err := bc.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if bounty.Settled() {
+		return nil, ErrBountyAlreadySettled
+	}
+
+	var r *models.Repository
+	if len(repo) > 0 {
+		r = repo[0]
+	} else {
+		r, err = bc.RepoCtrl.GetByID(bounty.RepositoryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bc.SyncContributions(bounty); err != nil {
+		return nil, err
+	}
+
+	// refuse to refund if a previous payout is still in flight or was already sent
+	if err := bc.checkNoActivePayout(bounty.ID); err != nil {
+		return nil, err
+	}
+
+	prevState := bounty.State
+	if err := bc.updateState(bounty.ID, models.BountyStateRefunding); err != nil {
+		return nil, err
+	}
+
+	payout, err := bc.refund(bounty)
+	if err != nil {
+		// the bounty stays refunding until ResumePayouts settles the refund
+		if sendUnverified(err) {
+			bc.audit(bounty.ID, models.AuditEventTransferFailed, actor, fmt.Sprintf("refund unverified: %s", err.Error()))
+			return nil, err
+		}
+		// allow the cancellation to be retried
+		if err := bc.updateState(bounty.ID, prevState); err != nil {
+			bc.logger.Error(fmt.Sprintf("couldn't reset state of bounty %d after failed refund: %s", bounty.ID, err.Error()))
+		}
+		bc.audit(bounty.ID, models.AuditEventTransferFailed, actor, fmt.Sprintf("refund failed: %s", err.Error()))
+		return nil, err
+	}
+
+	// nothing to refund, no payout is recorded
+	if payout == nil {
+		if err := bc.markRefundedWithoutBalance(bounty, actor); err != nil {
+			return nil, err
+		}
+		if err := bc.Bot.PostBountyRefundedMessage(r.Owner, r.Name, bounty, []models.Refund{}, 0, ""); err != nil {
+			bc.logger.Error(fmt.Sprintf("unable to post bounty refunded message: %s", err.Error()))
+		}
+		return []models.Refund{}, nil
+	}
+
+	if err := bc.finalizePayout(payout); err != nil {
+		return nil, err
+	}
+	bc.auditPayoutSent(bounty, payout, actor)
+
+	// ignore error as the refund already happened
+	if err := bc.Bot.PostBountyRefundedMessage(r.Owner, r.Name, bounty, payout.Refunds, payout.Value, payout.BundleHash); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to post bounty refunded message: %s", err.Error()))
+	}
+
+	return payout.Refunds, nil
+}
+
+// refund sends the available balance of the bounty back to its contributors.
+// No payout is returned if the bounty has no balance.
+func (bc *BountyCtrl) refund(bounty *models.Bounty) (*models.Payout, error) {
+	availBalance, err := bc.GetAccountBalance(bounty.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	if availBalance == 0 {
+		return nil, nil
+	}
+
+	refunds, err := computeRefunds(availBalance, bounty.Contributions, bc.Config.Refund.TreasuryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make(account.Recipients, len(refunds))
+	for i := range refunds {
+		recipients[i] = account.Recipient{
+			Address: refunds[i].Address,
+			Value:   refunds[i].Value,
+			Tag:     bundle.PadTag("IOTABOUNTYREFUND"),
+		}
+	}
+
+	payout := &models.Payout{
+		Kind:    models.PayoutKindRefund,
+		Value:   availBalance,
+		Refunds: refunds,
+	}
+	if err := bc.sendPayout(bounty, payout, recipients); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// markRefundedWithoutBalance settles a cancelled bounty which had nothing to refund.
+func (bc *BountyCtrl) markRefundedWithoutBalance(bounty *models.Bounty, actor *models.Actor) error {
+	mut := bson.D{{"$set", bson.D{
+		{"state", models.BountyStateRefunded},
+		{"refunds", []models.Refund{}},
+		{"balance", 0},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't mark bounty '%d' as refunded", bounty.ID)
+	}
+	bc.audit(bounty.ID, models.AuditEventCancelled, actor, "refund of 0, nothing to refund",
+		auditChange("state", bounty.State, models.BountyStateRefunded),
+		auditChange("balance", bounty.Balance, 0),
+	)
+	bc.publishBounty(models.LiveEventBountyRefunded, bounty, models.BountyStateRefunded, 0)
+	return nil
+}
+
+// computeRefunds splits the balance proportionally to the value of each contribution.
+// Shares of contributions without a refund address and rounding leftovers go to the treasury.
+func computeRefunds(balance uint64, contributions []models.Contribution, treasuryAddr string) ([]models.Refund, error) {
+	var total uint64
+	for _, contribution := range contributions {
+		total += contribution.Value
+	}
+
+	refunds := []models.Refund{}
+	var refunded uint64
+	if total > 0 {
+		bigBalance := new(big.Int).SetUint64(balance)
+		bigTotal := new(big.Int).SetUint64(total)
+		for _, contribution := range contributions {
+			if contribution.RefundAddress == "" {
+				continue
+			}
+			share := new(big.Int).SetUint64(contribution.Value)
+			share.Mul(share, bigBalance).Div(share, bigTotal)
+			if share.Uint64() == 0 {
+				continue
+			}
+			refunds = append(refunds, models.Refund{
+				BundleHash: contribution.BundleHash,
+				Address:    contribution.RefundAddress,
+				Value:      share.Uint64(),
+			})
+			refunded += share.Uint64()
+		}
+	}
+
+	if rest := balance - refunded; rest > 0 {
+		if treasuryAddr == "" {
+			return nil, ErrRefundTreasuryNotConfigured
+		}
+		refunds = append(refunds, models.Refund{Address: treasuryAddr, Value: rest, Treasury: true})
+	}
+	return refunds, nil
+}
+
+func (bc *BountyCtrl) updateState(id int64, state models.BountyState) error {
+	mut := bson.D{{"$set", bson.D{
+		{"state", state},
+		{"model.updated_on", time.Now()},
+	}}}
+	_, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", id}}, mut)
+	return errors.Wrapf(err, "(bounty) couldn't update bounty state '%d'", id)
+}
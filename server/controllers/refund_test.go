@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"reflect"
+	"testing"
+)
+
+const refundTreasuryAddr = "TREASURY"
+
+func TestComputeRefunds(t *testing.T) {
+	tests := []struct {
+		name          string
+		balance       uint64
+		contributions []models.Contribution
+		treasury      string
+		want          []models.Refund
+		wantErr       error
+	}{
+		{
+			name:    "proportional shares",
+			balance: 300,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 100, RefundAddress: "ADDR_A"},
+				{BundleHash: "B", Value: 200, RefundAddress: "ADDR_B"},
+			},
+			want: []models.Refund{
+				{BundleHash: "A", Address: "ADDR_A", Value: 100},
+				{BundleHash: "B", Address: "ADDR_B", Value: 200},
+			},
+		},
+		{
+			name:    "rounding leftovers go to the treasury",
+			balance: 100,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 1, RefundAddress: "ADDR_A"},
+				{BundleHash: "B", Value: 1, RefundAddress: "ADDR_B"},
+				{BundleHash: "C", Value: 1, RefundAddress: "ADDR_C"},
+			},
+			treasury: refundTreasuryAddr,
+			want: []models.Refund{
+				{BundleHash: "A", Address: "ADDR_A", Value: 33},
+				{BundleHash: "B", Address: "ADDR_B", Value: 33},
+				{BundleHash: "C", Address: "ADDR_C", Value: 33},
+				{Address: refundTreasuryAddr, Value: 1, Treasury: true},
+			},
+		},
+		{
+			name:    "contributions without refund address go to the treasury",
+			balance: 400,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 100, RefundAddress: "ADDR_A"},
+				{BundleHash: "B", Value: 300},
+			},
+			treasury: refundTreasuryAddr,
+			want: []models.Refund{
+				{BundleHash: "A", Address: "ADDR_A", Value: 100},
+				{Address: refundTreasuryAddr, Value: 300, Treasury: true},
+			},
+		},
+		{
+			name:    "contributions without refund address and no treasury",
+			balance: 400,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 100, RefundAddress: "ADDR_A"},
+				{BundleHash: "B", Value: 300},
+			},
+			wantErr: ErrRefundTreasuryNotConfigured,
+		},
+		{
+			name:    "zero shares are skipped",
+			balance: 10,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 1, RefundAddress: "ADDR_A"},
+				{BundleHash: "B", Value: 999, RefundAddress: "ADDR_B"},
+			},
+			treasury: refundTreasuryAddr,
+			want: []models.Refund{
+				{BundleHash: "B", Address: "ADDR_B", Value: 9},
+				{Address: refundTreasuryAddr, Value: 1, Treasury: true},
+			},
+		},
+		{
+			name:     "balance without known contributions goes to the treasury",
+			balance:  50,
+			treasury: refundTreasuryAddr,
+			want: []models.Refund{
+				{Address: refundTreasuryAddr, Value: 50, Treasury: true},
+			},
+		},
+		{
+			name:    "zero balance",
+			balance: 0,
+			contributions: []models.Contribution{
+				{BundleHash: "A", Value: 100, RefundAddress: "ADDR_A"},
+			},
+			want: []models.Refund{},
+		},
+	}
+	for _, test := range tests {
+		refunds, err := computeRefunds(test.balance, test.contributions, test.treasury)
+		if test.wantErr != nil {
+			if err != test.wantErr {
+				t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(refunds, test.want) {
+			t.Errorf("%s: got refunds %+v, want %+v", test.name, refunds, test.want)
+		}
+		var sum uint64
+		for _, refund := range refunds {
+			sum += refund.Value
+		}
+		if sum != test.balance {
+			t.Errorf("%s: refunds add up to %d, want %d", test.name, sum, test.balance)
+		}
+	}
+}
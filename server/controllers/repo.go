@@ -3,6 +3,8 @@ package controllers
 import (
 	"fmt"
 	"github.com/google/go-github/github"
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/guards"
 	"github.com/luca-moser/iota-bounty-platform/server/misc"
 	"github.com/luca-moser/iota-bounty-platform/server/models"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
@@ -25,6 +27,7 @@ type RepoCtrl struct {
 	Bot        *Bot                  `inject:""`
 	GHClient   *github.Client        `inject:""`
 	Mongo      *mongo.Client         `inject:""`
+	Events     *EventCtrl            `inject:""`
 	Coll       *mongo.Collection
 	DelColl    *mongo.Collection
 	logger     log15.Logger
@@ -62,6 +65,10 @@ func (rc *RepoCtrl) Init() error {
 		return err
 	}
 
+	if err := rc.initSearchIndex(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -132,6 +139,7 @@ func (rc *RepoCtrl) Add(owner string, name string) (*models.Repository, error) {
 	if _, err := rc.Coll.InsertOne(DefaultCtx(), repoModel); err != nil {
 		return nil, errors.Wrap(err, "(repo) couldn't insert repo")
 	}
+	rc.publish(models.LiveEventRepositoryCreated, repoModel.ID)
 
 	// trigger the bot to re-check webhooks
 	rc.Bot.InstallWebHooks()
@@ -161,7 +169,7 @@ func (rc *RepoCtrl) SyncRepo(repo *models.Repository) error {
 		if res != nil && res.StatusCode == 404 {
 			// delete the repository automatically and all its associated bounties
 			// as it no longer exists
-			if err := rc.Delete(repo.ID); err != nil {
+			if err := rc.Delete(repo.ID, PlatformActor); err != nil {
 				return err
 			}
 			return err
@@ -174,16 +182,56 @@ func (rc *RepoCtrl) SyncRepo(repo *models.Repository) error {
 		return ErrIssuesDeactivated
 	}
 
+	owner := strings.TrimSpace(ghRepo.GetOwner().GetLogin())
+	name := strings.TrimSpace(ghRepo.GetName())
+	url := strings.TrimSpace(ghRepo.GetHTMLURL())
+	description := strings.TrimSpace(ghRepo.GetDescription())
 	mut := bson.D{{"$set", bson.D{
-		{"owner", strings.TrimSpace(ghRepo.GetOwner().GetLogin())},
-		{"name", strings.TrimSpace(ghRepo.GetName())},
-		{"url", strings.TrimSpace(ghRepo.GetHTMLURL())},
-		{"description", strings.TrimSpace(ghRepo.GetDescription())},
+		{"owner", owner},
+		{"name", name},
+		{"url", url},
+		{"description", description},
 		{"model.updated_on", time.Now()},
 	}}}
 
-	_, err = rc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", repo.ID}}, mut)
-	return errors.Wrapf(err, "(repo) couldn't update repo '%d'", repo.ID)
+	if _, err = rc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", repo.ID}}, mut); err != nil {
+		return errors.Wrapf(err, "(repo) couldn't update repo '%d'", repo.ID)
+	}
+	if repo.Owner != owner || repo.Name != name || repo.URL != url || repo.Description != description {
+		rc.publish(models.LiveEventRepositoryUpdated, repo.ID)
+	}
+	return nil
+}
+
+func (rc *RepoCtrl) UpdateSettings(id int64, settings *models.RepositorySettings) (*models.Repository, error) {
+	feePercent := rc.Config.Fees.PlatformFeePercent
+	if settings.PlatformFeePercent != nil {
+		feePercent = *settings.PlatformFeePercent
+	}
+	if err := validateFeePercentages(feePercent, settings.MaintainerTipPercent); err != nil {
+		return nil, err
+	}
+
+	if settings.MaintainerTipPercent > 0 {
+		addr := settings.MaintainerTipAddress
+		if !guards.IsAddressWithChecksum(addr) || address.ValidChecksum(addr[:81], addr[81:]) != nil {
+			return nil, errors.Wrap(ErrInvalidFeeSettings, "invalid maintainer tip address")
+		}
+	}
+
+	if settings.FundingNoticeQuietMinutes != nil && *settings.FundingNoticeQuietMinutes < 0 {
+		return nil, errors.Wrap(ErrInvalidFundingNoticeSettings, "the quiet period can't be negative")
+	}
+
+	mut := bson.D{{"$set", bson.D{
+		{"settings", settings},
+		{"model.updated_on", time.Now()},
+	}}}
+	if _, err := rc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", id}}, mut); err != nil {
+		return nil, errors.Wrapf(err, "(repo) couldn't update settings of repo '%d'", id)
+	}
+	rc.publish(models.LiveEventRepositoryUpdated, id)
+	return rc.GetByID(id)
 }
 
 func (rc *RepoCtrl) AddViaURL(url string) (*models.Repository, error) {
@@ -196,7 +244,8 @@ func (rc *RepoCtrl) AddViaURL(url string) (*models.Repository, error) {
 	return rc.Add(owner, name)
 }
 
-func (rc *RepoCtrl) Delete(id int64) error {
+// Delete removes the repository and all its bounties on behalf of the given actor.
+func (rc *RepoCtrl) Delete(id int64, actor *models.Actor) error {
 	repo, err := rc.GetByID(id)
 	if err != nil {
 		return err
@@ -208,14 +257,19 @@ func (rc *RepoCtrl) Delete(id int64) error {
 	}
 
 	for i := range bounties {
-		if err := rc.BountyCtrl.Delete(bounties[i].ID, repo); err != nil {
+		if err := rc.BountyCtrl.Delete(bounties[i].ID, actor, repo); err != nil {
 			rc.logger.Error(fmt.Sprintf("couldn't delete associated bounty: %s", err.Error()))
 		}
 	}
 
 	if _, err := rc.Coll.DeleteOne(DefaultCtx(), bson.D{{"_id", id}}); err != nil {
-		return errors.Wrapf(err, "(repo) couldn't delete repo '%s'", id)
+		return errors.Wrapf(err, "(repo) couldn't delete repo '%d'", id)
 	}
+	rc.publish(models.LiveEventRepositoryDeleted, id)
 	_, err = rc.DelColl.InsertOne(DefaultCtx(), models.DeletedModel{Object: repo})
-	return errors.Wrapf(err, "(repo) couldn't move repo '%s' to deleted collection", id)
+	return errors.Wrapf(err, "(repo) couldn't move repo '%d' to deleted collection", id)
+}
+
+func (rc *RepoCtrl) publish(kind models.LiveEventKind, id int64) {
+	rc.Events.Publish(models.LiveEvent{Kind: kind, RepositoryID: id})
 }
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const DefaultSearchLimit = 20
+const maxSearchLimit = 100
+
+// the amount of characters shown around the first match of a snippet
+const snippetRadius = 80
+
+// textIndex creates the text index of the given collection, a collection can only have a single one.
+func textIndex(coll *mongo.Collection, weights bsonx.Doc) error {
+	keys := bsonx.Doc{}
+	for _, weight := range weights {
+		keys = append(keys, bsonx.Elem{Key: weight.Key, Value: bsonx.String("text")})
+	}
+	name := "text"
+	index := mongo.IndexModel{
+		Keys:    keys,
+		Options: &options.IndexOptions{Name: &name, Weights: weights},
+	}
+	_, err := coll.Indexes().CreateOne(DefaultCtx(), index)
+	return err
+}
+
+func (bc *BountyCtrl) initSearchIndex() error {
+	err := textIndex(bc.Coll, bsonx.Doc{
+		{Key: "title", Value: bsonx.Int32(10)},
+		{Key: "repo_name", Value: bsonx.Int32(5)},
+		{Key: "repo_description", Value: bsonx.Int32(2)},
+		{Key: "body", Value: bsonx.Int32(1)},
+	})
+	return errors.Wrap(err, "(bounty) couldn't create text index")
+}
+
+func (rc *RepoCtrl) initSearchIndex() error {
+	err := textIndex(rc.Coll, bsonx.Doc{
+		{Key: "name", Value: bsonx.Int32(10)},
+		{Key: "owner", Value: bsonx.Int32(5)},
+		{Key: "description", Value: bsonx.Int32(2)},
+	})
+	return errors.Wrap(err, "(repo) couldn't create text index")
+}
+
+// searchOptions sorts the matches by their text score, which is added as the "score" field.
+func searchOptions(limit int64) *options.FindOptions {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	score := bson.D{{"score", bson.D{{"$meta", "textScore"}}}}
+	return options.Find().SetProjection(score).SetSort(score).SetLimit(limit)
+}
+
+// Search returns the bounties matching the given text and the filters of the query, best matches first.
+// The title, body and the name and description of the repository are searched.
+func (bc *BountyCtrl) Search(text string, query *BountyQuery) ([]models.BountySearchHit, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, errors.Wrap(ErrInvalidQuery, "no search terms")
+	}
+
+	filter := append(bson.D{{"$text", bson.D{{"$search", text}}}}, bountyFilter(query)...)
+	cursor, err := bc.Coll.Find(DefaultCtx(), filter, searchOptions(query.Limit))
+	if err != nil {
+		return nil, errors.Wrap(err, "(bounty) couldn't search bounties")
+	}
+	hits := []models.BountySearchHit{}
+	for cursor.Next(DefaultCtx()) {
+		var match struct {
+			models.Bounty `bson:",inline"`
+			Score         float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&match); err != nil {
+			return nil, err
+		}
+		hits = append(hits, models.BountySearchHit{
+			Bounty: match.Bounty,
+			Score:  match.Score,
+			Snippets: snippets(terms, map[string]string{
+				"title":            match.Title,
+				"body":             match.Body,
+				"repo_name":        match.RepoName,
+				"repo_description": match.RepoDescription,
+			}),
+		})
+	}
+	return hits, errors.Wrap(cursor.Err(), "(bounty) couldn't search bounties")
+}
+
+// Search returns the repositories whose owner, name or description match the given text, best matches first.
+func (rc *RepoCtrl) Search(text string, limit int64) ([]models.RepositorySearchHit, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, errors.Wrap(ErrInvalidQuery, "no search terms")
+	}
+
+	cursor, err := rc.Coll.Find(DefaultCtx(), bson.D{{"$text", bson.D{{"$search", text}}}}, searchOptions(limit))
+	if err != nil {
+		return nil, errors.Wrap(err, "(repo) couldn't search repos")
+	}
+	hits := []models.RepositorySearchHit{}
+	for cursor.Next(DefaultCtx()) {
+		var match struct {
+			models.Repository `bson:",inline"`
+			Score             float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&match); err != nil {
+			return nil, err
+		}
+		hits = append(hits, models.RepositorySearchHit{
+			Repository: match.Repository,
+			Score:      match.Score,
+			Snippets: snippets(terms, map[string]string{
+				"owner":       match.Owner,
+				"name":        match.Name,
+				"description": match.Description,
+			}),
+		})
+	}
+	return hits, errors.Wrap(cursor.Err(), "(repo) couldn't search repos")
+}
+
+// searchTerms extracts the lower cased terms to highlight from the given search text.
+// Negated terms are left out, phrases are highlighted word by word.
+func searchTerms(text string) []string {
+	terms := []string{}
+	for _, word := range strings.Fields(strings.Replace(text, `"`, " ", -1)) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		word = strings.TrimFunc(strings.ToLower(word), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// snippets returns the highlighted excerpts of the given fields which contain one of the terms.
+// Matches through stemming (e.g. "serialize" for "serialization") aren't highlighted.
+func snippets(terms []string, fields map[string]string) map[string]string {
+	res := map[string]string{}
+	for field, text := range fields {
+		if snippet := highlight(text, terms); snippet != "" {
+			res[field] = snippet
+		}
+	}
+	return res
+}
+
+// highlight cuts an excerpt around the first match of the terms out of the given text and wraps all matches
+// in <mark> tags. The rest of the text is HTML escaped. An empty string is returned if no term matches.
+func highlight(text string, terms []string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// the length of the term matching at each position
+	matches := make([]int, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(term)
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if string(lower[i:i+len(termRunes)]) != term || len(termRunes) <= matches[i] {
+				continue
+			}
+			matches[i] = len(termRunes)
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start, end := first-snippetRadius, first+snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if matches[i] == 0 {
+			j := i + 1
+			for j < end && matches[j] == 0 {
+				j++
+			}
+			b.WriteString(html.EscapeString(string(runes[i:j])))
+			i = j
+			continue
+		}
+		matchEnd := i + matches[i]
+		if matchEnd > len(runes) {
+			matchEnd = len(runes)
+		}
+		b.WriteString("<mark>" + html.EscapeString(string(runes[i:matchEnd])) + "</mark>")
+		i = matchEnd
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var ErrSeedMasterMismatch = errors.New("the configured master seed differs from the one the existing bounty seeds were derived from")
+
+const seedIndexCounter = "bounty_seed_index"
+
+type seedIndexCounterDoc struct {
+	Value    int64  `bson:"value"`
+	MasterID string `bson:"master_id"`
+}
+
+// nextSeedIndex allocates the index from which the seed of a new bounty is derived.
+func (bc *BountyCtrl) nextSeedIndex() (int64, error) {
+	mut := bson.D{
+		{"$inc", bson.D{{"value", int64(1)}}},
+		{"$setOnInsert", bson.D{{"master_id", bc.Deriver.ID()}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	res := bc.CounterColl.FindOneAndUpdate(DefaultCtx(), bson.D{{"_id", seedIndexCounter}}, mut, opts)
+	counter := &seedIndexCounterDoc{}
+	if err := res.Decode(counter); err != nil {
+		return 0, errors.Wrap(err, "(bounty) couldn't allocate seed index")
+	}
+	return counter.Value - 1, nil
+}
+
+// allocateSeed allocates the index of a new bounty or campaign account and derives its seed from it.
+// The index is allocated before the account is used, so no funds can end up on an unrecorded seed.
+func (bc *BountyCtrl) allocateSeed() (int64, string, error) {
+	seedIndex, err := bc.nextSeedIndex()
+	if err != nil {
+		return 0, "", err
+	}
+	return seedIndex, bc.Deriver.Derive(uint64(seedIndex)), nil
+}
+
+// allocatedSeedIndices returns the amount of seed indices allocated so far.
+func (bc *BountyCtrl) allocatedSeedIndices() (int64, error) {
+	counter := &seedIndexCounterDoc{}
+	if err := bc.CounterColl.FindOne(DefaultCtx(), bson.D{{"_id", seedIndexCounter}}).Decode(counter); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "(bounty) couldn't load seed index counter")
+	}
+	if err := counter.checkMaster(bc.Deriver); err != nil {
+		return 0, err
+	}
+	return counter.Value, nil
+}
+
+// checkMaster ensures that the allocated indices were derived from the master seed of the given deriver.
+func (counter *seedIndexCounterDoc) checkMaster(deriver *vault.SeedDeriver) error {
+	if counter.MasterID != deriver.ID() {
+		return ErrSeedMasterMismatch
+	}
+	return nil
+}
+
+func (bc *BountyCtrl) checkSeedMaster() error {
+	_, err := bc.allocatedSeedIndices()
+	return err
+}
+
+// openSeed derives or decrypts the seed of the given bounty.
+func (bc *BountyCtrl) openSeed(bounty *models.Bounty) error {
+	if bounty.SeedIndex != nil {
+		bounty.Seed = bc.Deriver.Derive(uint64(*bounty.SeedIndex))
+		return nil
+	}
+	if bounty.EncryptedSeed == nil {
+		return nil
+	}
+	seed, err := bc.Vault.Decrypt(bounty.EncryptedSeed)
+	if err != nil {
+		return errors.Wrapf(err, "(bounty) couldn't decrypt seed of bounty '%d'", bounty.ID)
+	}
+	bounty.Seed = seed
+	return nil
+}
+
+// sealSeed encrypts the seed of the given bounty and clears the plaintext one, so that
+// the returned copy can be persisted. Derived seeds aren't stored at all.
+func (bc *BountyCtrl) sealSeed(bounty *models.Bounty) (*models.Bounty, error) {
+	if bounty.SeedIndex != nil {
+		sealed := *bounty
+		sealed.Seed = ""
+		return &sealed, nil
+	}
+	encryptedSeed, err := bc.Vault.Encrypt(bounty.Seed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "(bounty) couldn't encrypt seed of bounty '%d'", bounty.ID)
+	}
+	bounty.EncryptedSeed = encryptedSeed
+	sealed := *bounty
+	sealed.Seed = ""
+	return &sealed, nil
+}
+
+// seedDocs describes where bounty documents (and thereby their seeds) reside in a collection.
+type seedDocs struct {
+	coll   *mongo.Collection
+	prefix string
+}
+
+// decode decodes the bounty of the current document of the cursor and returns the id of the document.
+func (docs seedDocs) decode(cursor *mongo.Cursor) (interface{}, *models.Bounty, error) {
+	if docs.prefix == "" {
+		bounty := &models.Bounty{}
+		err := cursor.Decode(bounty)
+		return bounty.ID, bounty, err
+	}
+	var doc struct {
+		ID     interface{}   `bson:"_id"`
+		Object models.Bounty `bson:"object"`
+	}
+	err := cursor.Decode(&doc)
+	return doc.ID, &doc.Object, err
+}
+
+func (bc *BountyCtrl) seedDocs() []seedDocs {
+	return []seedDocs{{bc.Coll, ""}, {bc.DelColl, "object."}}
+}
+
+// MigratePlaintextSeeds encrypts all seeds which are still stored in plaintext.
+func (bc *BountyCtrl) MigratePlaintextSeeds() error {
+	for _, docs := range bc.seedDocs() {
+		seedField := docs.prefix + "seed"
+		cursor, err := docs.coll.Find(DefaultCtx(), bson.D{{seedField, bson.D{{"$exists", true}, {"$ne", ""}}}})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(DefaultCtx())
+
+		var migrated int
+		for cursor.Next(DefaultCtx()) {
+			docID, bounty, err := docs.decode(cursor)
+			if err != nil {
+				return err
+			}
+
+			mut, err := bc.encryptSeedMutation(docs, bounty)
+			if err != nil {
+				return err
+			}
+			if _, err := docs.coll.UpdateOne(DefaultCtx(), bson.D{{"_id", docID}}, mut); err != nil {
+				return errors.Wrapf(err, "(bounty) couldn't migrate seed of bounty '%d'", bounty.ID)
+			}
+			migrated++
+		}
+		if migrated > 0 {
+			bc.logger.Info(fmt.Sprintf("encrypted %d plaintext seeds in collection %s", migrated, docs.coll.Name()))
+		}
+	}
+	return nil
+}
+
+// ReencryptSeeds re-wraps the data keys of all seeds encrypted by the current vault with the given new vault.
+// Seeds which are already wrapped by the new master key are skipped, so an interrupted rotation can be resumed.
+func (bc *BountyCtrl) ReencryptSeeds(newVault *vault.Vault) (int, error) {
+	var rotated int
+	for _, docs := range bc.seedDocs() {
+		encSeedField := docs.prefix + "encrypted_seed"
+		cursor, err := docs.coll.Find(DefaultCtx(), bson.D{
+			{encSeedField, bson.D{{"$exists", true}}},
+			{encSeedField + ".key_id", bson.D{{"$ne", newVault.KeyID()}}},
+		})
+		if err != nil {
+			return rotated, err
+		}
+		defer cursor.Close(DefaultCtx())
+
+		for cursor.Next(DefaultCtx()) {
+			docID, bounty, err := docs.decode(cursor)
+			if err != nil {
+				return rotated, err
+			}
+
+			mut, err := bc.reencryptSeedMutation(docs, bounty, newVault)
+			if err != nil {
+				return rotated, err
+			}
+			if _, err := docs.coll.UpdateOne(DefaultCtx(), bson.D{{"_id", docID}}, mut); err != nil {
+				return rotated, errors.Wrapf(err, "(bounty) couldn't store re-encrypted seed of bounty '%d'", bounty.ID)
+			}
+			rotated++
+		}
+	}
+	return rotated, nil
+}
+
+// RotateSeedKey re-encrypts all seeds stored in the configured database with the master key of the next vault.
+func RotateSeedKey(conf *config.Configuration, mongoClient *mongo.Client, current *vault.Vault, next *vault.Vault) (int, error) {
+	db := mongoClient.Database(conf.DB.DBName)
+	bc := &BountyCtrl{
+		Config:  conf,
+		Mongo:   mongoClient,
+		Vault:   current,
+		Coll:    db.Collection(bountyCollection),
+		DelColl: db.Collection(deletedBountyCollection),
+	}
+	return bc.ReencryptSeeds(next)
+}
+
+// encryptSeedMutation encrypts the plaintext seed of the given bounty and returns the update replacing it.
+func (bc *BountyCtrl) encryptSeedMutation(docs seedDocs, bounty *models.Bounty) (bson.D, error) {
+	encryptedSeed, err := bc.Vault.Encrypt(bounty.Seed)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{
+		{"$set", bson.D{{docs.prefix + "encrypted_seed", encryptedSeed}}},
+		{"$unset", bson.D{{docs.prefix + "seed", ""}}},
+	}, nil
+}
+
+// reencryptSeedMutation re-wraps the encrypted seed of the given bounty with the new vault
+// and returns the update storing it.
+func (bc *BountyCtrl) reencryptSeedMutation(docs seedDocs, bounty *models.Bounty, newVault *vault.Vault) (bson.D, error) {
+	rewrapped, err := bc.Vault.Rewrap(bounty.EncryptedSeed, newVault)
+	if err != nil {
+		return nil, errors.Wrapf(err, "(bounty) couldn't re-encrypt seed of bounty '%d'", bounty.ID)
+	}
+	return bson.D{{"$set", bson.D{
+		{docs.prefix + "encrypted_seed", rewrapped},
+		{docs.prefix + "model.updated_on", time.Now()},
+	}}}, nil
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+// the keys serve both as master keys of vaults and as master seeds of derivers
+const testMasterKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+const otherTestMasterKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+
+const testSeed = "SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9SEED9S"
+
+func newTestSeedVault(t *testing.T, key string) *vault.Vault {
+	v, err := vault.New(key)
+	if err != nil {
+		t.Fatalf("couldn't create vault: %v", err)
+	}
+	return v
+}
+
+// mutationField returns the value of the given field of the given operator of an update.
+func mutationField(mut bson.D, operator string, field string) (interface{}, bool) {
+	for _, op := range mut {
+		if op.Key != operator {
+			continue
+		}
+		for _, e := range op.Value.(bson.D) {
+			if e.Key == field {
+				return e.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func TestEncryptSeedMutation(t *testing.T) {
+	bc := &BountyCtrl{Vault: newTestSeedVault(t, testMasterKey)}
+
+	for _, docs := range []seedDocs{{prefix: ""}, {prefix: "object."}} {
+		mut, err := bc.encryptSeedMutation(docs, &models.Bounty{Seed: testSeed})
+		if err != nil {
+			t.Fatalf("%q: couldn't migrate seed: %v", docs.prefix, err)
+		}
+		if _, ok := mutationField(mut, "$unset", docs.prefix+"seed"); !ok {
+			t.Errorf("%q: the plaintext seed isn't removed", docs.prefix)
+		}
+		value, ok := mutationField(mut, "$set", docs.prefix+"encrypted_seed")
+		if !ok {
+			t.Fatalf("%q: the encrypted seed isn't set", docs.prefix)
+		}
+		seed, err := bc.Vault.Decrypt(value.(*models.EncryptedSecret))
+		if err != nil {
+			t.Fatalf("%q: couldn't decrypt the migrated seed: %v", docs.prefix, err)
+		}
+		if seed != testSeed {
+			t.Errorf("%q: got seed %s, want %s", docs.prefix, seed, testSeed)
+		}
+	}
+}
+
+func TestReencryptSeedMutation(t *testing.T) {
+	bc := &BountyCtrl{Vault: newTestSeedVault(t, testMasterKey)}
+	newVault := newTestSeedVault(t, otherTestMasterKey)
+
+	encryptedSeed, err := bc.Vault.Encrypt(testSeed)
+	if err != nil {
+		t.Fatalf("couldn't encrypt seed: %v", err)
+	}
+
+	for _, docs := range []seedDocs{{prefix: ""}, {prefix: "object."}} {
+		mut, err := bc.reencryptSeedMutation(docs, &models.Bounty{EncryptedSeed: encryptedSeed}, newVault)
+		if err != nil {
+			t.Fatalf("%q: couldn't re-encrypt seed: %v", docs.prefix, err)
+		}
+		value, ok := mutationField(mut, "$set", docs.prefix+"encrypted_seed")
+		if !ok {
+			t.Fatalf("%q: the re-encrypted seed isn't set", docs.prefix)
+		}
+		rewrapped := value.(*models.EncryptedSecret)
+		if rewrapped.KeyID != newVault.KeyID() {
+			t.Errorf("%q: got key id %s, want %s", docs.prefix, rewrapped.KeyID, newVault.KeyID())
+		}
+		seed, err := newVault.Decrypt(rewrapped)
+		if err != nil {
+			t.Fatalf("%q: couldn't decrypt the re-encrypted seed: %v", docs.prefix, err)
+		}
+		if seed != testSeed {
+			t.Errorf("%q: got seed %s, want %s", docs.prefix, seed, testSeed)
+		}
+	}
+
+	// seeds already wrapped by the new key can't be re-encrypted by the old vault
+	rewrapped, err := bc.Vault.Rewrap(encryptedSeed, newVault)
+	if err != nil {
+		t.Fatalf("couldn't rewrap seed: %v", err)
+	}
+	if _, err := bc.reencryptSeedMutation(seedDocs{}, &models.Bounty{EncryptedSeed: rewrapped}, newVault); errors.Cause(err) != vault.ErrUnknownKey {
+		t.Errorf("got error %v, want %v", err, vault.ErrUnknownKey)
+	}
+}
+
+func TestSealAndOpenSeed(t *testing.T) {
+	bc := &BountyCtrl{Vault: newTestSeedVault(t, testMasterKey)}
+
+	bounty := &models.Bounty{Seed: testSeed}
+	sealed, err := bc.sealSeed(bounty)
+	if err != nil {
+		t.Fatalf("couldn't seal seed: %v", err)
+	}
+	if sealed.Seed != "" || sealed.EncryptedSeed == nil {
+		t.Fatalf("the sealed bounty holds the plaintext seed")
+	}
+	if bounty.Seed != testSeed {
+		t.Errorf("sealing cleared the seed of the given bounty")
+	}
+
+	if err := bc.openSeed(sealed); err != nil {
+		t.Fatalf("couldn't open seed: %v", err)
+	}
+	if sealed.Seed != testSeed {
+		t.Errorf("got seed %s, want %s", sealed.Seed, testSeed)
+	}
+}
+
+func TestSeedCounterCheckMaster(t *testing.T) {
+	deriver, err := vault.NewSeedDeriver(testMasterKey)
+	if err != nil {
+		t.Fatalf("couldn't create seed deriver: %v", err)
+	}
+	changed, err := vault.NewSeedDeriver(otherTestMasterKey)
+	if err != nil {
+		t.Fatalf("couldn't create seed deriver: %v", err)
+	}
+
+	counter := &seedIndexCounterDoc{Value: 3, MasterID: deriver.ID()}
+	if err := counter.checkMaster(deriver); err != nil {
+		t.Errorf("same master: unexpected error: %v", err)
+	}
+	if err := counter.checkMaster(changed); err != ErrSeedMasterMismatch {
+		t.Errorf("changed master: got error %v, want %v", err, ErrSeedMasterMismatch)
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/api"
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var ErrBountyNotSettled = errors.New("the bounty wasn't paid out yet")
+var ErrNoLateDeposits = errors.New("there are no late deposits on the bounty address")
+var ErrInvalidSweepTarget = errors.New("the sweep target must either be 'receiver' or 'treasury'")
+var ErrNoSweepReceiver = errors.New("the bounty has no receiver address to sweep the late deposits to")
+var ErrPoolAddressKeyIndexNotFound = errors.New("couldn't find the key index of the pool address")
+
+// the amount of key indices searched for the pool address
+const poolAddressSearchDepth = 100
+
+// checkLateDeposits checks the spent pool addresses of a bounty for funds which arrived after they were paid out from
+// and warns on the issue whenever the late balance grew since the last warning.
+func (bc *BountyCtrl) checkLateDeposits(bounty *models.Bounty, repo *models.Repository) error {
+	// the pool address still holds the paid out funds until the payout is confirmed
+	inFlight, err := bc.hasPayoutInFlight(bounty.ID)
+	if err != nil || inFlight {
+		return err
+	}
+
+	_, balances, err := bc.lateDepositBalances(bounty)
+	if err != nil {
+		return err
+	}
+	balance := sumBalances(balances)
+
+	notified := bounty.LateBalanceNotified
+	if balance < notified {
+		// funds were swept in the meantime
+		notified = balance
+	}
+	warn := balance > notified
+	if warn {
+		notified = balance
+	}
+
+	if balance != bounty.LateBalance || notified != bounty.LateBalanceNotified {
+		mut := bson.D{{"$set", bson.D{
+			{"late_balance", balance},
+			{"late_balance_notified", notified},
+			{"model.updated_on", time.Now()},
+		}}}
+		if _, err := bc.Coll.UpdateOne(DefaultCtx(), bson.D{{"_id", bounty.ID}}, mut); err != nil {
+			return errors.Wrapf(err, "(bounty) couldn't update late balance of bounty '%d'", bounty.ID)
+		}
+		bounty.LateBalance = balance
+		bounty.LateBalanceNotified = notified
+	}
+
+	if !warn {
+		return nil
+	}
+	bc.logger.Warn(fmt.Sprintf("bounty %d/%s received %d iotas after it was paid out", bounty.ID, bounty.Title, balance))
+	return bc.Bot.PostLateDepositWarningMessage(repo.Owner, repo.Name, bounty)
+}
+
+func (bc *BountyCtrl) hasPayoutInFlight(bountyID int64) (bool, error) {
+	count, err := bc.PayoutColl.CountDocuments(DefaultCtx(), bson.D{
+		{"bounty_id", bountyID},
+		{"state", bson.D{{"$in", activePayoutStates}}},
+	})
+	return count > 0, err
+}
+
+// spentPoolAddresses returns the pool addresses of the bounty which were already spent by a payout,
+// the current one only once the bounty is paid out.
+func spentPoolAddresses(bounty *models.Bounty) trinary.Hashes {
+	addrs := trinary.Hashes{}
+	for _, addr := range bounty.SpentPoolAddresses {
+		addrs = append(addrs, addr[:consts.HashTrytesSize])
+	}
+	if bounty.Settled() {
+		addrs = append(addrs, bounty.PoolAddress[:consts.HashTrytesSize])
+	}
+	return addrs
+}
+
+// lateDepositBalances returns the spent pool addresses of the bounty and their balances.
+func (bc *BountyCtrl) lateDepositBalances(bounty *models.Bounty) (trinary.Hashes, []uint64, error) {
+	addrs := spentPoolAddresses(bounty)
+	if len(addrs) == 0 {
+		return addrs, []uint64{}, nil
+	}
+	balances, err := bc.iotaAPI.GetBalances(addrs, 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addrs, balances.Balances, nil
+}
+
+func sumBalances(balances []uint64) uint64 {
+	var sum uint64
+	for _, balance := range balances {
+		sum += balance
+	}
+	return sum
+}
+
+// SweepLateDeposits sends the funds which arrived on the spent pool addresses of the bounty after they were
+// paid out from either to the receiver of the bounty or to the treasury. As the addresses are already spent,
+// their whole balance is moved in a single bundle.
+func (bc *BountyCtrl) SweepLateDeposits(bounty *models.Bounty, target models.SweepTarget, actor *models.Actor, repo ...*models.Repository) (*models.Payout, error) {
+	// open bounties only have spent pool addresses after milestone payouts
+	if !bounty.Settled() && len(bounty.SpentPoolAddresses) == 0 {
+		return nil, ErrBountyNotSettled
+	}
+
+	var addr string
+	switch target {
+	case models.SweepTargetReceiver:
+		addr = bounty.ReceiverAddress
+		if addr == "" {
+			return nil, ErrNoSweepReceiver
+		}
+	case models.SweepTargetTreasury:
+		addr = bc.Config.Refund.TreasuryAddress
+		if addr == "" {
+			return nil, ErrRefundTreasuryNotConfigured
+		}
+	default:
+		return nil, ErrInvalidSweepTarget
+	}
+
+	var r *models.Repository
+	var err error
+	if len(repo) > 0 {
+		r = repo[0]
+	} else {
+		r, err = bc.RepoCtrl.GetByID(bounty.RepositoryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	inFlight, err := bc.hasPayoutInFlight(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inFlight {
+		return nil, ErrPayoutPending
+	}
+
+	addrs, balances, err := bc.lateDepositBalances(bounty)
+	if err != nil {
+		return nil, err
+	}
+	balance := sumBalances(balances)
+	if balance == 0 {
+		return nil, ErrNoLateDeposits
+	}
+
+	inputs, err := bc.sweepInputs(bounty, addrs, balances)
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &models.Payout{
+		Kind:            models.PayoutKindSweep,
+		Value:           balance,
+		ReceiverAddress: addr,
+		SweepTarget:     target,
+	}
+	if err := bc.insertPayout(bounty, payout); err != nil {
+		return nil, err
+	}
+	if err := bc.sendSweep(bounty, payout, inputs); err != nil {
+		return nil, err
+	}
+
+	if err := bc.finalizePayout(payout); err != nil {
+		return nil, err
+	}
+	bc.auditPayoutSent(bounty, payout, actor)
+
+	// ignore error as the sweep already happened
+	if err := bc.Bot.PostLateDepositsSweptMessage(r.Owner, r.Name, bounty, payout); err != nil {
+		bc.logger.Error(fmt.Sprintf("unable to post late deposits swept message: %s", err.Error()))
+	}
+	return payout, nil
+}
+
+// sendSweep signs and sends the sweep bundle directly as spent pool addresses are no longer managed by the account.
+func (bc *BountyCtrl) sendSweep(bounty *models.Bounty, payout *models.Payout, inputs []api.Input) error {
+	secLvl := consts.SecurityLevel(bc.Config.Account.SecurityLevel)
+	transfers := bundle.Transfers{{
+		Address: payout.ReceiverAddress,
+		Value:   payout.Value,
+		Tag:     bundle.PadTag("IOTABOUNTYSWEEP"),
+	}}
+	bundleTrytes, err := bc.iotaAPI.PrepareTransfers(bounty.Seed, transfers, api.PrepareTransfersOptions{
+		Inputs: inputs, Security: secLvl,
+	})
+	if err != nil {
+		return bc.failPayout(payout, err)
+	}
+
+	tx, err := transaction.AsTransactionObject(bundleTrytes[0])
+	if err != nil {
+		return bc.failPayout(payout, err)
+	}
+	payout.BundleHash = tx.Bundle
+	if err := bc.updatePayout(payout.ID, bson.D{{"bundle_hash", payout.BundleHash}}); err != nil {
+		return bc.failPayout(payout, err)
+	}
+
+	bndl, err := bc.iotaAPI.SendTrytes(bundleTrytes, bc.Config.Account.GTTADepth, bc.Config.Account.MWM)
+	if err != nil {
+		// the bundle might have been broadcasted before the error occurred
+		sent, checkErr := bc.verifyPendingPayout(bounty, payout)
+		if checkErr != nil {
+			// the sweep stays pending until ResumePayouts settles it against the tangle
+			bc.logger.Error(fmt.Sprintf("can't verify sweep %s after sending failed: %s", payout.ID.Hex(), checkErr.Error()))
+			return errors.Wrap(errSendUnverified, err.Error())
+		}
+		if !sent {
+			return bc.failPayout(payout, err)
+		}
+		bc.logger.Warn(fmt.Sprintf("sending sweep %s returned an error but the bundle is on the tangle: %s", payout.ID.Hex(), err.Error()))
+		return bc.markPayoutSent(payout)
+	}
+
+	payout.TailHash = bndl[0].Hash
+	payout.TailHashes = []string{payout.TailHash}
+	return bc.markPayoutSent(payout)
+}
+
+// sweepInputs searches the key indices from which the given pool addresses of the bounty were generated
+// and returns the ones holding funds as inputs of the sweep.
+func (bc *BountyCtrl) sweepInputs(bounty *models.Bounty, addrs trinary.Hashes, balances []uint64) ([]api.Input, error) {
+	secLvl := consts.SecurityLevel(bc.Config.Account.SecurityLevel)
+	generated, err := address.GenerateAddresses(bounty.Seed, 0, poolAddressSearchDepth, secLvl)
+	if err != nil {
+		return nil, err
+	}
+	keyIndices := map[trinary.Hash]uint64{}
+	for i, addr := range generated {
+		keyIndices[addr] = uint64(i)
+	}
+
+	inputs := []api.Input{}
+	for i, addr := range addrs {
+		if balances[i] == 0 {
+			continue
+		}
+		keyIndex, ok := keyIndices[addr]
+		if !ok {
+			return nil, errors.Wrapf(ErrPoolAddressKeyIndexNotFound, "address %s of bounty '%d'", addr, bounty.ID)
+		}
+		inputs = append(inputs, api.Input{Balance: balances[i], Address: addr, KeyIndex: keyIndex, Security: secLvl})
+	}
+	return inputs, nil
+}
+
+// reattachSweep reattaches the bundle of a sent sweep if its latest tail can no longer be promoted.
+// Transfers and refunds are reattached by the promoter of the account instead.
+func (bc *BountyCtrl) reattachSweep(payout *models.Payout, tails trinary.Hashes) (trinary.Hashes, error) {
+	if len(tails) == 0 {
+		return tails, nil
+	}
+	promotable, err := bc.iotaAPI.IsPromotable(tails[len(tails)-1])
+	if err != nil || promotable {
+		return tails, err
+	}
+	bndl, err := bc.iotaAPI.ReplayBundle(tails[len(tails)-1], bc.Config.Account.GTTADepth, bc.Config.Account.MWM)
+	if err != nil {
+		return tails, err
+	}
+	bc.logger.Info(fmt.Sprintf("reattached sweep %s of bounty %d", payout.ID.Hex(), payout.BountyID))
+	return append(tails, bndl[0].Hash), nil
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+	"strings"
+	"time"
+)
+
+const tokenCollection = "api_tokens"
+
+// API tokens start with the prefix to make them recognizable, e.g. by secret scanners
+const apiTokenPrefix = "ibp_"
+
+// the amount of characters of a token stored to tell tokens apart
+const apiTokenPrefixLength = 12
+
+const maxTokenNameLength = 100
+
+// the last use of a token is recorded at most once per interval
+const tokenLastUsedInterval = time.Minute
+
+var ErrInvalidAPIToken = errors.New("invalid API token")
+var ErrInvalidTokenRequest = errors.New("invalid API token request")
+
+func (ac *AuthCtrl) initTokenIndexes() error {
+	t := true
+	hashIndexName := "hash"
+	ownerIndexName := "owner_id"
+	_, err := ac.TokenColl.Indexes().CreateMany(DefaultCtx(), []mongo.IndexModel{
+		{
+			Keys:    bsonx.Doc{{Key: "hash", Value: bsonx.Int32(int32(1))}},
+			Options: &options.IndexOptions{Name: &hashIndexName, Unique: &t},
+		},
+		{
+			Keys:    bsonx.Doc{{Key: "owner_id", Value: bsonx.Int32(int32(1))}},
+			Options: &options.IndexOptions{Name: &ownerIndexName},
+		},
+	})
+	return errors.Wrap(err, "(auth) couldn't create API token indexes")
+}
+
+// CreateToken creates an API token on behalf of the user of the given session. Tokens created without
+// a session (through basic auth) are always service tokens. The token itself is only returned here.
+func (ac *AuthCtrl) CreateToken(req *models.NewAPIToken, owner *models.Session) (*models.CreatedAPIToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxTokenNameLength {
+		return nil, errors.Wrap(ErrInvalidTokenRequest, "the name must be between 1 and 100 characters")
+	}
+	scopes, err := validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresOn != nil && !req.ExpiresOn.After(time.Now()) {
+		return nil, errors.Wrap(ErrInvalidTokenRequest, "the expiry must be in the future")
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	token := apiTokenPrefix + secret
+
+	apiToken := models.APIToken{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Hash:      hashToken(token),
+		Prefix:    token[:apiTokenPrefixLength],
+		Scopes:    scopes,
+		Service:   req.Service || owner == nil,
+		CreatedOn: time.Now(),
+		ExpiresOn: req.ExpiresOn,
+	}
+	if owner != nil {
+		apiToken.OwnerID = owner.UserID
+		apiToken.OwnerLogin = owner.Login
+	}
+	if _, err := ac.TokenColl.InsertOne(DefaultCtx(), apiToken); err != nil {
+		return nil, errors.Wrapf(err, "(auth) couldn't store API token '%s'", name)
+	}
+	ac.logger.Info(fmt.Sprintf("created API token '%s' (%s) with scopes %v", name, apiToken.Prefix, scopes))
+	return &models.CreatedAPIToken{APIToken: apiToken, Token: token}, nil
+}
+
+func validateScopes(scopes []models.TokenScope) ([]models.TokenScope, error) {
+	if len(scopes) == 0 {
+		return nil, errors.Wrap(ErrInvalidTokenRequest, "at least one scope is required")
+	}
+	// deduplicated and in the order of the defined scopes
+	requested := map[models.TokenScope]bool{}
+	for _, s := range scopes {
+		requested[s] = true
+	}
+	valid := []models.TokenScope{}
+	for _, scope := range models.TokenScopes {
+		if requested[scope] {
+			valid = append(valid, scope)
+			delete(requested, scope)
+		}
+	}
+	for s := range requested {
+		return nil, errors.Wrapf(ErrInvalidTokenRequest, "unknown scope '%s'", s)
+	}
+	return valid, nil
+}
+
+// GetTokens returns the API tokens of the given user, or all tokens if the user ID is 0.
+func (ac *AuthCtrl) GetTokens(ownerID int64) ([]models.APIToken, error) {
+	filter := bson.D{}
+	if ownerID != 0 {
+		filter = append(filter, bson.E{"owner_id", ownerID})
+	}
+	opts := options.Find().SetSort(bson.D{{"created_on", -1}})
+	cursor, err := ac.TokenColl.Find(DefaultCtx(), filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "(auth) couldn't load API tokens")
+	}
+	tokens := []models.APIToken{}
+	for cursor.Next(DefaultCtx()) {
+		var token models.APIToken
+		if err := cursor.Decode(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, errors.Wrap(cursor.Err(), "(auth) couldn't load API tokens")
+}
+
+func (ac *AuthCtrl) GetTokenByID(id primitive.ObjectID) (*models.APIToken, error) {
+	token := &models.APIToken{}
+	err := ac.TokenColl.FindOne(DefaultCtx(), bson.D{{"_id", id}}).Decode(token)
+	return token, errors.Wrapf(err, "(auth) couldn't load API token '%s'", id.Hex())
+}
+
+// DeleteToken revokes the given API token.
+func (ac *AuthCtrl) DeleteToken(token *models.APIToken) error {
+	if _, err := ac.TokenColl.DeleteOne(DefaultCtx(), bson.D{{"_id", token.ID}}); err != nil {
+		return errors.Wrapf(err, "(auth) couldn't delete API token '%s'", token.ID.Hex())
+	}
+	ac.logger.Info(fmt.Sprintf("deleted API token '%s' (%s)", token.Name, token.Prefix))
+	return nil
+}
+
+// Authenticate returns the unexpired API token matching the given bearer token and records its use.
+func (ac *AuthCtrl) Authenticate(token string) (*models.APIToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+	apiToken := &models.APIToken{}
+	if err := ac.TokenColl.FindOne(DefaultCtx(), bson.D{{"hash", hashToken(token)}}).Decode(apiToken); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, errors.Wrap(err, "(auth) couldn't load API token")
+	}
+	now := time.Now()
+	if apiToken.ExpiresOn != nil && apiToken.ExpiresOn.Before(now) {
+		return nil, errors.Wrapf(ErrInvalidAPIToken, "token '%s' expired", apiToken.Prefix)
+	}
+
+	if apiToken.LastUsedOn == nil || now.Sub(*apiToken.LastUsedOn) > tokenLastUsedInterval {
+		mut := bson.D{{"$set", bson.D{{"last_used_on", now}}}}
+		if _, err := ac.TokenColl.UpdateOne(DefaultCtx(), bson.D{{"_id", apiToken.ID}}, mut); err != nil {
+			ac.logger.Warn(fmt.Sprintf("couldn't record use of API token '%s': %s", apiToken.Prefix, err.Error()))
+		}
+		apiToken.LastUsedOn = &now
+	}
+	return apiToken, nil
+}
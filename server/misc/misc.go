@@ -3,13 +3,13 @@ package misc
 import (
 	"crypto/rand"
 	"fmt"
-	"github.com/iotaledger/iota.go/consts"
 	"github.com/mattn/go-colorable"
 	"github.com/pkg/errors"
 	"gopkg.in/inconshreveable/log15.v2"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 )
 
 var Debug = false
@@ -52,22 +52,6 @@ func GenerateAlphanumericCode(length int) string {
 	return pw
 }
 
-const seedLength = 81
-
-var tryteAlphabetLength = byte(len(consts.TryteAlphabet))
-
-func GenerateSeed() (string, error) {
-	var by [seedLength]byte
-	if _, err := rand.Read(by[:]); err != nil {
-		return "", err
-	}
-	var seed string
-	for _, b := range by {
-		seed += string(consts.TryteAlphabet[b%tryteAlphabetLength])
-	}
-	return seed, nil
-}
-
 const githubFrag = "github.com"
 
 var ErrRepoURLInvalid = errors.New("repository URL invalid")
@@ -87,3 +71,18 @@ func ExtractOwnerAndNameFromGitHubURL(repoURL string) (string, string, error) {
 	}
 	return urlSplit[splitLength-2], urlSplit[splitLength-1], nil
 }
+
+var ErrDeadlineInvalid = errors.New("deadline invalid, use YYYY-MM-DD or RFC3339")
+
+// ParseDeadline parses a deadline either as a date (end of the day in UTC) or as a RFC3339 timestamp.
+func ParseDeadline(deadline string) (time.Time, error) {
+	deadline = strings.TrimSpace(deadline)
+	if t, err := time.Parse("2006-01-02", deadline); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, deadline)
+	if err != nil {
+		return time.Time{}, ErrDeadlineInvalid
+	}
+	return t, nil
+}
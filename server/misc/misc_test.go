@@ -0,0 +1,33 @@
+package misc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline string
+		want     time.Time
+		wantErr  error
+	}{
+		{"date is the end of the day", "2026-10-19", time.Date(2026, 10, 19, 23, 59, 59, 0, time.UTC), nil},
+		{"surrounding whitespace", " 2026-10-19\n", time.Date(2026, 10, 19, 23, 59, 59, 0, time.UTC), nil},
+		{"timestamp", "2026-10-19T12:30:00Z", time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC), nil},
+		{"timestamp with offset", "2026-10-19T12:30:00+02:00", time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC), nil},
+		{"empty", "", time.Time{}, ErrDeadlineInvalid},
+		{"other date format", "19.10.2026", time.Time{}, ErrDeadlineInvalid},
+		{"invalid date", "2026-02-30", time.Time{}, ErrDeadlineInvalid},
+	}
+	for _, test := range tests {
+		got, err := ParseDeadline(test.deadline)
+		if err != test.wantErr {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package models
 
 import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
+// APIVersion is the version of the REST API described by /api/openapi.json.
+// The major version is bumped on incompatible changes, the minor version on additions.
+const APIVersion = "1.0.0"
+
 type Model struct {
 	CreatedOn time.Time  `json:"created_on,omitempty" bson:"created_on,omitempty"`
 	UpdatedOn *time.Time `json:"updated_on,omitempty" bson:"updated_on,omitempty"`
@@ -11,11 +16,23 @@ type Model struct {
 
 type Repository struct {
 	Model       `json:",inline"`
-	ID          int64  `json:"id" bson:"_id"`
-	Owner       string `json:"owner" bson:"owner"`
-	Name        string `json:"name" bson:"name"`
-	URL         string `json:"url" bson:"url"`
-	Description string `json:"description" bson:"description"`
+	ID          int64              `json:"id" bson:"_id"`
+	Owner       string             `json:"owner" bson:"owner"`
+	Name        string             `json:"name" bson:"name"`
+	URL         string             `json:"url" bson:"url"`
+	Description string             `json:"description" bson:"description"`
+	Settings    RepositorySettings `json:"settings" bson:"settings"`
+}
+
+// RepositorySettings are per repository adjustments of the platform behaviour.
+type RepositorySettings struct {
+	// overrides the platform fee defined in the config if set
+	PlatformFeePercent   *float64 `json:"platform_fee_percent,omitempty" bson:"platform_fee_percent,omitempty"`
+	MaintainerTipPercent float64  `json:"maintainer_tip_percent" bson:"maintainer_tip_percent"`
+	MaintainerTipAddress string   `json:"maintainer_tip_address" bson:"maintainer_tip_address"`
+	// override the funding notice threshold and quiet period defined in the config if set
+	FundingNoticeThreshold    *uint64 `json:"funding_notice_threshold,omitempty" bson:"funding_notice_threshold,omitempty"`
+	FundingNoticeQuietMinutes *int    `json:"funding_notice_quiet_minutes,omitempty" bson:"funding_notice_quiet_minutes,omitempty"`
 }
 
 type BountyState int
@@ -24,26 +41,675 @@ const (
 	BountyStateOpen BountyState = iota
 	BountyStateReleased
 	BountyStateTransferred
+	BountyStateRefunding
+	BountyStateRefunded
+	BountyStateExpired
+	BountyStateConfirmed
 )
 
 type Bounty struct {
+	Model               `json:",inline"`
+	ID                  int64            `json:"id" bson:"_id"`
+	IssueNumber         int              `json:"issue_number" bson:"issue_number"`
+	RepositoryID        int64            `json:"repository_id" bson:"repository_id"`
+	ReceiverID          int64            `json:"receiver_id" bson:"receiver_id"`
+	ReleasedBy          int64            `json:"released_by" bson:"released_by"`
+	Seed                string           `json:"-" bson:"seed,omitempty"`
+	EncryptedSeed       *EncryptedSecret `json:"-" bson:"encrypted_seed,omitempty"`
+	SeedIndex           *int64           `json:"seed_index,omitempty" bson:"seed_index,omitempty"`
+	PoolAddress         string           `json:"pool_address" bson:"pool_address"`
+	ReceiverAddress     string           `json:"receiver_address" bson:"receiver_address"`
+	BundleHash          string           `json:"bundle_hash" bson:"bundle_hash"`
+	Balance             uint64           `json:"balance" bson:"balance"`
+	URL                 string           `json:"url" bson:"url"`
+	Title               string           `json:"title" bson:"title"`
+	Body                string           `json:"body" bson:"body"`
+	State               BountyState      `json:"state" bson:"state"`
+	Contributions       []Contribution   `json:"contributions" bson:"contributions"`
+	Refunds             []Refund         `json:"refunds" bson:"refunds"`
+	RefundBundleHash    string           `json:"refund_bundle_hash" bson:"refund_bundle_hash"`
+	Deadline            *time.Time       `json:"deadline,omitempty" bson:"deadline,omitempty"`
+	ExpiredOn           *time.Time       `json:"expired_on,omitempty" bson:"expired_on,omitempty"`
+	RemindersSent       []int            `json:"-" bson:"reminders_sent"`
+	Payout              *PayoutSplit     `json:"payout,omitempty" bson:"payout,omitempty"`
+	LateBalance         uint64           `json:"late_balance" bson:"late_balance"`
+	LateBalanceNotified uint64           `json:"-" bson:"late_balance_notified"`
+	SweepBundleHashes   []string         `json:"sweep_bundle_hashes,omitempty" bson:"sweep_bundle_hashes,omitempty"`
+	FundingNotice       *FundingNotice   `json:"-" bson:"funding_notice,omitempty"`
+	Milestones          []Milestone      `json:"milestones" bson:"milestones,omitempty"`
+	MilestoneSource     MilestoneSource  `json:"milestone_source,omitempty" bson:"milestone_source,omitempty"`
+	// the iotas paid out through milestones, the rest remains in the pool
+	PaidOut uint64 `json:"paid_out" bson:"paid_out"`
+	// copies of the repository's name and description for the full-text search
+	RepoName        string `json:"-" bson:"repo_name,omitempty"`
+	RepoDescription string `json:"-" bson:"repo_description,omitempty"`
+	// set when a milestone payout spent the pool address and a new one must be allocated
+	PoolAddressSpent bool `json:"-" bson:"pool_address_spent,omitempty"`
+	// the previous pool addresses spent by milestone payouts, checked for late deposits
+	SpentPoolAddresses []string   `json:"spent_pool_addresses,omitempty" bson:"spent_pool_addresses,omitempty"`
+	Fiat               *FiatValue `json:"fiat,omitempty" bson:"-"`
+}
+
+// NewBounty is the request to create a bounty for an issue.
+type NewBounty struct {
+	Owner   string `json:"owner"`
+	Name    string `json:"name"`
+	IssueID int    `json:"issue_id"`
+	// YYYY-MM-DD (end of the day in UTC) or RFC 3339
+	Deadline string `json:"deadline,omitempty"`
+}
+
+// FiatValue is the value of a bounty's balance in fiat currencies at the given time.
+type FiatValue struct {
+	USD            float64   `json:"usd"`
+	EUR            float64   `json:"eur"`
+	RatesUpdatedOn time.Time `json:"rates_updated_on"`
+}
+
+type MilestoneSource string
+
+const (
+	// the milestones were defined through the API and are no longer taken from the issue
+	MilestoneSourceAPI MilestoneSource = "api"
+	// the milestones are parsed from the task list in the issue body
+	MilestoneSourceIssue MilestoneSource = "issue"
+)
+
+type MilestoneState int
+
+const (
+	MilestoneStateOpen MilestoneState = iota
+	MilestoneStateReleased
+	MilestoneStatePaid
+)
+
+// Milestone is a stage of a bounty which is paid out on its own. Its share is either a fixed
+// amount of iotas or a percentage of the total funding of the bounty (its balance plus the iotas already paid out).
+type Milestone struct {
+	Title      string         `json:"title" bson:"title"`
+	Value      uint64         `json:"value,omitempty" bson:"value,omitempty"`
+	Percent    float64        `json:"percent,omitempty" bson:"percent,omitempty"`
+	State      MilestoneState `json:"state" bson:"state"`
+	ReceiverID int64          `json:"receiver_id,omitempty" bson:"receiver_id,omitempty"`
+	ReleasedBy int64          `json:"released_by,omitempty" bson:"released_by,omitempty"`
+	Paid       uint64         `json:"paid,omitempty" bson:"paid,omitempty"`
+	BundleHash string         `json:"bundle_hash,omitempty" bson:"bundle_hash,omitempty"`
+}
+
+// FundingNotice is the last comment announcing an increase of a bounty's balance.
+// Further increases within the quiet period are folded into it.
+type FundingNotice struct {
+	CommentID int64 `bson:"comment_id"`
+	// the balance before the increases announced in the comment
+	From uint64 `bson:"from"`
+	// the balance announced in the comment, increases are measured from it
+	To       uint64    `bson:"to"`
+	PostedOn time.Time `bson:"posted_on"`
+}
+
+// PayoutSplit is the breakdown of a bounty transfer into the part for the receiver,
+// the platform fee and the maintainer tip.
+type PayoutSplit struct {
+	Total      uint64 `json:"total" bson:"total"`
+	Receiver   uint64 `json:"receiver" bson:"receiver"`
+	Fee        uint64 `json:"fee" bson:"fee"`
+	FeeAddress string `json:"fee_address" bson:"fee_address"`
+	Tip        uint64 `json:"tip" bson:"tip"`
+	TipAddress string `json:"tip_address" bson:"tip_address"`
+}
+
+// Settled tells whether the funds of the bounty have left or are leaving the pool address.
+func (b *Bounty) Settled() bool {
+	switch b.State {
+	case BountyStateTransferred, BountyStateRefunding, BountyStateRefunded, BountyStateConfirmed:
+		return true
+	}
+	return false
+}
+
+// EncryptedSecret is a secret encrypted with a data key, which itself is wrapped by the master key with the given id.
+type EncryptedSecret struct {
+	KeyID      string `bson:"key_id"`
+	WrappedKey []byte `bson:"wrapped_key"`
+	Ciphertext []byte `bson:"ciphertext"`
+}
+
+// Contribution is a confirmed deposit onto the pool address of a bounty.
+type Contribution struct {
+	BundleHash    string `json:"bundle_hash" bson:"bundle_hash"`
+	Value         uint64 `json:"value" bson:"value"`
+	RefundAddress string `json:"refund_address" bson:"refund_address"`
+	RegisteredBy  int64  `json:"registered_by" bson:"registered_by"`
+	// when the sync first saw the confirmed deposit
+	DetectedOn *time.Time `json:"detected_on,omitempty" bson:"detected_on,omitempty"`
+	// set if the deposit is a matching transfer of a campaign
+	CampaignID *primitive.ObjectID `json:"campaign_id,omitempty" bson:"campaign_id,omitempty"`
+}
+
+// Refund is a single payout made when a bounty got cancelled. BundleHash references the
+// refunded contribution, treasury refunds hold the share of contributions without a refund address.
+type Refund struct {
+	BundleHash string `json:"bundle_hash" bson:"bundle_hash"`
+	Address    string `json:"address" bson:"address"`
+	Value      uint64 `json:"value" bson:"value"`
+	Treasury   bool   `json:"treasury" bson:"treasury"`
+}
+
+type PayoutKind int
+
+const (
+	PayoutKindTransfer PayoutKind = iota
+	PayoutKindRefund
+	// late deposits swept off the spent pool address
+	PayoutKindSweep
+	// the share of a single milestone, the rest remains in the pool
+	PayoutKindMilestone
+)
+
+type SweepTarget string
+
+const (
+	SweepTargetReceiver SweepTarget = "receiver"
+	SweepTargetTreasury SweepTarget = "treasury"
+)
+
+type PayoutState int
+
+const (
+	// the payout is persisted before the bundle gets signed
+	PayoutStatePending PayoutState = iota
+	// the bundle was stored in the account and broadcasted
+	PayoutStateSent
+	// the payout failed before the bundle left the platform and can be retried
+	PayoutStateFailed
+	// one of the tails (origin or reattachment) of the bundle got confirmed
+	PayoutStateConfirmed
+	// the value exceeds the approval threshold and a second person must approve the payout before it is sent
+	PayoutStateAwaitingApproval
+	// the payout wasn't approved in time
+	PayoutStateExpired
+	// the payout was rejected instead of approved
+	PayoutStateRejected
+)
+
+// Payout is an outbox entry of an outgoing transfer of a bounty's funds.
+// It is created before the bundle is signed so that a crash can never lead to sending twice.
+type Payout struct {
 	Model           `json:",inline"`
-	ID              int64       `json:"id" bson:"_id"`
-	IssueNumber     int         `json:"issue_number" bson:"issue_number"`
-	RepositoryID    int64       `json:"repository_id" bson:"repository_id"`
-	ReceiverID      int64       `json:"receiver_id" bson:"receiver_id"`
-	Seed            string      `json:"-" bson:"seed"`
-	PoolAddress     string      `json:"pool_address" bson:"pool_address"`
-	ReceiverAddress string      `json:"receiver_address" bson:"receiver_address"`
-	BundleHash      string      `json:"bundle_hash" bson:"bundle_hash"`
-	Balance         uint64      `json:"balance" bson:"balance"`
-	URL             string      `json:"url" bson:"url"`
-	Title           string      `json:"title" bson:"title"`
-	Body            string      `json:"body" bson:"body"`
-	State           BountyState `json:"state" bson:"state"`
+	ID              primitive.ObjectID `json:"id" bson:"_id"`
+	BountyID        int64              `json:"bounty_id" bson:"bounty_id"`
+	Kind            PayoutKind         `json:"kind" bson:"kind"`
+	State           PayoutState        `json:"state" bson:"state"`
+	Value           uint64             `json:"value" bson:"value"`
+	ReceiverAddress string             `json:"receiver_address" bson:"receiver_address"`
+	// the GitHub user the bounty (or milestone) was released to when the payout was requested
+	ReceiverID  int64        `json:"receiver_id,omitempty" bson:"receiver_id,omitempty"`
+	Split       *PayoutSplit `json:"split,omitempty" bson:"split,omitempty"`
+	Refunds     []Refund     `json:"refunds,omitempty" bson:"refunds,omitempty"`
+	SweepTarget SweepTarget  `json:"sweep_target,omitempty" bson:"sweep_target,omitempty"`
+	// the number (starting at 1) of the paid out milestone
+	Milestone     int        `json:"milestone,omitempty" bson:"milestone,omitempty"`
+	RequestedBy   int64      `json:"requested_by,omitempty" bson:"requested_by,omitempty"`
+	ApprovedBy    int64      `json:"approved_by,omitempty" bson:"approved_by,omitempty"`
+	ApprovedOn    *time.Time `json:"approved_on,omitempty" bson:"approved_on,omitempty"`
+	ExpiresOn     *time.Time `json:"expires_on,omitempty" bson:"expires_on,omitempty"`
+	BundleHash    string     `json:"bundle_hash" bson:"bundle_hash"`
+	TailHash      string     `json:"tail_hash" bson:"tail_hash"`
+	TailHashes    []string   `json:"tail_hashes" bson:"tail_hashes"`
+	Error         string     `json:"error,omitempty" bson:"error,omitempty"`
+	SentOn        *time.Time `json:"sent_on,omitempty" bson:"sent_on,omitempty"`
+	ConfirmedOn   *time.Time `json:"confirmed_on,omitempty" bson:"confirmed_on,omitempty"`
+	LastCheckedOn *time.Time `json:"last_checked_on,omitempty" bson:"last_checked_on,omitempty"`
+}
+
+// Campaign is the promise of a sponsor to match the contributions to the bounties in its scope
+// from a platform account the sponsor funds.
+type Campaign struct {
+	Model   `json:",inline"`
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	Sponsor string             `json:"sponsor" bson:"sponsor"`
+	// the repositories and bounties whose contributions are matched, all bounties if both are empty
+	RepositoryIDs []int64 `json:"repository_ids" bson:"repository_ids"`
+	BountyIDs     []int64 `json:"bounty_ids" bson:"bounty_ids"`
+	// the iotas matched per contributed iota
+	Ratio float64 `json:"ratio" bson:"ratio"`
+	// the maximum amount of iotas matched over the whole campaign
+	Cap      uint64     `json:"cap" bson:"cap"`
+	StartsOn time.Time  `json:"starts_on" bson:"starts_on"`
+	EndsOn   time.Time  `json:"ends_on" bson:"ends_on"`
+	EndedOn  *time.Time `json:"ended_on,omitempty" bson:"ended_on,omitempty"`
+	// the seed of the campaign account is derived from the master seed
+	SeedIndex int64 `json:"-" bson:"seed_index"`
+	// the address the sponsor sends the funds to, renewed after each transfer as the previous one is spent
+	FundingAddress string `json:"funding_address" bson:"funding_address"`
+	// the address (with checksum) receiving the leftover funds and the refunds of matched contributions
+	RefundAddress string `json:"refund_address" bson:"refund_address"`
+	// the iotas committed to matching transfers which didn't fail, computed from the transfers
+	Matched uint64 `json:"matched" bson:"-"`
+	// the available balance of the campaign account as of the last sync
+	Balance uint64 `json:"balance" bson:"balance"`
+}
+
+// Active tells whether contributions detected at the given time are matched.
+func (c *Campaign) Active(t time.Time) bool {
+	return !t.Before(c.StartsOn) && t.Before(c.End())
+}
+
+// End returns the time at which the campaign ended or ends.
+func (c *Campaign) End() time.Time {
+	if c.EndedOn != nil && c.EndedOn.Before(c.EndsOn) {
+		return *c.EndedOn
+	}
+	return c.EndsOn
+}
+
+// Covers tells whether the given bounty is within the scope of the campaign.
+func (c *Campaign) Covers(bounty *Bounty) bool {
+	if len(c.RepositoryIDs) == 0 && len(c.BountyIDs) == 0 {
+		return true
+	}
+	for _, id := range c.RepositoryIDs {
+		if id == bounty.RepositoryID {
+			return true
+		}
+	}
+	for _, id := range c.BountyIDs {
+		if id == bounty.ID {
+			return true
+		}
+	}
+	return false
+}
+
+type CampaignTransferKind string
+
+const (
+	// matches a contribution by sending onto the pool address of its bounty
+	CampaignTransferKindMatch CampaignTransferKind = "match"
+	// returns the leftover funds of an ended campaign to the sponsor
+	CampaignTransferKindWithdrawal CampaignTransferKind = "withdrawal"
+)
+
+// CampaignTransfer is an outbox entry of an outgoing transfer of a campaign account.
+type CampaignTransfer struct {
+	Model      `json:",inline"`
+	ID         primitive.ObjectID   `json:"id" bson:"_id"`
+	CampaignID primitive.ObjectID   `json:"campaign_id" bson:"campaign_id"`
+	Kind       CampaignTransferKind `json:"kind" bson:"kind"`
+	// the matched contribution
+	BountyID               int64       `json:"bounty_id,omitempty" bson:"bounty_id,omitempty"`
+	ContributionBundleHash string      `json:"contribution_bundle_hash,omitempty" bson:"contribution_bundle_hash,omitempty"`
+	ContributionValue      uint64      `json:"contribution_value,omitempty" bson:"contribution_value,omitempty"`
+	Value                  uint64      `json:"value" bson:"value"`
+	Address                string      `json:"address" bson:"address"`
+	State                  PayoutState `json:"state" bson:"state"`
+	BundleHash             string      `json:"bundle_hash" bson:"bundle_hash"`
+	Error                  string      `json:"error,omitempty" bson:"error,omitempty"`
+	SentOn                 *time.Time  `json:"sent_on,omitempty" bson:"sent_on,omitempty"`
+	ConfirmedOn            *time.Time  `json:"confirmed_on,omitempty" bson:"confirmed_on,omitempty"`
 }
 
 // Used to circumvent duplicated _id fields
 type DeletedModel struct {
 	Object interface{} `json:"object" bson:"object"`
 }
+
+type ActorSource string
+
+const (
+	// a command posted as comment on the bounty's issue
+	ActorSourceGitHub ActorSource = "github"
+	// a call of the admin API, the GitHub user is set if the caller signed in through OAuth
+	ActorSourceAPI ActorSource = "api"
+	// the platform itself, e.g. the sync loop expiring a bounty
+	ActorSourcePlatform ActorSource = "platform"
+)
+
+// Actor is the initiator of a change of a bounty.
+type Actor struct {
+	Source     ActorSource `json:"source" bson:"source"`
+	GitHubID   int64       `json:"github_id,omitempty" bson:"github_id,omitempty"`
+	Login      string      `json:"login,omitempty" bson:"login,omitempty"`
+	CommentURL string      `json:"comment_url,omitempty" bson:"comment_url,omitempty"`
+	// the name of the API token used for the call
+	Token string `json:"token,omitempty" bson:"token,omitempty"`
+}
+
+type AuditEventKind string
+
+const (
+	AuditEventCreated                 AuditEventKind = "created"
+	AuditEventReleased                AuditEventKind = "released"
+	AuditEventReceiverChanged         AuditEventKind = "receiver_changed"
+	AuditEventTransferAttempted       AuditEventKind = "transfer_attempted"
+	AuditEventTransferHeld            AuditEventKind = "transfer_held"
+	AuditEventTransferSent            AuditEventKind = "transfer_sent"
+	AuditEventTransferFailed          AuditEventKind = "transfer_failed"
+	AuditEventPayoutApproved          AuditEventKind = "payout_approved"
+	AuditEventPayoutWithdrawn         AuditEventKind = "payout_withdrawn"
+	AuditEventPayoutConfirmed         AuditEventKind = "payout_confirmed"
+	AuditEventCancelled               AuditEventKind = "cancelled"
+	AuditEventDeadlineSet             AuditEventKind = "deadline_set"
+	AuditEventExpired                 AuditEventKind = "expired"
+	AuditEventRefundAddressRegistered AuditEventKind = "refund_address_registered"
+	AuditEventLateDepositsSwept       AuditEventKind = "late_deposits_swept"
+	AuditEventContributionMatched     AuditEventKind = "contribution_matched"
+	AuditEventMilestonesSet           AuditEventKind = "milestones_set"
+	AuditEventMilestoneReleased       AuditEventKind = "milestone_released"
+	AuditEventMilestonePaid           AuditEventKind = "milestone_paid"
+	AuditEventPoolAddressRenewed      AuditEventKind = "pool_address_renewed"
+	AuditEventSynced                  AuditEventKind = "synced"
+	AuditEventDeleted                 AuditEventKind = "deleted"
+)
+
+// AuditChange is a single field changed by an audit event. Values are stored in their
+// textual representation so that the hash of the event can be recomputed from the stored document.
+type AuditChange struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before" bson:"before"`
+	After  string `json:"after" bson:"after"`
+}
+
+// AuditEvent is an entry of the append-only audit log. Each event contains the hash of its
+// predecessor, so that modifying or removing an event breaks the chain.
+type AuditEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Seq       int64              `json:"seq" bson:"seq"`
+	BountyID  int64              `json:"bounty_id" bson:"bounty_id"`
+	Kind      AuditEventKind     `json:"kind" bson:"kind"`
+	Actor     Actor              `json:"actor" bson:"actor"`
+	Changes   []AuditChange      `json:"changes" bson:"changes"`
+	Note      string             `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedOn time.Time          `json:"created_on" bson:"created_on"`
+	PrevHash  string             `json:"prev_hash" bson:"prev_hash"`
+	Hash      string             `json:"hash" bson:"hash"`
+}
+
+type LiveEventKind string
+
+const (
+	LiveEventBountyCreated        LiveEventKind = "bounty_created"
+	LiveEventBountyBalanceChanged LiveEventKind = "bounty_balance_changed"
+	LiveEventBountyReleased       LiveEventKind = "bounty_released"
+	LiveEventBountyTransferred    LiveEventKind = "bounty_transferred"
+	LiveEventBountyRefunded       LiveEventKind = "bounty_refunded"
+	LiveEventBountyDeleted        LiveEventKind = "bounty_deleted"
+	LiveEventRepositoryCreated    LiveEventKind = "repository_created"
+	LiveEventRepositoryUpdated    LiveEventKind = "repository_updated"
+	LiveEventRepositoryDeleted    LiveEventKind = "repository_deleted"
+	// sent instead of the missed events if a stream can't be resumed, clients must reload their state
+	LiveEventReset LiveEventKind = "reset"
+)
+
+// LiveEvent announces a change of a bounty or repository to the clients streaming the changes.
+// It only carries what changed, clients load the bounty or repository for the details.
+type LiveEvent struct {
+	ID           uint64        `json:"id"`
+	Kind         LiveEventKind `json:"kind"`
+	RepositoryID int64         `json:"repository_id,omitempty"`
+	BountyID     int64         `json:"bounty_id,omitempty"`
+	State        *BountyState  `json:"state,omitempty"`
+	Balance      *uint64       `json:"balance,omitempty"`
+	// the number of the milestone a release or transfer belongs to
+	Milestone int       `json:"milestone,omitempty"`
+	CreatedOn time.Time `json:"created_on"`
+}
+
+type DiscrepancyKind string
+
+const (
+	// the stored balance of an unsettled bounty differs from the balance of its pool address
+	DiscrepancyBalanceMismatch DiscrepancyKind = "balance_mismatch"
+	// the pool address of an unsettled bounty which received funds is empty although no payout was made
+	DiscrepancyPoolDrained DiscrepancyKind = "pool_drained"
+	// the bundle of a paid out bounty didn't confirm within the stuck threshold
+	DiscrepancyBundleUnconfirmed DiscrepancyKind = "bundle_unconfirmed"
+	// the bundle of a paid out bounty can't be found on the tangle
+	DiscrepancyBundleNotFound DiscrepancyKind = "bundle_not_found"
+)
+
+// Discrepancy is a difference between the stored state of a bounty and the data on the tangle.
+type Discrepancy struct {
+	BountyID int64           `json:"bounty_id" bson:"bounty_id"`
+	Kind     DiscrepancyKind `json:"kind" bson:"kind"`
+	Stored   string          `json:"stored" bson:"stored"`
+	OnTangle string          `json:"on_tangle" bson:"on_tangle"`
+	Detail   string          `json:"detail,omitempty" bson:"detail,omitempty"`
+}
+
+// ReconciliationReport is the result of comparing all bounties with the tangle.
+type ReconciliationReport struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id"`
+	StartedOn       time.Time          `json:"started_on" bson:"started_on"`
+	FinishedOn      time.Time          `json:"finished_on" bson:"finished_on"`
+	BountiesChecked int                `json:"bounties_checked" bson:"bounties_checked"`
+	Discrepancies   []Discrepancy      `json:"discrepancies" bson:"discrepancies"`
+	// bounties which couldn't be checked
+	Errors []string `json:"errors" bson:"errors"`
+}
+
+// SearchResult holds the bounties and repositories matching a full-text search, best matches first.
+type SearchResult struct {
+	Bounties     []BountySearchHit     `json:"bounties"`
+	Repositories []RepositorySearchHit `json:"repositories"`
+}
+
+type BountySearchHit struct {
+	Bounty Bounty  `json:"bounty"`
+	Score  float64 `json:"score"`
+	// excerpts of the matching fields with the search terms wrapped in <mark> tags
+	Snippets map[string]string `json:"snippets"`
+}
+
+type RepositorySearchHit struct {
+	Repository Repository        `json:"repository"`
+	Score      float64           `json:"score"`
+	Snippets   map[string]string `json:"snippets"`
+}
+
+// PublicBounty is the view of a bounty served to anonymous visitors. The fields are copied one by one,
+// so that fields added to Bounty are only exposed once they are added here.
+type PublicBounty struct {
+	ID           int64             `json:"id"`
+	IssueNumber  int               `json:"issue_number"`
+	RepositoryID int64             `json:"repository_id"`
+	PoolAddress  string            `json:"pool_address"`
+	Balance      uint64            `json:"balance"`
+	URL          string            `json:"url"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body"`
+	State        BountyState       `json:"state"`
+	Deadline     *time.Time        `json:"deadline,omitempty"`
+	ExpiredOn    *time.Time        `json:"expired_on,omitempty"`
+	Milestones   []PublicMilestone `json:"milestones"`
+	PaidOut      uint64            `json:"paid_out"`
+	// shown so that visitors don't send tokens to them
+	SpentPoolAddresses []string   `json:"spent_pool_addresses,omitempty"`
+	Fiat               *FiatValue `json:"fiat,omitempty"`
+	CreatedOn          time.Time  `json:"created_on"`
+	UpdatedOn          *time.Time `json:"updated_on,omitempty"`
+}
+
+// PublicMilestone is the view of a milestone served to anonymous visitors.
+type PublicMilestone struct {
+	Title   string         `json:"title"`
+	Value   uint64         `json:"value,omitempty"`
+	Percent float64        `json:"percent,omitempty"`
+	State   MilestoneState `json:"state"`
+	Paid    uint64         `json:"paid,omitempty"`
+}
+
+// NewPublicBounty returns the public view of the given bounty, which leaves out the seed index, the
+// contributions and refunds with their addresses, the receiver and the payout split.
+func NewPublicBounty(b *Bounty) PublicBounty {
+	milestones := make([]PublicMilestone, len(b.Milestones))
+	for i, m := range b.Milestones {
+		milestones[i] = PublicMilestone{Title: m.Title, Value: m.Value, Percent: m.Percent, State: m.State, Paid: m.Paid}
+	}
+	return PublicBounty{
+		ID:                 b.ID,
+		IssueNumber:        b.IssueNumber,
+		RepositoryID:       b.RepositoryID,
+		PoolAddress:        b.PoolAddress,
+		Balance:            b.Balance,
+		URL:                b.URL,
+		Title:              b.Title,
+		Body:               b.Body,
+		State:              b.State,
+		Deadline:           b.Deadline,
+		ExpiredOn:          b.ExpiredOn,
+		Milestones:         milestones,
+		PaidOut:            b.PaidOut,
+		SpentPoolAddresses: b.SpentPoolAddresses,
+		Fiat:               b.Fiat,
+		CreatedOn:          b.CreatedOn,
+		UpdatedOn:          b.UpdatedOn,
+	}
+}
+
+// PublicRepository is the view of a repository served to anonymous visitors, it leaves out the settings.
+type PublicRepository struct {
+	ID          int64      `json:"id"`
+	Owner       string     `json:"owner"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+	CreatedOn   time.Time  `json:"created_on"`
+	UpdatedOn   *time.Time `json:"updated_on,omitempty"`
+}
+
+func NewPublicRepository(r *Repository) PublicRepository {
+	return PublicRepository{
+		ID:          r.ID,
+		Owner:       r.Owner,
+		Name:        r.Name,
+		URL:         r.URL,
+		Description: r.Description,
+		CreatedOn:   r.CreatedOn,
+		UpdatedOn:   r.UpdatedOn,
+	}
+}
+
+// PublicSearchResult is the view of a SearchResult served to anonymous visitors.
+type PublicSearchResult struct {
+	Bounties     []PublicBountySearchHit     `json:"bounties"`
+	Repositories []PublicRepositorySearchHit `json:"repositories"`
+}
+
+type PublicBountySearchHit struct {
+	Bounty   PublicBounty      `json:"bounty"`
+	Score    float64           `json:"score"`
+	Snippets map[string]string `json:"snippets"`
+}
+
+type PublicRepositorySearchHit struct {
+	Repository PublicRepository  `json:"repository"`
+	Score      float64           `json:"score"`
+	Snippets   map[string]string `json:"snippets"`
+}
+
+func NewPublicSearchResult(res *SearchResult) PublicSearchResult {
+	public := PublicSearchResult{
+		Bounties:     make([]PublicBountySearchHit, len(res.Bounties)),
+		Repositories: make([]PublicRepositorySearchHit, len(res.Repositories)),
+	}
+	for i := range res.Bounties {
+		hit := &res.Bounties[i]
+		public.Bounties[i] = PublicBountySearchHit{Bounty: NewPublicBounty(&hit.Bounty), Score: hit.Score, Snippets: hit.Snippets}
+	}
+	for i := range res.Repositories {
+		hit := &res.Repositories[i]
+		public.Repositories[i] = PublicRepositorySearchHit{Repository: NewPublicRepository(&hit.Repository), Score: hit.Score, Snippets: hit.Snippets}
+	}
+	return public
+}
+
+type Role string
+
+const (
+	// may do anything, defined in the configuration
+	RolePlatformAdmin Role = "platform_admin"
+	// may manage the repositories and bounties of repositories on which the user is a GitHub admin
+	RoleRepositoryManager Role = "repository_manager"
+	// may only read
+	RoleViewer Role = "viewer"
+)
+
+// Session is a server-side session of a user who signed in through GitHub.
+type Session struct {
+	// the SHA-256 hash of the session token, the token itself is only known to the client
+	ID        string    `json:"-" bson:"_id"`
+	UserID    int64     `json:"user_id" bson:"user_id"`
+	Login     string    `json:"login" bson:"login"`
+	AvatarURL string    `json:"avatar_url" bson:"avatar_url"`
+	CreatedOn time.Time `json:"created_on" bson:"created_on"`
+	ExpiresOn time.Time `json:"expires_on" bson:"expires_on"`
+}
+
+// User is the signed in user and the role it has on the platform.
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+	// platform admin or viewer, the repository manager role is determined per repository
+	Role Role `json:"role"`
+}
+
+type TokenScope string
+
+const (
+	// read all /api routes
+	TokenScopeRead TokenScope = "read"
+	// create and manage bounties
+	TokenScopeBountiesWrite TokenScope = "bounties:write"
+	// add, configure and delete repositories
+	TokenScopeReposWrite TokenScope = "repos:write"
+	// approve and reject payouts
+	TokenScopePayoutsApprove TokenScope = "payouts:approve"
+)
+
+var TokenScopes = []TokenScope{TokenScopeRead, TokenScopeBountiesWrite, TokenScopeReposWrite, TokenScopePayoutsApprove}
+
+// APIToken is a bearer token for automated access to the API. A personal token acts on behalf of its owner
+// and can't do more than its owner, a service token acts on behalf of the platform.
+type APIToken struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	Name string             `json:"name" bson:"name"`
+	// the SHA-256 hash of the token, the token itself is only returned on creation
+	Hash string `json:"-" bson:"hash"`
+	// the beginning of the token to tell tokens apart
+	Prefix  string       `json:"prefix" bson:"prefix"`
+	Scopes  []TokenScope `json:"scopes" bson:"scopes"`
+	Service bool         `json:"service" bson:"service"`
+	// the GitHub user who created the token, unset if it was created through basic auth
+	OwnerID    int64      `json:"owner_id,omitempty" bson:"owner_id,omitempty"`
+	OwnerLogin string     `json:"owner_login,omitempty" bson:"owner_login,omitempty"`
+	CreatedOn  time.Time  `json:"created_on" bson:"created_on"`
+	ExpiresOn  *time.Time `json:"expires_on" bson:"expires_on"`
+	LastUsedOn *time.Time `json:"last_used_on" bson:"last_used_on"`
+}
+
+// HasScope tells whether the token was granted the given scope.
+func (t *APIToken) HasScope(scope TokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken is the request to create an API token.
+type NewAPIToken struct {
+	Name    string       `json:"name"`
+	Scopes  []TokenScope `json:"scopes"`
+	Service bool         `json:"service"`
+	// unset for a token which never expires
+	ExpiresOn *time.Time `json:"expires_on"`
+}
+
+// CreatedAPIToken is returned once on creation of an API token.
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,220 @@
+package nodes
+
+import (
+	"github.com/iotaledger/iota.go/api"
+	"github.com/pkg/errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoNodes = errors.New("no IOTA nodes defined")
+var ErrAllNodesFailed = errors.New("all IOTA nodes failed to handle the request")
+
+// Status is the health state of a node as seen by the last health check and the requests routed to it.
+type Status struct {
+	URI                                string     `json:"uri"`
+	Healthy                            bool       `json:"healthy"`
+	Synced                             bool       `json:"synced"`
+	Active                             bool       `json:"active"`
+	AppName                            string     `json:"app_name"`
+	AppVersion                         string     `json:"app_version"`
+	LatestMilestoneIndex               int64      `json:"latest_milestone_index"`
+	LatestSolidSubtangleMilestoneIndex int64      `json:"latest_solid_subtangle_milestone_index"`
+	LatencyMs                          int64      `json:"latency_ms"`
+	ConsecutiveFailures                int        `json:"consecutive_failures"`
+	LastError                          string     `json:"last_error,omitempty"`
+	LastCheckedOn                      *time.Time `json:"last_checked_on"`
+}
+
+type node struct {
+	provider api.Provider
+	status   Status
+}
+
+// Pool is an api.Provider which routes every request to the healthiest node and fails over
+// to the next one if the node can't handle it.
+type Pool struct {
+	mu sync.RWMutex
+	// in the order the nodes were defined, which is used as tie breaker
+	nodes []*node
+	// the amount of milestones a node may lag behind and still be considered synced
+	maxMilestoneLag int64
+}
+
+// NewPool creates a new Pool for the given node URIs. Until the first health check ran, the nodes
+// are tried in the given order.
+func NewPool(uris []string, maxMilestoneLag int64, timeout time.Duration) (*Pool, error) {
+	if len(uris) == 0 {
+		return nil, ErrNoNodes
+	}
+	pool := &Pool{maxMilestoneLag: maxMilestoneLag}
+	client := &http.Client{Timeout: timeout}
+	for _, uri := range uris {
+		provider, err := api.NewHTTPClient(api.HTTPClientSettings{URI: uri, Client: client})
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid node '%s'", uri)
+		}
+		pool.nodes = append(pool.nodes, &node{provider: provider, status: Status{URI: uri, Healthy: true, Synced: true}})
+	}
+	return pool, nil
+}
+
+// CreateProvider returns the pool itself, so that it can be passed to api.ComposeAPI.
+func (p *Pool) CreateProvider(settings interface{}) (api.Provider, error) {
+	return p, nil
+}
+
+// SetSettings is a no-op, as the nodes are defined when the pool is created.
+func (p *Pool) SetSettings(settings interface{}) error {
+	return nil
+}
+
+// Send sends the given command to the healthiest node. Connection errors and server side errors
+// make the pool mark the node as unhealthy and retry the command on the next node. Errors caused
+// by the request itself are returned directly, as every other node would refuse it too.
+func (p *Pool) Send(cmd interface{}, out interface{}) error {
+	var lastErr error
+	for _, n := range p.ranked() {
+		err := n.provider.Send(cmd, out)
+		if err == nil {
+			p.markSuccess(n)
+			return nil
+		}
+		if !isNodeFailure(err) {
+			return err
+		}
+		p.markFailure(n, err)
+		lastErr = err
+	}
+	return errors.Wrapf(ErrAllNodesFailed, "last error: %s", lastErr.Error())
+}
+
+func isNodeFailure(err error) bool {
+	if reqErr, ok := errors.Cause(err).(*api.ErrRequestError); ok {
+		return reqErr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// ranked returns the nodes ordered by their health: synced nodes first, then unsynced but reachable
+// ones and lastly the unhealthy ones. Nodes within the same group are ordered by latency.
+func (p *Pool) ranked() []*node {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ranked := make([]*node, len(p.nodes))
+	copy(ranked, p.nodes)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i].status, ranked[j].status
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a.LatencyMs < b.LatencyMs
+	})
+	return ranked
+}
+
+func rank(status Status) int {
+	switch {
+	case status.Healthy && status.Synced:
+		return 0
+	case status.Healthy:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (p *Pool) markSuccess(n *node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, other := range p.nodes {
+		other.status.Active = other == n
+	}
+	n.status.ConsecutiveFailures = 0
+}
+
+func (p *Pool) markFailure(n *node, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.status.Healthy = false
+	n.status.Active = false
+	n.status.ConsecutiveFailures++
+	n.status.LastError = err.Error()
+}
+
+type checkResult struct {
+	info    *api.GetNodeInfoResponse
+	latency time.Duration
+	err     error
+}
+
+// Check queries the node info of all nodes concurrently and updates their health state.
+// A node is considered synced if its solid milestone doesn't lag behind its latest milestone and its
+// latest milestone doesn't lag behind the one of the most advanced node by more than the allowed amount.
+func (p *Pool) Check() {
+	p.mu.RLock()
+	nodes := make([]*node, len(p.nodes))
+	copy(nodes, p.nodes)
+	p.mu.RUnlock()
+
+	results := make([]checkResult, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			info := &api.GetNodeInfoResponse{}
+			s := time.Now()
+			err := nodes[i].provider.Send(&api.GetNodeInfoCommand{Command: api.Command{Command: api.GetNodeInfoCmd}}, info)
+			results[i] = checkResult{info: info, latency: time.Since(s), err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	var highestMilestone int64
+	for _, res := range results {
+		if res.err == nil && res.info.LatestMilestoneIndex > highestMilestone {
+			highestMilestone = res.info.LatestMilestoneIndex
+		}
+	}
+
+	t := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, n := range nodes {
+		res := results[i]
+		n.status.LastCheckedOn = &t
+		if res.err != nil {
+			n.status.Healthy = false
+			n.status.Synced = false
+			n.status.Active = false
+			n.status.ConsecutiveFailures++
+			n.status.LastError = res.err.Error()
+			continue
+		}
+		info := res.info
+		n.status.Healthy = true
+		n.status.Synced = info.LatestMilestoneIndex-info.LatestSolidSubtangleMilestoneIndex <= p.maxMilestoneLag &&
+			highestMilestone-info.LatestMilestoneIndex <= p.maxMilestoneLag
+		n.status.AppName = info.AppName
+		n.status.AppVersion = info.AppVersion
+		n.status.LatestMilestoneIndex = info.LatestMilestoneIndex
+		n.status.LatestSolidSubtangleMilestoneIndex = info.LatestSolidSubtangleMilestoneIndex
+		n.status.LatencyMs = int64(res.latency / time.Millisecond)
+		n.status.ConsecutiveFailures = 0
+		n.status.LastError = ""
+	}
+}
+
+// Status returns the health state of all nodes in the order they were defined.
+func (p *Pool) Status() []Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := make([]Status, len(p.nodes))
+	for i, n := range p.nodes {
+		statuses[i] = n.status
+	}
+	return statuses
+}
@@ -0,0 +1,79 @@
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the amount of iotas prices are quoted for (MIOTA)
+const QuoteUnit = 1000000
+
+var ErrRatesMissing = errors.New("the price source didn't return a USD and EUR rate")
+
+// Rates are the fiat prices of one MIOTA.
+type Rates struct {
+	USD       float64   `json:"usd"`
+	EUR       float64   `json:"eur"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// Provider provides the current IOTA fiat rates.
+type Provider interface {
+	Rates() (*Rates, error)
+}
+
+// HTTPProvider fetches the rates from a JSON endpoint. The endpoint must return an object with "usd" and "eur"
+// fields, optionally nested under the given key path (e.g. "iota" for {"iota": {"usd": 0.25, "eur": 0.23}}).
+type HTTPProvider struct {
+	URL     string
+	KeyPath string
+	Client  *http.Client
+}
+
+// NewHTTPProvider creates a new HTTPProvider for the given URL and key path.
+func NewHTTPProvider(url string, keyPath string) *HTTPProvider {
+	return &HTTPProvider{URL: url, KeyPath: keyPath, Client: &http.Client{Timeout: time.Duration(10) * time.Second}}
+}
+
+func (hp *HTTPProvider) Rates() (*Rates, error) {
+	res, err := hp.Client.Get(hp.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price source returned status code %d", res.StatusCode)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "unable to decode price source response")
+	}
+
+	if hp.KeyPath != "" {
+		for _, key := range strings.Split(hp.KeyPath, ".") {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, errors.Wrapf(err, "unable to descend into key '%s' of price source response", key)
+			}
+			var has bool
+			if raw, has = obj[key]; !has {
+				return nil, fmt.Errorf("price source response has no key '%s'", key)
+			}
+		}
+	}
+
+	rates := &Rates{}
+	if err := json.Unmarshal(raw, rates); err != nil {
+		return nil, errors.Wrap(err, "unable to decode rates of price source response")
+	}
+	if rates.USD <= 0 || rates.EUR <= 0 {
+		return nil, ErrRatesMissing
+	}
+	rates.UpdatedOn = time.Now()
+	return rates, nil
+}
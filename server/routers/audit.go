@@ -0,0 +1,71 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type AuditRouter struct {
+	R    *echo.Echo             `inject:""`
+	AC   *controllers.AuditCtrl `inject:""`
+	Auth *controllers.AuthCtrl  `inject:""`
+}
+
+func (ar *AuditRouter) Init() {
+
+	routeGroup := ar.R.Group("/api/audit", requireViewer(ar.Auth))
+
+	// pages through the whole log, the next page starts after the seq of the last returned event.
+	// all events of a bounty are returned if its ID is given.
+	routeGroup.GET("", func(c echo.Context) error {
+		if bountyIDStr := c.QueryParam("bounty_id"); bountyIDStr != "" {
+			bountyID, err := strconv.ParseInt(bountyIDStr, 10, 64)
+			if err != nil || c.QueryParam("after") != "" || c.QueryParam("limit") != "" {
+				return ErrBadRequest
+			}
+
+			events, err := ar.AC.GetOfBounty(bountyID)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, events)
+		}
+
+		afterSeq := int64(-1)
+		if afterStr := c.QueryParam("after"); afterStr != "" {
+			after, err := strconv.ParseInt(afterStr, 10, 64)
+			if err != nil {
+				return ErrBadRequest
+			}
+			afterSeq = after
+		}
+		var limit int64
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			l, err := strconv.ParseInt(limitStr, 10, 64)
+			if err != nil {
+				return ErrBadRequest
+			}
+			limit = l
+		}
+
+		events, err := ar.AC.GetAll(afterSeq, limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, events)
+	})
+
+	routeGroup.GET("/verify", func(c echo.Context) error {
+		verification, err := ar.AC.Verify()
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, verification)
+	})
+}
@@ -0,0 +1,413 @@
+package routers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/labstack/echo"
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sessionCookieName = "ibp_session"
+const oauthStateCookieName = "ibp_oauth_state"
+
+// the key under which the session of the signed in user is stored in the request context
+const sessionContextKey = "session"
+
+// the key under which the API token of the request is stored in the request context
+const tokenContextKey = "api_token"
+
+const bearerAuthScheme = "Bearer "
+
+// the key under which anonymous requests of a public platform are marked in the request context
+const publicContextKey = "public"
+
+type AuthRouter struct {
+	R      *echo.Echo            `inject:""`
+	AC     *controllers.AuthCtrl `inject:""`
+	Dev    bool                  `inject:"dev"`
+	Config *config.Configuration `inject:""`
+}
+
+// AuthStatus tells the client whether it must sign in and who is signed in.
+type AuthStatus struct {
+	OAuthEnabled   bool         `json:"oauth_enabled"`
+	PublicReadOnly bool         `json:"public_read_only"`
+	User           *models.User `json:"user"`
+}
+
+func (ar *AuthRouter) Init() {
+
+	ar.R.GET("/api/me", func(c echo.Context) error {
+		status := AuthStatus{OAuthEnabled: ar.AC.Enabled(), PublicReadOnly: ar.AC.PublicReadOnly()}
+		if !status.OAuthEnabled {
+			return c.JSON(http.StatusOK, status)
+		}
+
+		session, err := loadSession(ar.AC, c)
+		if err != nil {
+			return err
+		}
+		if session != nil {
+			status.User = ar.AC.User(session)
+		}
+		return c.JSON(http.StatusOK, status)
+	})
+
+	if !ar.AC.Enabled() {
+		return
+	}
+
+	routeGroup := ar.R.Group("/auth")
+
+	// the state protects the callback against login CSRF
+	routeGroup.GET("/login", func(c echo.Context) error {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		state := hex.EncodeToString(b)
+		c.SetCookie(ar.cookie(oauthStateCookieName, state, time.Now().Add(10*time.Minute)))
+		return c.Redirect(http.StatusSeeOther, ar.AC.AuthCodeURL(state))
+	})
+
+	routeGroup.GET("/callback", func(c echo.Context) error {
+		stateCookie, err := c.Cookie(oauthStateCookieName)
+		if err != nil || stateCookie.Value == "" || stateCookie.Value != c.QueryParam("state") {
+			return ErrBadRequest
+		}
+		c.SetCookie(ar.cookie(oauthStateCookieName, "", time.Unix(0, 0)))
+
+		token, session, err := ar.AC.SignIn(c.QueryParam("code"))
+		if err != nil {
+			return err
+		}
+
+		c.SetCookie(ar.cookie(sessionCookieName, token, session.ExpiresOn))
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	routeGroup.POST("/logout", func(c echo.Context) error {
+		if cookie, err := c.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+			if err := ar.AC.SignOut(cookie.Value); err != nil {
+				return err
+			}
+		}
+		c.SetCookie(ar.cookie(sessionCookieName, "", time.Unix(0, 0)))
+		return c.JSON(http.StatusOK, SimpleMsg{"ok"})
+	})
+
+}
+
+func (ar *AuthRouter) cookie(name string, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   ar.Config.HTTP.OAuth.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// loadSession returns the session of the signed in user or nil if the request carries no valid session cookie.
+func loadSession(ac *controllers.AuthCtrl, c echo.Context) (*models.Session, error) {
+	if session, ok := c.Get(sessionContextKey).(*models.Session); ok {
+		return session, nil
+	}
+	cookie, err := c.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	session, err := ac.GetSession(cookie.Value)
+	if err != nil {
+		if errors.Cause(err) == controllers.ErrSessionNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c.Set(sessionContextKey, session)
+	return session, nil
+}
+
+func requireSession(ac *controllers.AuthCtrl, c echo.Context) (*models.Session, error) {
+	session, err := loadSession(ac, c)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, echo.ErrUnauthorized
+	}
+	return session, nil
+}
+
+// loadToken returns the API token passed as bearer token or nil if the request carries none.
+func loadToken(ac *controllers.AuthCtrl, c echo.Context) (*models.APIToken, error) {
+	if token, ok := c.Get(tokenContextKey).(*models.APIToken); ok {
+		return token, nil
+	}
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !isBearerAuth(auth) {
+		return nil, nil
+	}
+	token, err := ac.Authenticate(strings.TrimSpace(auth[len(bearerAuthScheme):]))
+	if err != nil {
+		if errors.Cause(err) == controllers.ErrInvalidAPIToken {
+			return nil, echo.ErrUnauthorized
+		}
+		return nil, err
+	}
+	c.Set(tokenContextKey, token)
+	return token, nil
+}
+
+func isBearerAuth(auth string) bool {
+	return len(auth) > len(bearerAuthScheme) && strings.EqualFold(auth[:len(bearerAuthScheme)], bearerAuthScheme)
+}
+
+// requireViewer lets any signed in user pass. API tokens need the read scope for GET requests, the scopes
+// of other requests are checked by the guards of the routes. Without OAuth, only API tokens and,
+// on a public platform, the basic auth credentials are checked.
+func requireViewer(ac *controllers.AuthCtrl) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := loadToken(ac, c)
+			if err != nil {
+				return err
+			}
+			if token != nil {
+				if c.Request().Method == http.MethodGet && !token.HasScope(models.TokenScopeRead) {
+					return ErrForbidden
+				}
+				return next(c)
+			}
+			if !ac.Enabled() {
+				if err := requireBasicAuth(ac, c); err != nil {
+					return err
+				}
+				return next(c)
+			}
+			if _, err := requireSession(ac, c); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// requirePlatformAdmin only lets the configured platform admins pass and API tokens with the given scope,
+// which are either service tokens or personal tokens of platform admins. No API token passes without a scope.
+func requirePlatformAdmin(ac *controllers.AuthCtrl, scope models.TokenScope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := loadToken(ac, c)
+			if err != nil {
+				return err
+			}
+			if token != nil {
+				if scope == "" || !token.HasScope(scope) || (!token.Service && !ac.IsPlatformAdmin(token.OwnerID)) {
+					return ErrForbidden
+				}
+				return next(c)
+			}
+			if !ac.Enabled() {
+				if err := requireBasicAuth(ac, c); err != nil {
+					return err
+				}
+				return next(c)
+			}
+			session, err := requireSession(ac, c)
+			if err != nil {
+				return err
+			}
+			if !ac.IsPlatformAdmin(session.UserID) {
+				return ErrForbidden
+			}
+			return next(c)
+		}
+	}
+}
+
+// requireViewerOrPublic works like requireViewer, but lets anonymous GET requests of the given routes pass
+// if the platform is public. Those requests are marked, so that the handlers respond with the public views.
+func requireViewerOrPublic(ac *controllers.AuthCtrl, publicRoutes ...string) echo.MiddlewareFunc {
+	viewer := requireViewer(ac)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		guarded := viewer(next)
+		return func(c echo.Context) error {
+			if !ac.PublicReadOnly() || c.Request().Method != http.MethodGet || !containsRoute(publicRoutes, c.Path()) {
+				return guarded(c)
+			}
+			anonymous, err := isAnonymous(ac, c)
+			if err != nil {
+				return err
+			}
+			if !anonymous {
+				return guarded(c)
+			}
+			c.Set(publicContextKey, true)
+			return next(c)
+		}
+	}
+}
+
+func containsRoute(routes []string, route string) bool {
+	for _, r := range routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// isAnonymous tells whether the request carries neither credentials nor a valid session.
+func isAnonymous(ac *controllers.AuthCtrl, c echo.Context) (bool, error) {
+	if c.Request().Header.Get(echo.HeaderAuthorization) != "" {
+		return false, nil
+	}
+	if !ac.Enabled() {
+		return true, nil
+	}
+	session, err := loadSession(ac, c)
+	if err != nil {
+		return false, err
+	}
+	return session == nil, nil
+}
+
+// isPublic tells whether the request was let through as anonymous request of a public platform,
+// such requests must only be answered with the public views of bounties and repositories.
+func isPublic(c echo.Context) bool {
+	public, _ := c.Get(publicContextKey).(bool)
+	return public
+}
+
+// requireBasicAuth checks the basic auth credentials of a public platform, as the server then doesn't
+// apply basic auth to all requests. Otherwise it already checked them.
+func requireBasicAuth(ac *controllers.AuthCtrl, c echo.Context) error {
+	conf := ac.Config.HTTP
+	if !conf.PublicReadOnly || !conf.BasicAuth.Enabled {
+		return nil
+	}
+	username, password, ok := c.Request().BasicAuth()
+	if ok && subtle.ConstantTimeCompare([]byte(username), []byte(conf.BasicAuth.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(conf.BasicAuth.Password)) == 1 {
+		return nil
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "basic realm=Restricted")
+	return echo.ErrUnauthorized
+}
+
+// repoResolver determines the owner and name of the repository a request targets.
+type repoResolver func(c echo.Context) (string, string, error)
+
+// requireRepositoryManager only lets platform admins and the GitHub admins of the targeted repository pass
+// and API tokens with the given scope, whereby personal tokens must be owned by one of those.
+func requireRepositoryManager(ac *controllers.AuthCtrl, scope models.TokenScope, repoOf repoResolver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := loadToken(ac, c)
+			if err != nil {
+				return err
+			}
+			if token != nil {
+				if !token.HasScope(scope) {
+					return ErrForbidden
+				}
+				if !token.Service {
+					if err := checkRepositoryManager(ac, token.OwnerID, token.OwnerLogin, repoOf, c); err != nil {
+						return err
+					}
+				}
+				return next(c)
+			}
+			if !ac.Enabled() {
+				if err := requireBasicAuth(ac, c); err != nil {
+					return err
+				}
+				return next(c)
+			}
+			session, err := requireSession(ac, c)
+			if err != nil {
+				return err
+			}
+			if err := checkRepositoryManager(ac, session.UserID, session.Login, repoOf, c); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+func checkRepositoryManager(ac *controllers.AuthCtrl, userID int64, login string, repoOf repoResolver, c echo.Context) error {
+	if ac.IsPlatformAdmin(userID) {
+		return nil
+	}
+	owner, name, err := repoOf(c)
+	if err != nil {
+		return err
+	}
+	manager, err := ac.IsRepositoryManager(userID, login, owner, name)
+	if err != nil {
+		return err
+	}
+	if !manager {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// repoByID resolves the repository from the given path parameter holding its ID.
+func repoByID(rc *controllers.RepoCtrl, param string) repoResolver {
+	return func(c echo.Context) (string, string, error) {
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil {
+			return "", "", ErrBadRequest
+		}
+		repo, err := rc.GetByID(id)
+		if err != nil {
+			return "", "", err
+		}
+		return repo.Owner, repo.Name, nil
+	}
+}
+
+// repoOfBounty resolves the repository of the bounty whose ID is held by the given path parameter.
+func repoOfBounty(bc *controllers.BountyCtrl, param string) repoResolver {
+	return func(c echo.Context) (string, string, error) {
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil {
+			return "", "", ErrBadRequest
+		}
+		bounty, err := bc.GetByID(id)
+		if err != nil {
+			return "", "", err
+		}
+		repo, err := bc.RepoCtrl.GetByID(bounty.RepositoryID)
+		if err != nil {
+			return "", "", err
+		}
+		return repo.Owner, repo.Name, nil
+	}
+}
+
+// apiActor returns the actor of a change made through the API, which is the signed in user if there is one
+// or the API token and its owner.
+func apiActor(c echo.Context) *models.Actor {
+	if token, ok := c.Get(tokenContextKey).(*models.APIToken); ok {
+		return &models.Actor{Source: models.ActorSourceAPI, GitHubID: token.OwnerID, Login: token.OwnerLogin, Token: token.Name}
+	}
+	session, ok := c.Get(sessionContextKey).(*models.Session)
+	if !ok {
+		return controllers.APIActor
+	}
+	return &models.Actor{Source: models.ActorSourceAPI, GitHubID: session.UserID, Login: session.Login}
+}
@@ -1,10 +1,16 @@
 package routers
 
 import (
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/guards"
 	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo"
 )
@@ -12,13 +18,31 @@ import (
 type BountyRouter struct {
 	R         *echo.Echo              `inject:""`
 	BC        *controllers.BountyCtrl `inject:""`
+	PC        *controllers.PriceCtrl  `inject:""`
 	Dev       bool                    `inject:"dev"`
 	Config    *config.Configuration   `inject:""`
+	Auth      *controllers.AuthCtrl   `inject:""`
+	Bot       *controllers.Bot        `inject:""`
 }
 
 func (br *BountyRouter) Init() {
 
-	routeGroup := br.R.Group("/api/bounties")
+	routeGroup := br.R.Group("/api/bounties", requireViewerOrPublic(br.Auth,
+		"/api/bounties", "/api/bounties/:id", "/api/bounties/:owner/:name",
+	))
+
+	// lists the bounties of all repositories, the cursor of the next page is returned in the X-Next-Cursor header
+	routeGroup.GET("", func(c echo.Context) error {
+		query, err := parseBountyQuery(c)
+		if err != nil {
+			return err
+		}
+		if query.Limit == 0 {
+			query.Limit = controllers.DefaultBountyPageSize
+		}
+
+		return br.listBounties(c, query)
+	})
 
 	routeGroup.GET("/:id", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -32,47 +56,284 @@ func (br *BountyRouter) Init() {
 			return err
 		}
 
+		br.PC.AddFiat(bounty)
+		if isPublic(c) {
+			return c.JSON(http.StatusOK, models.NewPublicBounty(bounty))
+		}
 		return c.JSON(http.StatusOK, bounty)
 	})
 
 	routeGroup.GET("/:owner/:name", func(c echo.Context) error {
-		owner := c.Param("owner")
-		name := c.Param("name")
+		query, err := parseBountyQuery(c)
+		if err != nil {
+			return err
+		}
 
-		bounties, err := br.BC.GetOfRepository(owner, name)
+		repo, err := br.BC.RepoCtrl.GetByOwnerAndName(c.Param("owner"), c.Param("name"))
 		if err != nil {
 			return err
 		}
+		query.RepositoryID = repo.ID
 
-		return c.JSON(http.StatusOK, bounties)
+		// without a limit all bounties of the repository are returned
+		return br.listBounties(c, query)
 	})
 
+	// the bounty is described by a JSON body or, for backwards compatibility, by query parameters
 	routeGroup.POST("", func(c echo.Context) error {
-		issueIDStr := c.QueryParam("issue_id")
-		owner := c.QueryParam("owner")
-		name := c.QueryParam("name")
-		issueID, err := strconv.Atoi(issueIDStr)
+		req, err := parseNewBounty(c)
 		if err != nil {
 			return err
 		}
 
-		bounty, err := br.BC.Add(owner, name, issueID)
+		var deadline *time.Time
+		if req.Deadline != "" {
+			t, err := misc.ParseDeadline(req.Deadline)
+			if err != nil {
+				return ErrBadRequest
+			}
+			deadline = &t
+		}
+
+		bounty, err := br.BC.Add(req.Owner, req.Name, req.IssueID, deadline, apiActor(c))
 		if err != nil {
 			return err
 		}
 
+		br.PC.AddFiat(bounty)
+
 		return c.JSON(http.StatusOK, bounty)
-	})
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, func(c echo.Context) (string, string, error) {
+		req, err := parseNewBounty(c)
+		if err != nil {
+			return "", "", err
+		}
+		return req.Owner, req.Name, nil
+	}))
+
+	routeGroup.POST("/:id/cancel", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		refunds, err := br.Bot.CancelBounty(id, apiActor(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, refunds)
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
+
+	routeGroup.POST("/:id/sweep", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		payout, err := br.Bot.SweepLateDeposits(id, models.SweepTarget(c.QueryParam("target")), apiActor(c))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payout)
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
+
+	routeGroup.PUT("/:id/contributions/:bundle/refund_address", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		addr := c.QueryParam("address")
+		if !guards.IsAddressWithChecksum(addr) || address.ValidChecksum(addr[:81], addr[81:]) != nil {
+			return ErrBadRequest
+		}
+
+		bounty, err := br.BC.GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		// registrations through the API override the ones made by contributors
+		if err := br.BC.RegisterRefundAddress(bounty, c.Param("bundle"), addr, apiActor(c), true); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, SimpleMsg{"ok"})
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
+
+	routeGroup.PUT("/:id/deadline", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		deadline, err := misc.ParseDeadline(c.QueryParam("deadline"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		bounty, err := br.BC.GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := br.BC.SetDeadline(bounty, deadline, apiActor(c)); err != nil {
+			return err
+		}
+
+		br.PC.AddFiat(bounty)
+
+		return c.JSON(http.StatusOK, bounty)
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
+
+	routeGroup.PUT("/:id/milestones", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		milestones := []models.Milestone{}
+		if err := c.Bind(&milestones); err != nil {
+			return ErrBadRequest
+		}
+
+		bounty, err := br.BC.GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		if err := br.BC.SetMilestones(bounty, milestones, apiActor(c)); err != nil {
+			return err
+		}
+
+		br.PC.AddFiat(bounty)
+
+		return c.JSON(http.StatusOK, bounty)
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
 
 	routeGroup.DELETE("/:id", func(c echo.Context) error {
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			return err
 		}
-		if err := br.BC.Delete(int64(id)); err != nil {
+		if err := br.Bot.DeleteBounty(int64(id), apiActor(c)); err != nil {
 			return err
 		}
 		return c.JSON(http.StatusOK, SimpleMsg{"ok"})
-	})
+	}, requireRepositoryManager(br.Auth, models.TokenScopeBountiesWrite, repoOfBounty(br.BC, "id")))
+
+}
+
+func (br *BountyRouter) listBounties(c echo.Context, query *controllers.BountyQuery) error {
+	bounties, next, err := br.BC.QueryBounties(query)
+	if err != nil {
+		return err
+	}
+
+	for i := range bounties {
+		br.PC.AddFiat(&bounties[i])
+	}
+
+	if next != "" {
+		c.Response().Header().Set(nextCursorHeader, next)
+	}
+	if isPublic(c) {
+		public := make([]models.PublicBounty, len(bounties))
+		for i := range bounties {
+			public[i] = models.NewPublicBounty(&bounties[i])
+		}
+		return c.JSON(http.StatusOK, public)
+	}
+	return c.JSON(http.StatusOK, bounties)
+}
+
+const nextCursorHeader = "X-Next-Cursor"
+
+// the key under which the parsed bounty creation request is stored in the request context, as the body can only be read once
+const newBountyContextKey = "new_bounty"
+
+func parseNewBounty(c echo.Context) (*models.NewBounty, error) {
+	if req, ok := c.Get(newBountyContextKey).(*models.NewBounty); ok {
+		return req, nil
+	}
+	req := &models.NewBounty{}
+	if issueIDStr := c.QueryParam("issue_id"); issueIDStr != "" {
+		issueID, err := strconv.Atoi(issueIDStr)
+		if err != nil {
+			return nil, ErrBadRequest
+		}
+		req = &models.NewBounty{
+			Owner: c.QueryParam("owner"), Name: c.QueryParam("name"),
+			IssueID: issueID, Deadline: c.QueryParam("deadline"),
+		}
+	} else if err := c.Bind(req); err != nil {
+		return nil, ErrBadRequest
+	}
+	if req.Owner == "" || req.Name == "" || req.IssueID <= 0 {
+		return nil, ErrBadRequest
+	}
+	c.Set(newBountyContextKey, req)
+	return req, nil
+}
+
+// parseBountyQuery reads the filters, sort order and page of a bounty listing from the query parameters.
+func parseBountyQuery(c echo.Context) (*controllers.BountyQuery, error) {
+	query := &controllers.BountyQuery{Sort: c.QueryParam("sort"), Cursor: c.QueryParam("cursor")}
+
+	if statesStr := c.QueryParam("state"); statesStr != "" {
+		for _, stateStr := range strings.Split(statesStr, ",") {
+			state, err := strconv.Atoi(strings.TrimSpace(stateStr))
+			if err != nil {
+				return nil, ErrBadRequest
+			}
+			query.States = append(query.States, models.BountyState(state))
+		}
+	}
+
+	if repoStr := c.QueryParam("repository_id"); repoStr != "" {
+		repoID, err := strconv.ParseInt(repoStr, 10, 64)
+		if err != nil {
+			return nil, ErrBadRequest
+		}
+		query.RepositoryID = repoID
+	}
+
+	for param, target := range map[string]**uint64{"min_balance": &query.MinBalance, "max_balance": &query.MaxBalance} {
+		if balanceStr := c.QueryParam(param); balanceStr != "" {
+			balance, err := strconv.ParseUint(balanceStr, 10, 63)
+			if err != nil {
+				return nil, ErrBadRequest
+			}
+			*target = &balance
+		}
+	}
+
+	for param, target := range map[string]**time.Time{
+		"created_after": &query.CreatedAfter, "created_before": &query.CreatedBefore,
+		"updated_after": &query.UpdatedAfter, "updated_before": &query.UpdatedBefore,
+	} {
+		if timeStr := c.QueryParam(param); timeStr != "" {
+			t, err := parseQueryTime(timeStr)
+			if err != nil {
+				return nil, ErrBadRequest
+			}
+			*target = &t
+		}
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 1 {
+			return nil, ErrBadRequest
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
 
+// parseQueryTime accepts RFC 3339 timestamps and dates (YYYY-MM-DD, midnight UTC).
+func parseQueryTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
 }
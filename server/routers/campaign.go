@@ -0,0 +1,102 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+type CampaignRouter struct {
+	R    *echo.Echo              `inject:""`
+	BC   *controllers.BountyCtrl `inject:""`
+	Bot  *controllers.Bot        `inject:""`
+	Auth *controllers.AuthCtrl   `inject:""`
+}
+
+func (cr *CampaignRouter) Init() {
+
+	routeGroup := cr.R.Group("/api/campaigns", requireViewer(cr.Auth))
+
+	routeGroup.GET("", func(c echo.Context) error {
+		campaigns, err := cr.BC.GetCampaigns()
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, campaigns)
+	})
+
+	routeGroup.POST("", func(c echo.Context) error {
+		campaign := &models.Campaign{}
+		if err := c.Bind(campaign); err != nil {
+			return ErrBadRequest
+		}
+
+		campaign, err := cr.BC.AddCampaign(campaign)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, campaign)
+	}, requirePlatformAdmin(cr.Auth, ""))
+
+	routeGroup.GET("/:id", func(c echo.Context) error {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		campaign, err := cr.BC.GetCampaignByID(id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, campaign)
+	})
+
+	routeGroup.GET("/:id/transfers", func(c echo.Context) error {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		transfers, err := cr.BC.GetCampaignTransfers(id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, transfers)
+	})
+
+	routeGroup.POST("/:id/end", func(c echo.Context) error {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		campaign, err := cr.BC.EndCampaign(id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, campaign)
+	}, requirePlatformAdmin(cr.Auth, ""))
+
+	// sends the leftover funds of an ended campaign back to the sponsor
+	routeGroup.POST("/:id/withdraw", func(c echo.Context) error {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		transfer, err := cr.Bot.WithdrawCampaign(id)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, transfer)
+	}, requirePlatformAdmin(cr.Auth, ""))
+}
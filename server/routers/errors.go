@@ -0,0 +1,178 @@
+package routers
+
+import (
+	"fmt"
+	"github.com/google/go-github/github"
+	"github.com/labstack/echo"
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/nodes"
+	"github.com/luca-moser/iota-bounty-platform/server/price"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIError is the body of failed responses of /api routes.
+type APIError struct {
+	Status int `json:"status"`
+	// stable and machine-readable, see errorKinds
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorKind struct {
+	status int
+	code   string
+}
+
+// the status and code of each error a handler might return, the codes must never change
+var errorKinds = map[error]errorKind{
+	ErrBadRequest:     {http.StatusBadRequest, "bad_request"},
+	ErrForbidden:      {http.StatusForbidden, "forbidden"},
+	ErrInternalServer: {http.StatusInternalServerError, "internal_error"},
+
+	controllers.ErrInternalError:        {http.StatusInternalServerError, "internal_error"},
+	controllers.ErrInvalidID:            {http.StatusBadRequest, "invalid_id"},
+	controllers.ErrInvalidModel:         {http.StatusBadRequest, "invalid_model"},
+	controllers.ErrInvalidModelUpdate:   {http.StatusBadRequest, "invalid_model_update"},
+	controllers.ErrInvalidModelDeletion: {http.StatusBadRequest, "invalid_model_deletion"},
+	controllers.ErrInvalidQuery:         {http.StatusBadRequest, "invalid_query"},
+
+	controllers.ErrAlreadyConfirmed:        {http.StatusBadRequest, "already_confirmed"},
+	controllers.ErrInvalidConfirmationCode: {http.StatusBadRequest, "invalid_confirmation_code"},
+	controllers.ErrInvalidOAuthCode:        {http.StatusBadRequest, "invalid_oauth_code"},
+	controllers.ErrSessionNotFound:         {http.StatusUnauthorized, "session_not_found"},
+	controllers.ErrInvalidAPIToken:         {http.StatusUnauthorized, "invalid_api_token"},
+	controllers.ErrInvalidTokenRequest:     {http.StatusBadRequest, "invalid_token_request"},
+
+	controllers.ErrIssuesDeactivated:       {http.StatusBadRequest, "issues_deactivated"},
+	controllers.ErrIssueIsClosed:           {http.StatusBadRequest, "issue_closed"},
+	controllers.ErrIssueDoesntExist:        {http.StatusNotFound, "issue_not_found"},
+	controllers.ErrRepositoryNotInPlatform: {http.StatusNotFound, "repository_not_in_platform"},
+	misc.ErrRepoURLInvalid:                 {http.StatusBadRequest, "invalid_repository_url"},
+	misc.ErrDeadlineInvalid:                {http.StatusBadRequest, "invalid_deadline"},
+
+	controllers.ErrInvalidFeeSettings:           {http.StatusBadRequest, "invalid_fee_settings"},
+	controllers.ErrInvalidFundingNoticeSettings: {http.StatusBadRequest, "invalid_funding_notice_settings"},
+
+	controllers.ErrBountyAlreadySettled:           {http.StatusBadRequest, "bounty_already_settled"},
+	controllers.ErrBountyAddrEmpty:                {http.StatusBadRequest, "bounty_address_empty"},
+	controllers.ErrContributionNotFound:           {http.StatusNotFound, "contribution_not_found"},
+	controllers.ErrRefundAddressAlreadyRegistered: {http.StatusBadRequest, "refund_address_already_registered"},
+	controllers.ErrRefundTreasuryNotConfigured:    {http.StatusInternalServerError, "treasury_not_configured"},
+	controllers.ErrDeadlineInPast:                 {http.StatusBadRequest, "deadline_in_past"},
+	controllers.ErrBountyExpired:                  {http.StatusBadRequest, "bounty_expired"},
+
+	controllers.ErrPayoutAlreadySent:         {http.StatusBadRequest, "payout_already_sent"},
+	controllers.ErrPayoutPending:             {http.StatusBadRequest, "payout_pending"},
+	controllers.ErrPayoutAwaitingApproval:    {http.StatusBadRequest, "payout_awaiting_approval"},
+	controllers.ErrPayoutNotAwaitingApproval: {http.StatusBadRequest, "payout_not_awaiting_approval"},
+	controllers.ErrApprovalExpired:           {http.StatusBadRequest, "approval_expired"},
+	controllers.ErrApproverNotAllowed:        {http.StatusForbidden, "approver_not_allowed"},
+	controllers.ErrBountyBalanceChanged:      {http.StatusBadRequest, "bounty_balance_changed"},
+	controllers.ErrPayoutReceiverChanged:     {http.StatusBadRequest, "payout_receiver_changed"},
+
+	controllers.ErrBountyNotSettled:            {http.StatusBadRequest, "bounty_not_settled"},
+	controllers.ErrNoLateDeposits:              {http.StatusBadRequest, "no_late_deposits"},
+	controllers.ErrInvalidSweepTarget:          {http.StatusBadRequest, "invalid_sweep_target"},
+	controllers.ErrNoSweepReceiver:             {http.StatusBadRequest, "no_sweep_receiver"},
+	controllers.ErrPoolAddressKeyIndexNotFound: {http.StatusInternalServerError, "pool_address_key_index_not_found"},
+
+	controllers.ErrInvalidMilestones:       {http.StatusBadRequest, "invalid_milestones"},
+	controllers.ErrMilestonesLocked:        {http.StatusBadRequest, "milestones_locked"},
+	controllers.ErrMilestoneNotFound:       {http.StatusNotFound, "milestone_not_found"},
+	controllers.ErrMilestoneAlreadyPaid:    {http.StatusBadRequest, "milestone_already_paid"},
+	controllers.ErrMilestoneNotReleased:    {http.StatusBadRequest, "milestone_not_released"},
+	controllers.ErrMilestoneBountyNotOpen:  {http.StatusBadRequest, "milestone_bounty_not_open"},
+	controllers.ErrMilestoneExceedsBalance: {http.StatusBadRequest, "milestone_exceeds_balance"},
+
+	controllers.ErrInvalidCampaign:         {http.StatusBadRequest, "invalid_campaign"},
+	controllers.ErrCampaignEnded:           {http.StatusBadRequest, "campaign_ended"},
+	controllers.ErrCampaignNotEnded:        {http.StatusBadRequest, "campaign_not_ended"},
+	controllers.ErrNoCampaignRefundAddress: {http.StatusBadRequest, "no_campaign_refund_address"},
+	controllers.ErrNoCampaignFunds:         {http.StatusBadRequest, "no_campaign_funds"},
+
+	controllers.ErrSeedMasterMismatch: {http.StatusInternalServerError, "master_seed_mismatch"},
+	vault.ErrUnknownKey:               {http.StatusInternalServerError, "unknown_master_key"},
+	price.ErrRatesMissing:             {http.StatusServiceUnavailable, "rates_missing"},
+	nodes.ErrNoNodes:                  {http.StatusServiceUnavailable, "no_nodes"},
+	nodes.ErrAllNodesFailed:           {http.StatusServiceUnavailable, "nodes_unavailable"},
+
+	mongo.ErrNoDocuments: {http.StatusNotFound, "not_found"},
+}
+
+// the codes of errors raised by echo itself, e.g. for unknown routes
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// apiErrorOf describes the given error for the client. Messages of client errors contain the details the
+// controllers added to the error, server errors only expose the message of the underlying error.
+func apiErrorOf(err error) *APIError {
+	cause := errors.Cause(err)
+
+	if kind, ok := errorKinds[cause]; ok {
+		apiErr := &APIError{Status: kind.status, Code: kind.code, Message: err.Error()}
+		switch {
+		case cause == mongo.ErrNoDocuments:
+			apiErr.Message = "not found"
+		case kind.status >= http.StatusInternalServerError:
+			apiErr.Message = cause.Error()
+		}
+		return apiErr
+	}
+
+	switch e := cause.(type) {
+	case *echo.HTTPError:
+		code, ok := statusCodes[e.Code]
+		if !ok {
+			code = strings.ToLower(strings.Replace(http.StatusText(e.Code), " ", "_", -1))
+		}
+		message := http.StatusText(e.Code)
+		if msg, ok := e.Message.(string); ok && e.Code < http.StatusInternalServerError {
+			message = msg
+		}
+		return &APIError{Status: e.Code, Code: code, Message: message}
+	case mongo.WriteException:
+		if isDuplicateKey(e) {
+			return &APIError{Status: http.StatusConflict, Code: "already_exists", Message: "already exists"}
+		}
+	case *strconv.NumError:
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: fmt.Sprintf("'%s' isn't a valid number", e.Num)}
+	case *github.ErrorResponse:
+		if e.Response != nil && e.Response.StatusCode == http.StatusNotFound {
+			return &APIError{Status: http.StatusNotFound, Code: "github_not_found", Message: "not found on GitHub"}
+		}
+		return &APIError{Status: http.StatusBadGateway, Code: "github_error", Message: "the GitHub API call failed"}
+	}
+
+	return &APIError{Status: http.StatusInternalServerError, Code: "internal_error", Message: "internal server error"}
+}
+
+// the code of write errors which violate a unique index
+const duplicateKeyErrorCode = 11000
+
+func isDuplicateKey(e mongo.WriteException) bool {
+	for _, writeErr := range e.WriteErrors {
+		if writeErr.Code == duplicateKeyErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
@@ -0,0 +1,108 @@
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// interval in which comments are sent to keep idle streams open through proxies
+const liveEventKeepAliveInterval = 30 * time.Second
+
+type EventRouter struct {
+	R    *echo.Echo             `inject:""`
+	EC   *controllers.EventCtrl `inject:""`
+	RC   *controllers.RepoCtrl  `inject:""`
+	Auth *controllers.AuthCtrl  `inject:""`
+}
+
+func (er *EventRouter) Init() {
+
+	// the events only carry IDs, states and balances, which are public on a public platform
+	routeGroup := er.R.Group("/api/events", requireViewerOrPublic(er.Auth, "/api/events"))
+
+	// streams the changes of bounties and repositories as server-sent events, optionally only of one repository.
+	// interrupted streams resume after the event given in the Last-Event-ID header or the last_event_id query param.
+	routeGroup.GET("", func(c echo.Context) error {
+		var repoID int64
+		if repoIDStr := c.QueryParam("repository_id"); repoIDStr != "" {
+			id, err := strconv.ParseInt(repoIDStr, 10, 64)
+			if err != nil {
+				return ErrBadRequest
+			}
+			if _, err := er.RC.GetByID(id); err != nil {
+				return err
+			}
+			repoID = id
+		}
+
+		// browsers can't set headers on the first connection of an EventSource
+		lastEventIDStr := c.Request().Header.Get("Last-Event-ID")
+		if lastEventIDStr == "" {
+			lastEventIDStr = c.QueryParam("last_event_id")
+		}
+		var lastEventID uint64
+		if lastEventIDStr != "" {
+			id, err := strconv.ParseUint(lastEventIDStr, 10, 64)
+			if err != nil {
+				return ErrBadRequest
+			}
+			lastEventID = id
+		}
+
+		sub, missed := er.EC.Subscribe(repoID, lastEventID)
+		defer er.EC.Unsubscribe(sub)
+
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("Connection", "keep-alive")
+		// disables response buffering of nginx
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+
+		for i := range missed {
+			if err := writeLiveEvent(res, &missed[i]); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+
+		keepAlive := time.NewTicker(liveEventKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case event, ok := <-sub.C:
+				// the subscriber fell behind, the client reconnects with the last received ID
+				if !ok {
+					return nil
+				}
+				if err := writeLiveEvent(res, &event); err != nil {
+					return nil
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+			}
+			res.Flush()
+		}
+	})
+}
+
+func writeLiveEvent(res *echo.Response, event *models.LiveEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Kind, data)
+	return err
+}
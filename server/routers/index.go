@@ -1,11 +1,8 @@
 package routers
 
 import (
-	"fmt"
 	"github.com/labstack/echo"
-	"github.com/luca-moser/iota-bounty-platform/server/controllers"
 	"github.com/pkg/errors"
-	"go.mongodb.org/mongo-driver/mongo"
 	"io/ioutil"
 	"net/http"
 )
@@ -36,73 +33,40 @@ func (indexRouter *IndexRouter) Init() {
 	indexRouter.R.GET("/", indexRouter.indexRoute)
 	indexRouter.R.GET("*", indexRouter.indexRoute)
 
+	// /api routes respond with an APIError, other routes redirect unknown paths to the SPA
 	indexRouter.R.HTTPErrorHandler = func(err error, c echo.Context) {
-		c.Logger().Error(err)
+		apiErr := apiErrorOf(err)
+		if apiErr.Status >= http.StatusInternalServerError {
+			c.Logger().Errorf("%s %s: %+v", c.Request().Method, c.Request().URL.Path, err)
+		}
 
-		var statusCode int
-		var message string
+		if c.Response().Committed {
+			return
+		}
 
-		switch errors.Cause(err) {
+		if isAPIPath(c.Request().URL.Path) {
+			if err := c.JSON(apiErr.Status, apiErr); err != nil {
+				c.Logger().Error(err)
+			}
+			return
+		}
 
 		// executed when the route was not found
 		// also used to auto. reroute to the SPA page
-		case echo.ErrNotFound:
+		if errors.Cause(err) == echo.ErrNotFound {
 			c.Redirect(http.StatusSeeOther, "/")
 			return
-
-			// 401 unauthorized
-		case echo.ErrUnauthorized:
-			statusCode = http.StatusUnauthorized
-			message = "unauthorized"
-
-			// 403 forbidden
-		case ErrForbidden:
-			statusCode = http.StatusForbidden
-			message = "access forbidden"
-
-		// 500 internal server error
-		case controllers.ErrInternalError:
-			fallthrough
-		case ErrInternalServer:
-			statusCode = http.StatusInternalServerError
-			message = "internal server error"
-
-		// 404 not found
-		case mongo.ErrNoDocuments:
-			statusCode = http.StatusNotFound
-			message = "not found"
-
-		// 400 bad request
-		case controllers.ErrInvalidID:
-			fallthrough
-		case controllers.ErrInvalidQuery:
-			fallthrough
-		case controllers.ErrInvalidModel:
-			fallthrough
-		case controllers.ErrInvalidModelUpdate:
-			fallthrough
-		case controllers.ErrInvalidModelDeletion:
-			fallthrough
-		case controllers.ErrAlreadyConfirmed:
-			fallthrough
-		case controllers.ErrInvalidConfirmationCode:
-			fallthrough
-		case ErrBadRequest:
-			statusCode = http.StatusBadRequest
-			message = "bad request"
-
-			// 500 internal server error
-		default:
-			statusCode = http.StatusInternalServerError
-			message = "internal server error"
 		}
 
-		message = fmt.Sprintf("%s, error: %+v", message, err)
-		c.String(statusCode, message)
+		c.String(apiErr.Status, apiErr.Message)
 	}
 }
 
 func (indexRouter *IndexRouter) indexRoute(c echo.Context) error {
+	// unknown API routes must not serve the SPA
+	if isAPIPath(c.Request().URL.Path) {
+		return echo.ErrNotFound
+	}
 	if indexRouter.Dev {
 		htmlData, err := ioutil.ReadFile("../../client/html/index.html")
 		if err != nil {
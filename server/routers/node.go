@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+type NodeRouter struct {
+	R    *echo.Echo            `inject:""`
+	NC   *controllers.NodeCtrl `inject:""`
+	Auth *controllers.AuthCtrl `inject:""`
+}
+
+func (nr *NodeRouter) Init() {
+
+	routeGroup := nr.R.Group("/api/nodes", requireViewer(nr.Auth))
+
+	routeGroup.GET("", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nr.NC.Status())
+	})
+}
@@ -0,0 +1,320 @@
+package routers
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/nodes"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OpenAPIRouter serves the OpenAPI 3 description of the REST API, which is built from the operations
+// defined in apiOperations and the models they take and return.
+type OpenAPIRouter struct {
+	R *echo.Echo `inject:""`
+}
+
+type jsonObject = map[string]interface{}
+
+// the spec is built once, a failure is returned by the route instead of keeping the server from starting
+var openAPISpec, openAPISpecErr = json.MarshalIndent(buildOpenAPISpec(apiOperations), "", "  ")
+
+func (or *OpenAPIRouter) Init() {
+	or.R.GET("/api/openapi.json", func(c echo.Context) error {
+		if openAPISpecErr != nil {
+			return errors.Wrap(openAPISpecErr, "(openapi) couldn't build the spec")
+		}
+		return c.JSONBlob(http.StatusOK, openAPISpec)
+	})
+}
+
+// apiOperation describes a single route of the REST API.
+type apiOperation struct {
+	ID      string
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// the role or API token scope required, added to the description
+	Access string
+	Params []apiParam
+	// an instance of the JSON request body
+	Body interface{}
+	// an instance of the JSON response, nil if the route redirects
+	Response interface{}
+	// the response carries the cursor of the next page in the X-Next-Cursor header
+	Paged bool
+	// the response is a stream of server-sent events, each carrying a Response as data
+	EventStream bool
+	// the route can be called without credentials
+	Public bool
+}
+
+type apiParam struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      jsonObject
+}
+
+func pathParam(name string, schema jsonObject, description string) apiParam {
+	return apiParam{Name: name, In: "path", Description: description, Required: true, Schema: schema}
+}
+
+func queryParam(name string, schema jsonObject, description string) apiParam {
+	return apiParam{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+func requiredQueryParam(name string, schema jsonObject, description string) apiParam {
+	return apiParam{Name: name, In: "query", Description: description, Required: true, Schema: schema}
+}
+
+var echoPathParam = regexp.MustCompile(`:(\w+)`)
+
+func buildOpenAPISpec(operations []apiOperation) jsonObject {
+	schemas := &schemaRegistry{schemas: jsonObject{}}
+	paths := jsonObject{}
+	for _, op := range operations {
+		path := echoPathParam.ReplaceAllString(op.Path, "{$1}")
+		item, ok := paths[path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = schemas.operation(&op)
+	}
+
+	return jsonObject{
+		"openapi": "3.0.2",
+		"info": jsonObject{
+			"title":       "IOTA Bounty Platform API",
+			"version":     models.APIVersion,
+			"description": "Manages repositories, bounties and their payouts. Amounts are in iotas.",
+		},
+		"paths": paths,
+		"components": jsonObject{
+			"schemas": schemas.schemas,
+			"responses": jsonObject{
+				"Error": jsonObject{
+					"description": "The request failed, the code identifies the error",
+					"content":     jsonObject{echo.MIMEApplicationJSON: jsonObject{"schema": schemas.schemaOf(reflect.TypeOf(APIError{}))}},
+				},
+			},
+			"securitySchemes": jsonObject{
+				"bearerAuth":    jsonObject{"type": "http", "scheme": "bearer", "description": "An API token"},
+				"basicAuth":     jsonObject{"type": "http", "scheme": "basic"},
+				"sessionCookie": jsonObject{"type": "apiKey", "in": "cookie", "name": sessionCookieName},
+			},
+		},
+		"security": []jsonObject{{"bearerAuth": []string{}}, {"basicAuth": []string{}}, {"sessionCookie": []string{}}},
+	}
+}
+
+func (r *schemaRegistry) operation(op *apiOperation) jsonObject {
+	res := jsonObject{
+		"operationId": op.ID,
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+	}
+	if op.Access != "" {
+		res["description"] = "Requires " + op.Access + "."
+	}
+	if op.Public {
+		res["security"] = []jsonObject{}
+	}
+
+	if len(op.Params) > 0 {
+		params := []jsonObject{}
+		for _, p := range op.Params {
+			param := jsonObject{"name": p.Name, "in": p.In, "required": p.Required, "schema": p.Schema}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		res["parameters"] = params
+	}
+
+	if op.Body != nil {
+		res["requestBody"] = jsonObject{
+			"required": true,
+			"content":  jsonObject{echo.MIMEApplicationJSON: jsonObject{"schema": r.schemaOf(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	responses := jsonObject{"default": jsonObject{"$ref": "#/components/responses/Error"}}
+	if op.Response == nil {
+		responses["303"] = jsonObject{"description": "Redirect"}
+	} else {
+		mime := echo.MIMEApplicationJSON
+		if op.EventStream {
+			mime = "text/event-stream"
+		}
+		ok := jsonObject{
+			"description": "OK",
+			"content":     jsonObject{mime: jsonObject{"schema": r.schemaOf(reflect.TypeOf(op.Response))}},
+		}
+		if op.Paged {
+			ok["headers"] = jsonObject{nextCursorHeader: jsonObject{
+				"description": "The cursor of the next page, missing on the last page",
+				"schema":      jsonObject{"type": "string"},
+			}}
+		}
+		responses["200"] = ok
+	}
+	res["responses"] = responses
+	return res
+}
+
+// schemaRegistry derives JSON schemas from the JSON encoding of Go types.
+// Named structs are added as components and referenced.
+type schemaRegistry struct {
+	schemas jsonObject
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+
+// the names of components whose type name alone is ambiguous
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(nodes.Status{}): "NodeStatus",
+}
+
+// the values of the enumerations of the models
+var enumSchemas = map[reflect.Type]jsonObject{
+	reflect.TypeOf(models.BountyState(0)): {
+		"type": "integer", "enum": []int{0, 1, 2, 3, 4, 5, 6},
+		"description": "0 open, 1 released, 2 transferred, 3 refunding, 4 refunded, 5 expired, 6 confirmed",
+	},
+	reflect.TypeOf(models.PayoutState(0)): {
+		"type": "integer", "enum": []int{0, 1, 2, 3, 4, 5, 6},
+		"description": "0 pending, 1 sent, 2 failed, 3 confirmed, 4 awaiting approval, 5 expired, 6 rejected",
+	},
+	reflect.TypeOf(models.PayoutKind(0)): {
+		"type": "integer", "enum": []int{0, 1, 2, 3},
+		"description": "0 transfer, 1 refund, 2 sweep, 3 milestone",
+	},
+	reflect.TypeOf(models.MilestoneState(0)): {
+		"type": "integer", "enum": []int{0, 1, 2},
+		"description": "0 open, 1 released, 2 paid",
+	},
+	reflect.TypeOf(models.MilestoneSource("")): {
+		"type": "string", "enum": []models.MilestoneSource{models.MilestoneSourceAPI, models.MilestoneSourceIssue},
+	},
+	reflect.TypeOf(models.SweepTarget("")): {
+		"type": "string", "enum": []models.SweepTarget{models.SweepTargetReceiver, models.SweepTargetTreasury},
+	},
+	reflect.TypeOf(models.CampaignTransferKind("")): {
+		"type": "string", "enum": []models.CampaignTransferKind{models.CampaignTransferKindMatch, models.CampaignTransferKindWithdrawal},
+	},
+	reflect.TypeOf(models.ActorSource("")): {
+		"type": "string", "enum": []models.ActorSource{models.ActorSourceGitHub, models.ActorSourceAPI, models.ActorSourcePlatform},
+	},
+	reflect.TypeOf(models.DiscrepancyKind("")): {
+		"type": "string", "enum": []models.DiscrepancyKind{
+			models.DiscrepancyBalanceMismatch, models.DiscrepancyPoolDrained,
+			models.DiscrepancyBundleUnconfirmed, models.DiscrepancyBundleNotFound,
+		},
+	},
+	reflect.TypeOf(models.Role("")): {
+		"type": "string", "enum": []models.Role{models.RolePlatformAdmin, models.RoleRepositoryManager, models.RoleViewer},
+	},
+	reflect.TypeOf(models.TokenScope("")): {
+		"type": "string", "enum": models.TokenScopes,
+	},
+	reflect.TypeOf(models.LiveEventKind("")): {
+		"type": "string", "enum": []models.LiveEventKind{
+			models.LiveEventBountyCreated, models.LiveEventBountyBalanceChanged, models.LiveEventBountyReleased,
+			models.LiveEventBountyTransferred, models.LiveEventBountyRefunded, models.LiveEventBountyDeleted,
+			models.LiveEventRepositoryCreated, models.LiveEventRepositoryUpdated, models.LiveEventRepositoryDeleted,
+			models.LiveEventReset,
+		},
+	},
+}
+
+func (r *schemaRegistry) schemaOf(t reflect.Type) jsonObject {
+	if enum, ok := enumSchemas[t]; ok {
+		return enum
+	}
+	switch t {
+	case timeType:
+		return jsonObject{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return jsonObject{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.schemaOf(t.Elem())
+	case reflect.Bool:
+		return jsonObject{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return jsonObject{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return jsonObject{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonObject{"type": "integer", "format": "int64", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return jsonObject{"type": "number"}
+	case reflect.String:
+		return jsonObject{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonObject{"type": "string", "format": "byte"}
+		}
+		return jsonObject{"type": "array", "items": r.schemaOf(t.Elem())}
+	case reflect.Map:
+		return jsonObject{"type": "object", "additionalProperties": r.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		name, ok := schemaNames[t]
+		if !ok {
+			name = t.Name()
+		}
+		if _, ok := r.schemas[name]; !ok {
+			// registered before the fields are resolved to end recursions
+			r.schemas[name] = jsonObject{}
+			r.schemas[name] = r.structSchema(t)
+		}
+		return jsonObject{"$ref": "#/components/schemas/" + name}
+	}
+	return jsonObject{}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) jsonObject {
+	props := jsonObject{}
+	r.addFields(t, props)
+	return jsonObject{"type": "object", "properties": props}
+}
+
+// addFields adds the JSON encoded fields of the given struct, the fields of embedded structs are promoted.
+func (r *schemaRegistry) addFields(t reflect.Type, props jsonObject) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			r.addFields(field.Type, props)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = r.schemaOf(field.Type)
+	}
+}
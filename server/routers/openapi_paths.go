@@ -0,0 +1,288 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/nodes"
+	"reflect"
+)
+
+var int64Schema = jsonObject{"type": "integer", "format": "int64"}
+var stringSchema = jsonObject{"type": "string"}
+var objectIDSchema = jsonObject{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+var dateSchema = jsonObject{"type": "string", "description": "RFC 3339 timestamp or YYYY-MM-DD"}
+
+const (
+	accessViewer        = "a signed in user or an API token with the read scope"
+	accessPlatformAdmin = "a platform admin, API tokens can't be used"
+	// see requireViewerOrPublic
+	accessViewerOrPublic = accessViewer + ", anonymous visitors of a public platform get the public view"
+)
+
+func accessRepoManager(scope models.TokenScope) string {
+	return "a manager of the repository or an API token with the " + string(scope) + " scope"
+}
+
+func accessPlatformAdminOrScope(scope models.TokenScope) string {
+	return "a platform admin or an API token with the " + string(scope) + " scope"
+}
+
+var bountyIDParam = pathParam("id", int64Schema, "the ID of the bounty")
+var repoIDParam = pathParam("id", int64Schema, "the ID of the repository")
+var ownerParam = pathParam("owner", stringSchema, "the owner of the repository on GitHub")
+var nameParam = pathParam("name", stringSchema, "the name of the repository on GitHub")
+
+// the filters of bounty listings and searches, see parseBountyQuery
+var bountyQueryParams = []apiParam{
+	queryParam("state", stringSchema, "comma separated bounty states"),
+	queryParam("repository_id", int64Schema, ""),
+	queryParam("min_balance", int64Schema, ""),
+	queryParam("max_balance", int64Schema, ""),
+	queryParam("created_after", dateSchema, ""),
+	queryParam("created_before", dateSchema, ""),
+	queryParam("updated_after", dateSchema, ""),
+	queryParam("updated_before", dateSchema, ""),
+	queryParam("sort", jsonObject{"type": "string", "enum": []string{
+		"created_on", "-created_on", "updated_on", "-updated_on", "balance", "-balance",
+	}}, "defaults to -created_on"),
+	queryParam("cursor", stringSchema, "the cursor of the next page returned in the X-Next-Cursor header"),
+	queryParam("limit", int64Schema, ""),
+}
+
+func withBountyQueryParams(params ...apiParam) []apiParam {
+	return append(params, bountyQueryParams...)
+}
+
+// apiOperations are all routes of the REST API, keep them in sync with the routers.
+var apiOperations = []apiOperation{
+	// auth
+	{
+		ID: "signIn", Method: "GET", Path: "/auth/login", Tag: "auth", Public: true,
+		Summary: "Redirects to GitHub to sign in",
+	},
+	{
+		ID: "signInCallback", Method: "GET", Path: "/auth/callback", Tag: "auth", Public: true,
+		Summary: "Opens a session after signing in on GitHub",
+		Params: []apiParam{
+			requiredQueryParam("code", stringSchema, ""),
+			requiredQueryParam("state", stringSchema, ""),
+		},
+	},
+	{
+		ID: "signOut", Method: "POST", Path: "/auth/logout", Tag: "auth", Public: true,
+		Summary: "Ends the session", Response: SimpleMsg{},
+	},
+	{
+		ID: "getMe", Method: "GET", Path: "/api/me", Tag: "auth", Public: true,
+		Summary: "Returns whether OAuth is enabled and the signed in user", Response: AuthStatus{},
+	},
+	{
+		ID: "getOpenAPISpec", Method: "GET", Path: "/api/openapi.json", Tag: "meta", Public: true,
+		Summary: "Returns this description of the API", Response: jsonObject{},
+	},
+
+	// API tokens
+	{
+		ID: "listTokens", Method: "GET", Path: "/api/tokens", Tag: "tokens", Access: "a signed in user",
+		Summary: "Lists the API tokens of the user, all tokens for platform admins", Response: []models.APIToken{},
+	},
+	{
+		ID: "createToken", Method: "POST", Path: "/api/tokens", Tag: "tokens", Access: "a signed in user",
+		Summary: "Creates an API token, which is only returned once", Body: models.NewAPIToken{}, Response: models.CreatedAPIToken{},
+	},
+	{
+		ID: "deleteToken", Method: "DELETE", Path: "/api/tokens/:id", Tag: "tokens", Access: "the owner of the token or a platform admin",
+		Summary: "Revokes an API token", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: SimpleMsg{},
+	},
+
+	// repositories
+	{
+		ID: "listRepos", Method: "GET", Path: "/api/repos", Tag: "repositories", Access: accessViewerOrPublic,
+		Summary: "Lists all repositories", Response: []models.Repository{},
+	},
+	{
+		ID: "addRepoByURL", Method: "POST", Path: "/api/repos", Tag: "repositories", Access: accessRepoManager(models.TokenScopeReposWrite),
+		Summary: "Adds a repository by its GitHub URL", Response: models.Repository{},
+		Params: []apiParam{requiredQueryParam("url", stringSchema, "")},
+	},
+	{
+		ID: "getRepoOfBounty", Method: "GET", Path: "/api/repos/of/:id", Tag: "repositories", Access: accessViewerOrPublic,
+		Summary: "Returns the repository of a bounty", Params: []apiParam{bountyIDParam}, Response: models.Repository{},
+	},
+	{
+		ID: "getRepo", Method: "GET", Path: "/api/repos/:id", Tag: "repositories", Access: accessViewerOrPublic,
+		Summary: "Returns a repository", Params: []apiParam{repoIDParam}, Response: models.Repository{},
+	},
+	{
+		ID: "deleteRepo", Method: "DELETE", Path: "/api/repos/:id", Tag: "repositories", Access: accessRepoManager(models.TokenScopeReposWrite),
+		Summary: "Deletes a repository and its bounties", Params: []apiParam{repoIDParam}, Response: SimpleMsg{},
+	},
+	{
+		ID: "getRepoByName", Method: "GET", Path: "/api/repos/:owner/:name", Tag: "repositories", Access: accessViewerOrPublic,
+		Summary: "Returns a repository by its owner and name", Params: []apiParam{ownerParam, nameParam}, Response: models.Repository{},
+	},
+	{
+		ID: "addRepo", Method: "POST", Path: "/api/repos/:owner/:name", Tag: "repositories", Access: accessRepoManager(models.TokenScopeReposWrite),
+		Summary: "Adds a repository", Params: []apiParam{ownerParam, nameParam}, Response: models.Repository{},
+	},
+	{
+		ID: "updateRepoSettings", Method: "PUT", Path: "/api/repos/:id/settings", Tag: "repositories", Access: accessRepoManager(models.TokenScopeReposWrite),
+		Summary: "Replaces the settings of a repository", Params: []apiParam{repoIDParam},
+		Body: models.RepositorySettings{}, Response: models.Repository{},
+	},
+
+	// bounties
+	{
+		ID: "listBounties", Method: "GET", Path: "/api/bounties", Tag: "bounties", Access: accessViewerOrPublic,
+		Summary: "Lists bounties, 50 per page by default", Params: bountyQueryParams,
+		Response: []models.Bounty{}, Paged: true,
+	},
+	{
+		ID: "createBounty", Method: "POST", Path: "/api/bounties", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Creates a bounty for an issue", Body: models.NewBounty{}, Response: models.Bounty{},
+	},
+	{
+		ID: "getBounty", Method: "GET", Path: "/api/bounties/:id", Tag: "bounties", Access: accessViewerOrPublic,
+		Summary: "Returns a bounty", Params: []apiParam{bountyIDParam}, Response: models.Bounty{},
+	},
+	{
+		ID: "deleteBounty", Method: "DELETE", Path: "/api/bounties/:id", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Deletes a bounty", Params: []apiParam{bountyIDParam}, Response: SimpleMsg{},
+	},
+	{
+		ID: "listRepoBounties", Method: "GET", Path: "/api/bounties/:owner/:name", Tag: "bounties", Access: accessViewerOrPublic,
+		Summary: "Lists the bounties of a repository, all of them unless a limit is given",
+		Params:  withBountyQueryParams(ownerParam, nameParam), Response: []models.Bounty{}, Paged: true,
+	},
+	{
+		ID: "cancelBounty", Method: "POST", Path: "/api/bounties/:id/cancel", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Cancels a bounty and refunds its contributions", Params: []apiParam{bountyIDParam}, Response: []models.Refund{},
+	},
+	{
+		ID: "sweepBounty", Method: "POST", Path: "/api/bounties/:id/sweep", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Sweeps the late deposits of a settled bounty", Response: models.Payout{},
+		Params: []apiParam{bountyIDParam, requiredQueryParam("target", enumSchemas[reflect.TypeOf(models.SweepTarget(""))], "")},
+	},
+	{
+		ID: "registerRefundAddress", Method: "PUT", Path: "/api/bounties/:id/contributions/:bundle/refund_address", Tag: "bounties",
+		Access: accessRepoManager(models.TokenScopeBountiesWrite), Summary: "Registers the refund address of a contribution", Response: SimpleMsg{},
+		Params: []apiParam{
+			bountyIDParam,
+			pathParam("bundle", stringSchema, "the bundle hash of the contribution"),
+			requiredQueryParam("address", stringSchema, "the address with checksum"),
+		},
+	},
+	{
+		ID: "setBountyDeadline", Method: "PUT", Path: "/api/bounties/:id/deadline", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Sets the deadline of a bounty", Response: models.Bounty{},
+		Params: []apiParam{bountyIDParam, requiredQueryParam("deadline", dateSchema, "")},
+	},
+	{
+		ID: "setBountyMilestones", Method: "PUT", Path: "/api/bounties/:id/milestones", Tag: "bounties", Access: accessRepoManager(models.TokenScopeBountiesWrite),
+		Summary: "Replaces the milestones of a bounty", Params: []apiParam{bountyIDParam},
+		Body: []models.Milestone{}, Response: models.Bounty{},
+	},
+
+	// payouts
+	{
+		ID: "listPayouts", Method: "GET", Path: "/api/payouts", Tag: "payouts", Access: accessViewer,
+		Summary: "Lists all payouts, the ones in the given state or the ones of the given bounty", Response: []models.Payout{},
+		Params: []apiParam{
+			queryParam("state", enumSchemas[reflect.TypeOf(models.PayoutState(0))], ""),
+			queryParam("bounty_id", int64Schema, "can't be combined with the state"),
+		},
+	},
+	{
+		ID: "listStuckPayouts", Method: "GET", Path: "/api/payouts/stuck", Tag: "payouts", Access: accessViewer,
+		Summary: "Lists the payouts which didn't confirm in time", Response: []models.Payout{},
+	},
+	{
+		ID: "listPayoutsAwaitingApproval", Method: "GET", Path: "/api/payouts/awaiting_approval", Tag: "payouts", Access: accessViewer,
+		Summary: "Lists the payouts awaiting approval", Response: []models.Payout{},
+	},
+	{
+		ID: "approvePayout", Method: "POST", Path: "/api/payouts/:id/approve", Tag: "payouts", Access: accessPlatformAdminOrScope(models.TokenScopePayoutsApprove),
+		Summary: "Approves and sends a payout", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: models.Payout{},
+	},
+	{
+		ID: "rejectPayout", Method: "POST", Path: "/api/payouts/:id/reject", Tag: "payouts", Access: accessPlatformAdminOrScope(models.TokenScopePayoutsApprove),
+		Summary: "Rejects a payout", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: models.Payout{},
+	},
+
+	// campaigns
+	{
+		ID: "listCampaigns", Method: "GET", Path: "/api/campaigns", Tag: "campaigns", Access: accessViewer,
+		Summary: "Lists all matching campaigns", Response: []models.Campaign{},
+	},
+	{
+		ID: "createCampaign", Method: "POST", Path: "/api/campaigns", Tag: "campaigns", Access: accessPlatformAdmin,
+		Summary: "Creates a matching campaign", Body: models.Campaign{}, Response: models.Campaign{},
+	},
+	{
+		ID: "getCampaign", Method: "GET", Path: "/api/campaigns/:id", Tag: "campaigns", Access: accessViewer,
+		Summary: "Returns a campaign", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: models.Campaign{},
+	},
+	{
+		ID: "listCampaignTransfers", Method: "GET", Path: "/api/campaigns/:id/transfers", Tag: "campaigns", Access: accessViewer,
+		Summary: "Lists the transfers of a campaign", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: []models.CampaignTransfer{},
+	},
+	{
+		ID: "endCampaign", Method: "POST", Path: "/api/campaigns/:id/end", Tag: "campaigns", Access: accessPlatformAdmin,
+		Summary: "Ends a campaign early", Params: []apiParam{pathParam("id", objectIDSchema, "")}, Response: models.Campaign{},
+	},
+	{
+		ID: "withdrawCampaign", Method: "POST", Path: "/api/campaigns/:id/withdraw", Tag: "campaigns", Access: accessPlatformAdmin,
+		Summary: "Sends the leftover funds of an ended campaign back to the sponsor",
+		Params:  []apiParam{pathParam("id", objectIDSchema, "")}, Response: models.CampaignTransfer{},
+	},
+
+	// operations
+	{
+		ID: "listNodes", Method: "GET", Path: "/api/nodes", Tag: "operations", Access: accessViewer,
+		Summary: "Returns the health of the IOTA nodes", Response: []nodes.Status{},
+	},
+	{
+		ID: "listAuditEvents", Method: "GET", Path: "/api/audit", Tag: "operations", Access: accessViewer,
+		Summary: "Pages through the audit log or lists all events of a bounty", Response: []models.AuditEvent{},
+		Params: []apiParam{
+			queryParam("after", int64Schema, "the seq of the last event of the previous page"),
+			queryParam("limit", int64Schema, ""),
+			queryParam("bounty_id", int64Schema, "can't be combined with paging"),
+		},
+	},
+	{
+		ID: "verifyAuditLog", Method: "GET", Path: "/api/audit/verify", Tag: "operations", Access: accessViewer,
+		Summary: "Verifies the hash chain of the audit log", Response: controllers.AuditVerification{},
+	},
+	{
+		ID: "listReconciliationReports", Method: "GET", Path: "/api/reconciliation", Tag: "operations", Access: accessViewer,
+		Summary: "Lists the reconciliation reports, latest first", Response: []models.ReconciliationReport{},
+		Params: []apiParam{queryParam("limit", int64Schema, "")},
+	},
+	{
+		ID: "getLatestReconciliationReport", Method: "GET", Path: "/api/reconciliation/latest", Tag: "operations", Access: accessViewer,
+		Summary: "Returns the latest reconciliation report", Response: models.ReconciliationReport{},
+	},
+	{
+		ID: "reconcile", Method: "POST", Path: "/api/reconciliation", Tag: "operations", Access: accessPlatformAdmin,
+		Summary: "Runs a reconciliation", Response: models.ReconciliationReport{},
+	},
+
+	// search
+	{
+		ID: "search", Method: "GET", Path: "/api/search", Tag: "search", Access: accessViewerOrPublic,
+		Summary: "Searches bounties and repositories", Response: models.SearchResult{},
+		Params: withBountyQueryParams(requiredQueryParam("q", stringSchema, "the search text")),
+	},
+
+	// live updates
+	{
+		ID: "streamEvents", Method: "GET", Path: "/api/events", Tag: "events", Access: accessViewerOrPublic,
+		Summary: "Streams the changes of bounties and repositories as server-sent events", Response: models.LiveEvent{},
+		EventStream: true,
+		Params: []apiParam{
+			queryParam("repository_id", int64Schema, "only stream the events of this repository"),
+			queryParam("last_event_id", int64Schema, "resume after this event, the Last-Event-ID header takes precedence"),
+		},
+	},
+}
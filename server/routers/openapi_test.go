@@ -0,0 +1,38 @@
+package routers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	if openAPISpecErr != nil {
+		t.Fatalf("couldn't build the spec: %v", openAPISpecErr)
+	}
+
+	spec := struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}{}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("the spec isn't valid JSON: %v", err)
+	}
+
+	seen := map[string]bool{}
+	var operations int
+	for _, op := range apiOperations {
+		key := op.Method + " " + op.Path
+		if seen[key] {
+			t.Errorf("operation %s is described twice", key)
+		}
+		seen[key] = true
+		if op.ID == "" || op.Summary == "" {
+			t.Errorf("operation %s lacks an ID or summary", key)
+		}
+	}
+	for _, item := range spec.Paths {
+		operations += len(item)
+	}
+	if operations != len(apiOperations) {
+		t.Errorf("the spec holds %d operations, want %d", operations, len(apiOperations))
+	}
+}
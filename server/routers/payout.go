@@ -0,0 +1,114 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type PayoutRouter struct {
+	R      *echo.Echo              `inject:""`
+	BC     *controllers.BountyCtrl `inject:""`
+	Bot    *controllers.Bot        `inject:""`
+	Auth   *controllers.AuthCtrl   `inject:""`
+	Dev    bool                    `inject:"dev"`
+	Config *config.Configuration   `inject:""`
+}
+
+func (pr *PayoutRouter) Init() {
+
+	routeGroup := pr.R.Group("/api/payouts", requireViewer(pr.Auth))
+
+	// lists all payouts, the ones in the given state or the ones of the given bounty
+	routeGroup.GET("", func(c echo.Context) error {
+		if bountyIDStr := c.QueryParam("bounty_id"); bountyIDStr != "" {
+			bountyID, err := strconv.ParseInt(bountyIDStr, 10, 64)
+			if err != nil || c.QueryParam("state") != "" {
+				return ErrBadRequest
+			}
+
+			payouts, err := pr.BC.GetPayoutsOfBounty(bountyID)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, payouts)
+		}
+
+		var state *models.PayoutState
+		if stateStr := c.QueryParam("state"); stateStr != "" {
+			s, err := strconv.Atoi(stateStr)
+			if err != nil {
+				return ErrBadRequest
+			}
+			payoutState := models.PayoutState(s)
+			state = &payoutState
+		}
+
+		payouts, err := pr.BC.GetPayouts(state)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payouts)
+	})
+
+	routeGroup.GET("/stuck", func(c echo.Context) error {
+		payouts, err := pr.BC.GetStuckPayouts()
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payouts)
+	})
+
+	routeGroup.GET("/awaiting_approval", func(c echo.Context) error {
+		state := models.PayoutStateAwaitingApproval
+		payouts, err := pr.BC.GetPayouts(&state)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payouts)
+	})
+
+	routeGroup.POST("/:id/approve", func(c echo.Context) error {
+		payout, err := pr.loadPayout(c)
+		if err != nil {
+			return err
+		}
+
+		if err := pr.Bot.ApprovePayout(payout, apiActor(c)); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payout)
+	}, requirePlatformAdmin(pr.Auth, models.TokenScopePayoutsApprove))
+
+	routeGroup.POST("/:id/reject", func(c echo.Context) error {
+		payout, err := pr.loadPayout(c)
+		if err != nil {
+			return err
+		}
+
+		if err := pr.BC.RejectPayout(payout, apiActor(c)); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, payout)
+	}, requirePlatformAdmin(pr.Auth, models.TokenScopePayoutsApprove))
+
+}
+
+func (pr *PayoutRouter) loadPayout(c echo.Context) (*models.Payout, error) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, ErrBadRequest
+	}
+	return pr.BC.GetPayoutByID(id)
+}
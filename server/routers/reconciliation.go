@@ -0,0 +1,61 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type ReconciliationRouter struct {
+	R    *echo.Echo              `inject:""`
+	BC   *controllers.BountyCtrl `inject:""`
+	Bot  *controllers.Bot        `inject:""`
+	Auth *controllers.AuthCtrl   `inject:""`
+}
+
+func (rr *ReconciliationRouter) Init() {
+
+	routeGroup := rr.R.Group("/api/reconciliation", requireViewer(rr.Auth))
+
+	routeGroup.GET("", func(c echo.Context) error {
+		var limit int64
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			l, err := strconv.ParseInt(limitStr, 10, 64)
+			if err != nil {
+				return ErrBadRequest
+			}
+			limit = l
+		}
+
+		reports, err := rr.BC.GetReconciliationReports(limit)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, reports)
+	})
+
+	routeGroup.GET("/latest", func(c echo.Context) error {
+		reports, err := rr.BC.GetReconciliationReports(1)
+		if err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return echo.ErrNotFound
+		}
+
+		return c.JSON(http.StatusOK, reports[0])
+	})
+
+	// runs a reconciliation immediately instead of waiting for the next interval
+	routeGroup.POST("", func(c echo.Context) error {
+		report, err := rr.Bot.Reconcile()
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, report)
+	}, requirePlatformAdmin(rr.Auth, ""))
+}
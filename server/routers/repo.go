@@ -2,6 +2,8 @@ package routers
 
 import (
 	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
 	"net/http"
 	"strconv"
@@ -13,13 +15,17 @@ type RepoRouter struct {
 	R      *echo.Echo              `inject:""`
 	RC     *controllers.RepoCtrl   `inject:""`
 	BC     *controllers.BountyCtrl `inject:""`
+	Auth   *controllers.AuthCtrl   `inject:""`
+	Bot    *controllers.Bot        `inject:""`
 	Dev    bool                    `inject:"dev"`
 	Config *config.Configuration   `inject:""`
 }
 
 func (rr *RepoRouter) Init() {
 
-	routeGroup := rr.R.Group("/api/repos")
+	routeGroup := rr.R.Group("/api/repos", requireViewerOrPublic(rr.Auth,
+		"/api/repos", "/api/repos/of/:id", "/api/repos/:id", "/api/repos/:owner/:name",
+	))
 
 	routeGroup.GET("", func(c echo.Context) error {
 		repos, err := rr.RC.GetAll()
@@ -27,6 +33,13 @@ func (rr *RepoRouter) Init() {
 			return err
 		}
 
+		if isPublic(c) {
+			public := make([]models.PublicRepository, len(repos))
+			for i := range repos {
+				public[i] = models.NewPublicRepository(&repos[i])
+			}
+			return c.JSON(http.StatusOK, public)
+		}
 		return c.JSON(http.StatusOK, repos)
 	})
 
@@ -47,7 +60,7 @@ func (rr *RepoRouter) Init() {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, repo)
+		return respondRepo(c, repo)
 	})
 
 	routeGroup.GET("/:id", func(c echo.Context) error {
@@ -62,7 +75,7 @@ func (rr *RepoRouter) Init() {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, repo)
+		return respondRepo(c, repo)
 	})
 
 	routeGroup.GET("/:owner/:name", func(c echo.Context) error {
@@ -74,7 +87,7 @@ func (rr *RepoRouter) Init() {
 			return err
 		}
 
-		return c.JSON(http.StatusOK, repo)
+		return respondRepo(c, repo)
 	})
 
 	routeGroup.POST("", func(c echo.Context) error {
@@ -89,7 +102,13 @@ func (rr *RepoRouter) Init() {
 		}
 
 		return c.JSON(http.StatusOK, repo)
-	})
+	}, requireRepositoryManager(rr.Auth, models.TokenScopeReposWrite, func(c echo.Context) (string, string, error) {
+		owner, name, err := misc.ExtractOwnerAndNameFromGitHubURL(c.QueryParam("url"))
+		if err != nil {
+			return "", "", ErrBadRequest
+		}
+		return owner, name, nil
+	}))
 
 	routeGroup.POST("/:owner/:name", func(c echo.Context) error {
 		owner := c.Param("owner")
@@ -101,7 +120,28 @@ func (rr *RepoRouter) Init() {
 		}
 
 		return c.JSON(http.StatusOK, repo)
-	})
+	}, requireRepositoryManager(rr.Auth, models.TokenScopeReposWrite, func(c echo.Context) (string, string, error) {
+		return c.Param("owner"), c.Param("name"), nil
+	}))
+
+	routeGroup.PUT("/:id/settings", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		settings := &models.RepositorySettings{}
+		if err := c.Bind(settings); err != nil {
+			return ErrBadRequest
+		}
+
+		repo, err := rr.RC.UpdateSettings(id, settings)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, repo)
+	}, requireRepositoryManager(rr.Auth, models.TokenScopeReposWrite, repoByID(rr.RC, "id")))
 
 	routeGroup.DELETE("/:id", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -110,11 +150,19 @@ func (rr *RepoRouter) Init() {
 			return err
 		}
 
-		if err := rr.RC.Delete(int64(id)); err != nil {
+		if err := rr.Bot.DeleteRepository(int64(id), apiActor(c)); err != nil {
 			return err
 		}
 
 		return c.JSON(http.StatusOK, SimpleMsg{"ok"})
-	})
+	}, requireRepositoryManager(rr.Auth, models.TokenScopeReposWrite, repoByID(rr.RC, "id")))
+
+}
 
+// respondRepo responds with the public view of the repository to anonymous visitors.
+func respondRepo(c echo.Context, repo *models.Repository) error {
+	if isPublic(c) {
+		return c.JSON(http.StatusOK, models.NewPublicRepository(repo))
+	}
+	return c.JSON(http.StatusOK, repo)
 }
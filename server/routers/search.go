@@ -0,0 +1,52 @@
+package routers
+
+import (
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type SearchRouter struct {
+	R    *echo.Echo              `inject:""`
+	BC   *controllers.BountyCtrl `inject:""`
+	RC   *controllers.RepoCtrl   `inject:""`
+	PC   *controllers.PriceCtrl  `inject:""`
+	Auth *controllers.AuthCtrl   `inject:""`
+}
+
+func (sr *SearchRouter) Init() {
+
+	// searches bounties and repositories, the bounties can be narrowed down with the filters of the bounty listing
+	sr.R.GET("/api/search", func(c echo.Context) error {
+		text := strings.TrimSpace(c.QueryParam("q"))
+		if text == "" {
+			return ErrBadRequest
+		}
+		query, err := parseBountyQuery(c)
+		if err != nil {
+			return err
+		}
+
+		bounties, err := sr.BC.Search(text, query)
+		if err != nil {
+			return err
+		}
+		for i := range bounties {
+			sr.PC.AddFiat(&bounties[i].Bounty)
+		}
+
+		repos, err := sr.RC.Search(text, query.Limit)
+		if err != nil {
+			return err
+		}
+
+		res := &models.SearchResult{Bounties: bounties, Repositories: repos}
+		if isPublic(c) {
+			return c.JSON(http.StatusOK, models.NewPublicSearchResult(res))
+		}
+		return c.JSON(http.StatusOK, res)
+	}, requireViewerOrPublic(sr.Auth, "/api/search"))
+}
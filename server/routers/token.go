@@ -0,0 +1,103 @@
+package routers
+
+import (
+	"github.com/labstack/echo"
+	"github.com/luca-moser/iota-bounty-platform/server/controllers"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+)
+
+type TokenRouter struct {
+	R    *echo.Echo            `inject:""`
+	Auth *controllers.AuthCtrl `inject:""`
+}
+
+func (tr *TokenRouter) Init() {
+
+	// tokens can't be used to manage tokens
+	routeGroup := tr.R.Group("/api/tokens", tr.requireSignedIn)
+
+	// platform admins see all tokens, other users only their own ones
+	routeGroup.GET("", func(c echo.Context) error {
+		var ownerID int64
+		if session := tr.session(c); session != nil && !tr.Auth.IsPlatformAdmin(session.UserID) {
+			ownerID = session.UserID
+		}
+
+		tokens, err := tr.Auth.GetTokens(ownerID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, tokens)
+	})
+
+	routeGroup.POST("", func(c echo.Context) error {
+		req := &models.NewAPIToken{}
+		if err := c.Bind(req); err != nil {
+			return ErrBadRequest
+		}
+
+		session := tr.session(c)
+		if req.Service && session != nil && !tr.Auth.IsPlatformAdmin(session.UserID) {
+			return ErrForbidden
+		}
+
+		token, err := tr.Auth.CreateToken(req, session)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, token)
+	})
+
+	routeGroup.DELETE("/:id", func(c echo.Context) error {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			return ErrBadRequest
+		}
+
+		token, err := tr.Auth.GetTokenByID(id)
+		if err != nil {
+			return err
+		}
+
+		session := tr.session(c)
+		if session != nil && token.OwnerID != session.UserID && !tr.Auth.IsPlatformAdmin(session.UserID) {
+			return ErrForbidden
+		}
+
+		if err := tr.Auth.DeleteToken(token); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, SimpleMsg{"ok"})
+	})
+
+}
+
+// requireSignedIn rejects API tokens and, if OAuth is enabled, requests without a session.
+func (tr *TokenRouter) requireSignedIn(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if isBearerAuth(c.Request().Header.Get(echo.HeaderAuthorization)) {
+			return ErrForbidden
+		}
+		if !tr.Auth.Enabled() {
+			if err := requireBasicAuth(tr.Auth, c); err != nil {
+				return err
+			}
+			return next(c)
+		}
+		if _, err := requireSession(tr.Auth, c); err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
+
+// session returns the session of the signed in user, nil if OAuth is disabled.
+func (tr *TokenRouter) session(c echo.Context) *models.Session {
+	session, _ := c.Get(sessionContextKey).(*models.Session)
+	return session
+}
@@ -27,6 +27,15 @@ type Configuration struct {
 	DebugLoggerEnabled bool `json:"debug_logger_enabled"`
 	GitHub             GitHubConfig
 	Account            AccountConfig
+	Refund             RefundConfig
+	Deadline           DeadlineConfig
+	Fees               FeeConfig
+	Payouts            PayoutConfig
+	SeedEncryption     SeedEncryptionConfig `json:"seed_encryption"`
+	SeedDerivation     SeedDerivationConfig `json:"seed_derivation"`
+	Price              PriceConfig
+	FundingNotices     FundingNoticeConfig `json:"funding_notices"`
+	Reconciliation     ReconciliationConfig
 	HTTP               WebConfig
 	DB                 DBConfig
 }
@@ -44,12 +53,85 @@ type GitHubConfig struct {
 }
 
 type AccountConfig struct {
-	Node          string `json:"node"`
-	Collection    string `json:"collection"`
-	MWM           uint64 `json:"mwm"`
-	GTTADepth     uint64 `json:"gtta_depth"`
-	SecurityLevel uint64 `json:"security_level"`
-	NTPServer     string `json:"ntp_server"`
+	Node             string   `json:"node"`
+	Nodes            []string `json:"nodes"`
+	NodeCheckSeconds int      `json:"node_check_seconds"`
+	MaxMilestoneLag  int64    `json:"max_milestone_lag"`
+	Collection       string   `json:"collection"`
+	MWM              uint64   `json:"mwm"`
+	GTTADepth        uint64   `json:"gtta_depth"`
+	SecurityLevel    uint64   `json:"security_level"`
+	NTPServer        string   `json:"ntp_server"`
+}
+
+// NodeURIs returns the defined nodes, falling back to the single node of older configurations.
+func (ac AccountConfig) NodeURIs() []string {
+	if len(ac.Nodes) > 0 {
+		return ac.Nodes
+	}
+	if ac.Node != "" {
+		return []string{ac.Node}
+	}
+	return nil
+}
+
+type RefundConfig struct {
+	TreasuryAddress string `json:"treasury_address"`
+}
+
+type DeadlineConfig struct {
+	ReminderHours    []int `json:"reminder_hours"`
+	RefundAfterHours int   `json:"refund_after_hours"`
+}
+
+type FeeConfig struct {
+	PlatformFeePercent float64 `json:"platform_fee_percent"`
+	TreasuryAddress    string  `json:"treasury_address"`
+}
+
+type PayoutConfig struct {
+	ConfirmationPollSeconds int    `json:"confirmation_poll_seconds"`
+	StuckAfterMinutes       int    `json:"stuck_after_minutes"`
+	ApprovalThreshold       uint64 `json:"approval_threshold"`
+	ApprovalExpireHours     int    `json:"approval_expire_hours"`
+}
+
+type SeedEncryptionConfig struct {
+	KeyFile string `json:"key_file"`
+	KeyEnv  string `json:"key_env"`
+}
+
+type SeedDerivationConfig struct {
+	MasterSeedFile string `json:"master_seed_file"`
+	MasterSeedEnv  string `json:"master_seed_env"`
+}
+
+type PriceConfig struct {
+	URL            string `json:"url"`
+	KeyPath        string `json:"key_path"`
+	RefreshSeconds int    `json:"refresh_seconds"`
+	ShowInMessages bool   `json:"show_in_messages"`
+}
+
+type FundingNoticeConfig struct {
+	Threshold    uint64 `json:"threshold"`
+	QuietMinutes int    `json:"quiet_minutes"`
+}
+
+type ReconciliationConfig struct {
+	IntervalMinutes int `json:"interval_minutes"`
+}
+
+// OAuthConfig defines the GitHub OAuth app through which users sign in and the platform admins.
+type OAuthConfig struct {
+	Enabled      bool
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	CallbackURL  string `json:"callback_url"`
+	// the GitHub user IDs of the platform admins
+	PlatformAdminIDs []int64 `json:"platform_admin_ids"`
+	SessionHours     int     `json:"session_hours"`
+	SecureCookies    bool    `json:"secure_cookies"`
 }
 
 type DBConfig struct {
@@ -65,7 +147,12 @@ type WebConfig struct {
 		Username string
 		Password string
 	} `json:"basic_auth"`
-	Assets struct {
+	// replaces the basic auth if enabled
+	OAuth OAuthConfig `json:"oauth"`
+	// lets anonymous visitors list, view and search bounties and repositories,
+	// everything else still requires the basic auth credentials or signing in
+	PublicReadOnly bool `json:"public_read_only"`
+	Assets         struct {
 		Static  string
 		HTML    string
 		Favicon string
@@ -9,8 +9,10 @@ import (
 	"github.com/labstack/echo/middleware"
 	"github.com/luca-moser/iota-bounty-platform/server/controllers"
 	"github.com/luca-moser/iota-bounty-platform/server/misc"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
 	"github.com/luca-moser/iota-bounty-platform/server/routers"
 	"github.com/luca-moser/iota-bounty-platform/server/server/config"
+	"github.com/luca-moser/iota-bounty-platform/server/vault"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
@@ -20,6 +22,7 @@ import (
 	"html/template"
 	"io"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -71,14 +74,21 @@ func (server *Server) Start() {
 		templates: template.Must(template.ParseGlob(fmt.Sprintf("%s/*.html", httpConfig.Assets.HTML))),
 	}
 
-	// check whether we do basic HTTP auth
+	// check whether we do basic HTTP auth, signing in through GitHub replaces it
+	// and requests with API tokens are authenticated by the routers. On a public
+	// platform the routers check the credentials of everything but the public reads.
 	basicAuthConf := conf.HTTP.BasicAuth
-	if basicAuthConf.Enabled {
-		e.Use(middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
-			if username == basicAuthConf.Username && password == basicAuthConf.Password {
-				return true, nil
-			}
-			return false, nil
+	if basicAuthConf.Enabled && !conf.HTTP.OAuth.Enabled && !conf.HTTP.PublicReadOnly {
+		e.Use(middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+			Skipper: func(c echo.Context) bool {
+				return strings.HasPrefix(strings.ToLower(c.Request().Header.Get(echo.HeaderAuthorization)), "bearer ")
+			},
+			Validator: func(username, password string, c echo.Context) (bool, error) {
+				if username == basicAuthConf.Username && password == basicAuthConf.Password {
+					return true, nil
+				}
+				return false, nil
+			},
 		}))
 	}
 
@@ -104,31 +114,47 @@ func (server *Server) Start() {
 	// create controllers
 	appCtrl := &controllers.AppCtrl{}
 	repoCtrl := &controllers.RepoCtrl{}
+	authCtrl := &controllers.AuthCtrl{}
 	bountyCtrl := &controllers.BountyCtrl{}
+	priceCtrl := &controllers.PriceCtrl{}
+	nodeCtrl := &controllers.NodeCtrl{}
+	auditCtrl := &controllers.AuditCtrl{}
+	eventCtrl := &controllers.EventCtrl{}
 	bot := &controllers.Bot{}
-	ctrls := []controllers.Controller{appCtrl, repoCtrl, bountyCtrl, bot}
+	// the node controller must be initialised before the bounty controller composes its IOTA API
+	ctrls := []controllers.Controller{appCtrl, eventCtrl, repoCtrl, authCtrl, nodeCtrl, auditCtrl, bountyCtrl, priceCtrl, bot}
 
 	// create routers
 	indexRouter := &routers.IndexRouter{}
+	authRouter := &routers.AuthRouter{}
 	repoRouter := &routers.RepoRouter{}
 	bountyRouter := &routers.BountyRouter{}
-	rters := []routers.Router{indexRouter, repoRouter, bountyRouter}
+	payoutRouter := &routers.PayoutRouter{}
+	nodeRouter := &routers.NodeRouter{}
+	auditRouter := &routers.AuditRouter{}
+	reconciliationRouter := &routers.ReconciliationRouter{}
+	campaignRouter := &routers.CampaignRouter{}
+	searchRouter := &routers.SearchRouter{}
+	tokenRouter := &routers.TokenRouter{}
+	openAPIRouter := &routers.OpenAPIRouter{}
+	eventRouter := &routers.EventRouter{}
+	rters := []routers.Router{indexRouter, authRouter, repoRouter, bountyRouter, payoutRouter, nodeRouter, auditRouter, reconciliationRouter, campaignRouter, searchRouter, tokenRouter, openAPIRouter, eventRouter}
 
 	// init mongo db conn
-	mongoClient, err := mongo.NewClient([]*options.ClientOptions{
-		{
-			WriteConcern: writeconcern.New(writeconcern.J(true), writeconcern.WMajority(), writeconcern.WTimeout(5*time.Second)),
-			ReadConcern:  readconcern.Majority(),
-		},
-		options.Client().ApplyURI(server.Config.DB.URI),
-	}...)
+	mongoClient, err := connectMongo(server.Config.DB.URI)
 	must(err)
-
-	mongoConnCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	must(mongoClient.Connect(mongoConnCtx))
-	must(mongoClient.Ping(mongoConnCtx, nil))
 	logger.Info("connected to MongoDB")
 
+	// load the master key used to encrypt the bounty seeds
+	seedVault, err := vault.Load(conf.SeedEncryption.KeyFile, conf.SeedEncryption.KeyEnv)
+	must(err)
+	logger.Info(fmt.Sprintf("loaded seed master key %s", seedVault.KeyID()))
+
+	// load the master seed from which the bounty seeds are derived
+	seedDeriver, err := vault.LoadSeedDeriver(conf.SeedDerivation.MasterSeedFile, conf.SeedDerivation.MasterSeedEnv)
+	must(err)
+	logger.Info(fmt.Sprintf("loaded master seed %s", seedDeriver.ID()))
+
 	// create injection graph for automatic dependency injection
 	g := inject.Graph{}
 
@@ -138,6 +164,8 @@ func (server *Server) Start() {
 		&inject.Object{Value: mongoClient},
 		&inject.Object{Value: githubClient},
 		&inject.Object{Value: conf},
+		&inject.Object{Value: seedVault},
+		&inject.Object{Value: seedDeriver},
 		&inject.Object{Value: conf.Dev, Name: "dev"},
 	))
 
@@ -179,6 +207,103 @@ func (server *Server) Shutdown(ctx context.Context) {
 	must(server.WebEngine.Shutdown(ctx))
 }
 
+// RotateSeedKey re-encrypts all bounty seeds with the master key contained in the given key file.
+// The current master key is loaded as defined in the configuration.
+func RotateSeedKey(newKeyFile string) (int, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := vault.Load(conf.SeedEncryption.KeyFile, conf.SeedEncryption.KeyEnv)
+	if err != nil {
+		return 0, err
+	}
+
+	next, err := vault.Load(newKeyFile, "")
+	if err != nil {
+		return 0, err
+	}
+
+	mongoClient, err := connectMongo(conf.DB.URI)
+	if err != nil {
+		return 0, err
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	return controllers.RotateSeedKey(conf, mongoClient, current, next)
+}
+
+// RecoverAccounts re-derives the bounty seeds from the configured master seed and scans them for funds.
+func RecoverAccounts(indexCount int64, addrCount uint64) ([]controllers.RecoveredAccount, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	seedDeriver, err := vault.LoadSeedDeriver(conf.SeedDerivation.MasterSeedFile, conf.SeedDerivation.MasterSeedEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoClient, err := connectMongo(conf.DB.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	return controllers.RecoverAccounts(conf, mongoClient, seedDeriver, indexCount, addrCount)
+}
+
+// Reconcile compares the stored state of all bounties with the tangle and stores the report.
+func Reconcile() (*models.ReconciliationReport, error) {
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	seedVault, err := vault.Load(conf.SeedEncryption.KeyFile, conf.SeedEncryption.KeyEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	seedDeriver, err := vault.LoadSeedDeriver(conf.SeedDerivation.MasterSeedFile, conf.SeedDerivation.MasterSeedEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoClient, err := connectMongo(conf.DB.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	return controllers.Reconcile(conf, mongoClient, seedVault, seedDeriver)
+}
+
+func connectMongo(uri string) (*mongo.Client, error) {
+	mongoClient, err := mongo.NewClient([]*options.ClientOptions{
+		{
+			WriteConcern: writeconcern.New(writeconcern.J(true), writeconcern.WMajority(), writeconcern.WTimeout(5*time.Second)),
+			ReadConcern:  readconcern.Majority(),
+		},
+		options.Client().ApplyURI(uri),
+	}...)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoConnCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mongoClient.Connect(mongoConnCtx); err != nil {
+		return nil, err
+	}
+	if err := mongoClient.Ping(mongoConnCtx, nil); err != nil {
+		return nil, err
+	}
+	return mongoClient, nil
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,71 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/iotaledger/iota.go/consts"
+)
+
+const seedLength = 81
+
+// seedDerivationContext separates the seed derivation from any other use of the master seed.
+const seedDerivationContext = "ibp-bounty-seed"
+
+// SeedDeriver deterministically derives the seeds of bounty accounts from a single master seed
+// and a per-bounty index, so that all funds can be recovered from the master seed and the indices.
+type SeedDeriver struct {
+	masterSeed []byte
+	id         string
+}
+
+// LoadSeedDeriver loads the master seed from the given file or, if no file is given, from the given environment variable.
+func LoadSeedDeriver(masterSeedFile string, masterSeedEnv string) (*SeedDeriver, error) {
+	encodedSeed, err := readKey(masterSeedFile, masterSeedEnv)
+	if err != nil {
+		return nil, err
+	}
+	return NewSeedDeriver(encodedSeed)
+}
+
+// NewSeedDeriver creates a new SeedDeriver from the given hex or base64 encoded 32 bytes master seed.
+func NewSeedDeriver(encodedSeed string) (*SeedDeriver, error) {
+	masterSeed, err := decodeKey(encodedSeed)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(masterSeed)
+	return &SeedDeriver{masterSeed: masterSeed, id: hexID(hash[:])}, nil
+}
+
+// ID returns the identifier of the master seed.
+func (sd *SeedDeriver) ID() string {
+	return sd.id
+}
+
+// Derive derives the seed with the given index. The trytes are drawn from a HMAC-SHA256 based stream
+// keyed by the master seed; bytes above the largest multiple of 27 are discarded to not bias the seed.
+func (sd *SeedDeriver) Derive(index uint64) string {
+	seed := make([]byte, 0, seedLength)
+	tryteAlphabetLength := len(consts.TryteAlphabet)
+	limit := 256 - 256%tryteAlphabetLength
+
+	var block [16]byte
+	binary.BigEndian.PutUint64(block[:8], index)
+	for counter := uint64(0); len(seed) < seedLength; counter++ {
+		binary.BigEndian.PutUint64(block[8:], counter)
+		mac := hmac.New(sha256.New, sd.masterSeed)
+		mac.Write([]byte(seedDerivationContext))
+		mac.Write(block[:])
+		for _, b := range mac.Sum(nil) {
+			if int(b) >= limit {
+				continue
+			}
+			seed = append(seed, consts.TryteAlphabet[int(b)%tryteAlphabetLength])
+			if len(seed) == seedLength {
+				break
+			}
+		}
+	}
+	return string(seed)
+}
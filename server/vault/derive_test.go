@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"github.com/iotaledger/iota.go/guards"
+	"testing"
+)
+
+func newTestSeedDeriver(t *testing.T, key string) *SeedDeriver {
+	sd, err := NewSeedDeriver(key)
+	if err != nil {
+		t.Fatalf("couldn't create seed deriver: %v", err)
+	}
+	return sd
+}
+
+func TestDeriveIsDeterministic(t *testing.T) {
+	sd := newTestSeedDeriver(t, testKey)
+	again := newTestSeedDeriver(t, testKey)
+
+	for _, index := range []uint64{0, 1, 42, 1 << 40} {
+		seed := sd.Derive(index)
+		if !guards.IsTrytesOfExactLength(seed, seedLength) {
+			t.Errorf("index %d: %s isn't a seed", index, seed)
+		}
+		if sd.Derive(index) != seed || again.Derive(index) != seed {
+			t.Errorf("index %d: deriving twice produced different seeds", index)
+		}
+	}
+
+	// the derivation must never change, as otherwise the funds of existing bounties can't be recovered
+	const want = "JQRDBG99IJCKEIGZIY9OFXRLXSHJOLHTBDYZRMFMIATSIXERLZDKM9WECZRPDESICRFYIQT9YQEHTANWV"
+	if seed := sd.Derive(0); seed != want {
+		t.Errorf("got seed %s, want %s", seed, want)
+	}
+}
+
+func TestDeriveDiffers(t *testing.T) {
+	sd := newTestSeedDeriver(t, testKey)
+	other := newTestSeedDeriver(t, otherTestKey)
+
+	if sd.ID() == other.ID() {
+		t.Errorf("different master seeds have the same id")
+	}
+	seen := map[string]bool{}
+	for index := uint64(0); index < 100; index++ {
+		seed := sd.Derive(index)
+		if seen[seed] {
+			t.Errorf("index %d: derived a seed twice", index)
+		}
+		seen[seed] = true
+		if other.Derive(index) == seed {
+			t.Errorf("index %d: different master seeds derived the same seed", index)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/luca-moser/iota-bounty-platform/server/models"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+var ErrNoMasterKey = errors.New("no master key defined, set a key file or the key environment variable")
+var ErrInvalidMasterKey = errors.New("master key must be 32 bytes encoded as hex or base64")
+var ErrUnknownKey = errors.New("secret was encrypted with an unknown master key")
+
+// Vault encrypts secrets using envelope encryption: every secret is encrypted with its own random
+// data key which itself is encrypted (wrapped) with the master key.
+type Vault struct {
+	masterKey []byte
+	keyID     string
+	gcm       cipher.AEAD
+}
+
+// Load loads the master key from the given key file or, if no file is given, from the given environment variable.
+func Load(keyFile string, keyEnv string) (*Vault, error) {
+	encodedKey, err := readKey(keyFile, keyEnv)
+	if err != nil {
+		return nil, err
+	}
+	return New(encodedKey)
+}
+
+// New creates a new Vault from the given hex or base64 encoded master key.
+func New(encodedKey string) (*Vault, error) {
+	key, err := decodeKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyHash := sha256.Sum256(key)
+	return &Vault{masterKey: key, keyID: hexID(keyHash[:]), gcm: gcm}, nil
+}
+
+// hexID returns the hex encoded first 8 bytes of the given hash, used to identify keys without revealing them.
+func hexID(hash []byte) string {
+	return hex.EncodeToString(hash[:8])
+}
+
+// KeyID returns the identifier of the master key.
+func (v *Vault) KeyID() string {
+	return v.keyID
+}
+
+// Encrypt encrypts the given plaintext with a new data key wrapped by the master key.
+func (v *Vault) Encrypt(plaintext string) (*models.EncryptedSecret, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	dataGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(dataGCM, []byte(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(v.gcm, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EncryptedSecret{KeyID: v.keyID, WrappedKey: wrappedKey, Ciphertext: ciphertext}, nil
+}
+
+// Decrypt decrypts the given secret.
+func (v *Vault) Decrypt(secret *models.EncryptedSecret) (string, error) {
+	dataKey, err := v.unwrap(secret)
+	if err != nil {
+		return "", err
+	}
+
+	dataGCM, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataGCM, secret.Ciphertext)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decrypt secret")
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap re-encrypts the data key of the given secret (encrypted by this vault) with the master key of the target vault.
+// The secret's ciphertext stays untouched.
+func (v *Vault) Rewrap(secret *models.EncryptedSecret, target *Vault) (*models.EncryptedSecret, error) {
+	dataKey, err := v.unwrap(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(target.gcm, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EncryptedSecret{KeyID: target.keyID, WrappedKey: wrappedKey, Ciphertext: secret.Ciphertext}, nil
+}
+
+func (v *Vault) unwrap(secret *models.EncryptedSecret) ([]byte, error) {
+	if secret.KeyID != v.keyID {
+		return nil, ErrUnknownKey
+	}
+	dataKey, err := open(v.gcm, secret.WrappedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unwrap data key")
+	}
+	return dataKey, nil
+}
+
+// readKey reads the encoded key from the given file or, if no file is given, from the given environment variable.
+func readKey(keyFile string, keyEnv string) (string, error) {
+	var encodedKey string
+	switch {
+	case keyFile != "":
+		keyBytes, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to read master key file")
+		}
+		encodedKey = string(keyBytes)
+	case keyEnv != "":
+		encodedKey = os.Getenv(keyEnv)
+	}
+	encodedKey = strings.TrimSpace(encodedKey)
+	if encodedKey == "" {
+		return "", ErrNoMasterKey
+	}
+	return encodedKey, nil
+}
+
+func decodeKey(encodedKey string) ([]byte, error) {
+	key, err := hex.DecodeString(encodedKey)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(encodedKey)
+	}
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext and prefixes the result with the random nonce.
+func seal(gcm cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(gcm cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
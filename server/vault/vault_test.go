@@ -0,0 +1,152 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+const otherTestKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+
+func newTestVault(t *testing.T, key string) *Vault {
+	v, err := New(key)
+	if err != nil {
+		t.Fatalf("couldn't create vault: %v", err)
+	}
+	return v
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("abcd"); err != ErrInvalidMasterKey {
+		t.Errorf("short key: got error %v, want %v", err, ErrInvalidMasterKey)
+	}
+	if _, err := New(strings.Repeat("x", 64)); err != ErrInvalidMasterKey {
+		t.Errorf("undecodable key: got error %v, want %v", err, ErrInvalidMasterKey)
+	}
+	// the same key encoded as base64
+	base64Key := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	if newTestVault(t, base64Key).KeyID() != newTestVault(t, testKey).KeyID() {
+		t.Errorf("hex and base64 encodings of the same key have different ids")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	v := newTestVault(t, testKey)
+	const plaintext = "SEED9SEED9SEED"
+
+	secret, err := v.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("couldn't encrypt: %v", err)
+	}
+	if secret.KeyID != v.KeyID() {
+		t.Errorf("got key id %s, want %s", secret.KeyID, v.KeyID())
+	}
+	if strings.Contains(string(secret.Ciphertext), plaintext) {
+		t.Errorf("the ciphertext contains the plaintext")
+	}
+
+	decrypted, err := v.Decrypt(secret)
+	if err != nil {
+		t.Fatalf("couldn't decrypt: %v", err)
+	}
+	if decrypted != plaintext {
+		t.Errorf("got plaintext %q, want %q", decrypted, plaintext)
+	}
+
+	// every secret gets its own data key and nonce
+	other, err := v.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("couldn't encrypt: %v", err)
+	}
+	if string(other.Ciphertext) == string(secret.Ciphertext) || string(other.WrappedKey) == string(secret.WrappedKey) {
+		t.Errorf("encrypting the same plaintext twice produced the same secret")
+	}
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	v := newTestVault(t, testKey)
+	other := newTestVault(t, otherTestKey)
+
+	secret, err := v.Encrypt("SEED")
+	if err != nil {
+		t.Fatalf("couldn't encrypt: %v", err)
+	}
+	if _, err := other.Decrypt(secret); err != ErrUnknownKey {
+		t.Errorf("got error %v, want %v", err, ErrUnknownKey)
+	}
+
+	// a secret claiming to be wrapped by the other key still can't be unwrapped by it
+	secret.KeyID = other.KeyID()
+	if _, err := other.Decrypt(secret); err == nil {
+		t.Errorf("decrypted a secret wrapped by another key")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	v := newTestVault(t, testKey)
+
+	tests := []struct {
+		name   string
+		tamper func(ciphertext, wrappedKey []byte)
+	}{
+		{"ciphertext", func(ciphertext, wrappedKey []byte) { ciphertext[len(ciphertext)-1] ^= 1 }},
+		{"nonce", func(ciphertext, wrappedKey []byte) { ciphertext[0] ^= 1 }},
+		{"wrapped key", func(ciphertext, wrappedKey []byte) { wrappedKey[len(wrappedKey)-1] ^= 1 }},
+	}
+	for _, test := range tests {
+		secret, err := v.Encrypt("SEED")
+		if err != nil {
+			t.Fatalf("couldn't encrypt: %v", err)
+		}
+		test.tamper(secret.Ciphertext, secret.WrappedKey)
+		if _, err := v.Decrypt(secret); err == nil {
+			t.Errorf("%s: decrypted a tampered secret", test.name)
+		}
+	}
+
+	secret, err := v.Encrypt("SEED")
+	if err != nil {
+		t.Fatalf("couldn't encrypt: %v", err)
+	}
+	secret.Ciphertext = secret.Ciphertext[:4]
+	if _, err := v.Decrypt(secret); err == nil {
+		t.Errorf("decrypted a truncated secret")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	v := newTestVault(t, testKey)
+	target := newTestVault(t, otherTestKey)
+	const plaintext = "SEED9SEED9SEED"
+
+	secret, err := v.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("couldn't encrypt: %v", err)
+	}
+	rewrapped, err := v.Rewrap(secret, target)
+	if err != nil {
+		t.Fatalf("couldn't rewrap: %v", err)
+	}
+	if rewrapped.KeyID != target.KeyID() {
+		t.Errorf("got key id %s, want %s", rewrapped.KeyID, target.KeyID())
+	}
+	if string(rewrapped.Ciphertext) != string(secret.Ciphertext) {
+		t.Errorf("rewrapping changed the ciphertext")
+	}
+
+	decrypted, err := target.Decrypt(rewrapped)
+	if err != nil {
+		t.Fatalf("couldn't decrypt the rewrapped secret: %v", err)
+	}
+	if decrypted != plaintext {
+		t.Errorf("got plaintext %q, want %q", decrypted, plaintext)
+	}
+	if _, err := v.Decrypt(rewrapped); err != ErrUnknownKey {
+		t.Errorf("old vault: got error %v, want %v", err, ErrUnknownKey)
+	}
+
+	// secrets of other keys can't be rewrapped
+	if _, err := target.Rewrap(secret, v); err != ErrUnknownKey {
+		t.Errorf("foreign secret: got error %v, want %v", err, ErrUnknownKey)
+	}
+}